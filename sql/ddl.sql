@@ -400,7 +400,8 @@ CREATE TABLE public."user" (
     github_id character varying(255),
     wechat_id character varying(255),
     is_deleted boolean NOT NULL,
-    password character varying(255) NOT NULL
+    password character varying(255) NOT NULL,
+    deletion_scheduled_at timestamp without time zone
 );
 
 
@@ -428,6 +429,623 @@ ALTER TABLE public.user_id_seq OWNER TO sastlink;
 ALTER SEQUENCE public.user_id_seq OWNED BY public."user".id;
 
 
+--
+-- Name: two_factor; Type: TABLE; Schema: public; Owner: sastlink
+--
+
+CREATE TABLE public.two_factor (
+    id integer NOT NULL,
+    uid character varying(255) NOT NULL,
+    secret character varying(64) NOT NULL,
+    enabled boolean DEFAULT false NOT NULL,
+    recovery_codes character varying[],
+    created_at timestamp without time zone DEFAULT now() NOT NULL,
+    enabled_at timestamp without time zone
+);
+
+
+ALTER TABLE public.two_factor OWNER TO sastlink;
+
+--
+-- Name: COLUMN two_factor.uid; Type: COMMENT; Schema: public; Owner: sastlink
+--
+
+COMMENT ON COLUMN public.two_factor.uid IS '与user表uid映射';
+
+
+--
+-- Name: COLUMN two_factor.secret; Type: COMMENT; Schema: public; Owner: sastlink
+--
+
+COMMENT ON COLUMN public.two_factor.secret IS 'TOTP密钥(base32)';
+
+
+--
+-- Name: COLUMN two_factor.enabled; Type: COMMENT; Schema: public; Owner: sastlink
+--
+
+COMMENT ON COLUMN public.two_factor.enabled IS '是否已确认启用';
+
+
+--
+-- Name: COLUMN two_factor.recovery_codes; Type: COMMENT; Schema: public; Owner: sastlink
+--
+
+COMMENT ON COLUMN public.two_factor.recovery_codes IS '恢复码(sha256)';
+
+
+--
+-- Name: two_factor_id_seq; Type: SEQUENCE; Schema: public; Owner: sastlink
+--
+
+CREATE SEQUENCE public.two_factor_id_seq
+    AS integer
+    START WITH 1
+    INCREMENT BY 1
+    NO MINVALUE
+    NO MAXVALUE
+    CACHE 1;
+
+
+ALTER TABLE public.two_factor_id_seq OWNER TO sastlink;
+
+--
+-- Name: two_factor_id_seq; Type: SEQUENCE OWNED BY; Schema: public; Owner: sastlink
+--
+
+ALTER SEQUENCE public.two_factor_id_seq OWNED BY public.two_factor.id;
+
+
+--
+-- Name: webauthn_credential; Type: TABLE; Schema: public; Owner: sastlink
+--
+
+CREATE TABLE public.webauthn_credential (
+    id integer NOT NULL,
+    uid character varying(255) NOT NULL,
+    credential_id character varying(1400) NOT NULL,
+    public_key bytea NOT NULL,
+    sign_count bigint DEFAULT 0 NOT NULL,
+    aaguid character varying(36),
+    transports character varying[],
+    name character varying(64) NOT NULL,
+    created_at timestamp without time zone DEFAULT now() NOT NULL,
+    last_used_at timestamp without time zone
+);
+
+
+ALTER TABLE public.webauthn_credential OWNER TO sastlink;
+
+--
+-- Name: COLUMN webauthn_credential.uid; Type: COMMENT; Schema: public; Owner: sastlink
+--
+
+COMMENT ON COLUMN public.webauthn_credential.uid IS '与user表uid映射';
+
+
+--
+-- Name: COLUMN webauthn_credential.credential_id; Type: COMMENT; Schema: public; Owner: sastlink
+--
+
+COMMENT ON COLUMN public.webauthn_credential.credential_id IS '凭据ID(base64url)';
+
+
+--
+-- Name: COLUMN webauthn_credential.public_key; Type: COMMENT; Schema: public; Owner: sastlink
+--
+
+COMMENT ON COLUMN public.webauthn_credential.public_key IS 'COSE格式公钥';
+
+
+--
+-- Name: COLUMN webauthn_credential.sign_count; Type: COMMENT; Schema: public; Owner: sastlink
+--
+
+COMMENT ON COLUMN public.webauthn_credential.sign_count IS '签名计数器';
+
+
+--
+-- Name: COLUMN webauthn_credential.aaguid; Type: COMMENT; Schema: public; Owner: sastlink
+--
+
+COMMENT ON COLUMN public.webauthn_credential.aaguid IS '认证器型号';
+
+
+--
+-- Name: COLUMN webauthn_credential.transports; Type: COMMENT; Schema: public; Owner: sastlink
+--
+
+COMMENT ON COLUMN public.webauthn_credential.transports IS '传输方式';
+
+
+--
+-- Name: COLUMN webauthn_credential.name; Type: COMMENT; Schema: public; Owner: sastlink
+--
+
+COMMENT ON COLUMN public.webauthn_credential.name IS '用户设置的名称';
+
+
+--
+-- Name: webauthn_credential_id_seq; Type: SEQUENCE; Schema: public; Owner: sastlink
+--
+
+CREATE SEQUENCE public.webauthn_credential_id_seq
+    AS integer
+    START WITH 1
+    INCREMENT BY 1
+    NO MINVALUE
+    NO MAXVALUE
+    CACHE 1;
+
+
+ALTER TABLE public.webauthn_credential_id_seq OWNER TO sastlink;
+
+--
+-- Name: webauthn_credential_id_seq; Type: SEQUENCE OWNED BY; Schema: public; Owner: sastlink
+--
+
+ALTER SEQUENCE public.webauthn_credential_id_seq OWNED BY public.webauthn_credential.id;
+
+
+--
+-- Name: invitation; Type: TABLE; Schema: public; Owner: sastlink
+--
+
+CREATE TABLE public.invitation (
+    id integer NOT NULL,
+    code character varying(32) NOT NULL,
+    created_by character varying(255) NOT NULL,
+    note character varying(255),
+    org_id integer,
+    max_uses integer DEFAULT 1 NOT NULL,
+    used_count integer DEFAULT 0 NOT NULL,
+    expires_at timestamp without time zone NOT NULL,
+    created_at timestamp without time zone DEFAULT now() NOT NULL
+);
+
+
+ALTER TABLE public.invitation OWNER TO sastlink;
+
+--
+-- Name: COLUMN invitation.code; Type: COMMENT; Schema: public; Owner: sastlink
+--
+
+COMMENT ON COLUMN public.invitation.code IS '邀请码';
+
+
+--
+-- Name: COLUMN invitation.created_by; Type: COMMENT; Schema: public; Owner: sastlink
+--
+
+COMMENT ON COLUMN public.invitation.created_by IS '创建者uid';
+
+
+--
+-- Name: COLUMN invitation.note; Type: COMMENT; Schema: public; Owner: sastlink
+--
+
+COMMENT ON COLUMN public.invitation.note IS '备注，如被邀请人身份';
+
+
+--
+-- Name: COLUMN invitation.org_id; Type: COMMENT; Schema: public; Owner: sastlink
+--
+
+COMMENT ON COLUMN public.invitation.org_id IS '预设组织，与organize表id映射';
+
+
+--
+-- Name: COLUMN invitation.max_uses; Type: COMMENT; Schema: public; Owner: sastlink
+--
+
+COMMENT ON COLUMN public.invitation.max_uses IS '最大使用次数';
+
+
+--
+-- Name: COLUMN invitation.used_count; Type: COMMENT; Schema: public; Owner: sastlink
+--
+
+COMMENT ON COLUMN public.invitation.used_count IS '已使用次数';
+
+
+--
+-- Name: COLUMN invitation.expires_at; Type: COMMENT; Schema: public; Owner: sastlink
+--
+
+COMMENT ON COLUMN public.invitation.expires_at IS '过期时间';
+
+
+--
+-- Name: invitation_id_seq; Type: SEQUENCE; Schema: public; Owner: sastlink
+--
+
+CREATE SEQUENCE public.invitation_id_seq
+    AS integer
+    START WITH 1
+    INCREMENT BY 1
+    NO MINVALUE
+    NO MAXVALUE
+    CACHE 1;
+
+
+ALTER TABLE public.invitation_id_seq OWNER TO sastlink;
+
+--
+-- Name: invitation_id_seq; Type: SEQUENCE OWNED BY; Schema: public; Owner: sastlink
+--
+
+ALTER SEQUENCE public.invitation_id_seq OWNED BY public.invitation.id;
+
+
+--
+-- Name: invitation_redemption; Type: TABLE; Schema: public; Owner: sastlink
+--
+
+CREATE TABLE public.invitation_redemption (
+    id integer NOT NULL,
+    invitation_id integer NOT NULL,
+    uid character varying(255) NOT NULL,
+    email character varying(255) NOT NULL,
+    redeemed_at timestamp without time zone DEFAULT now() NOT NULL
+);
+
+
+ALTER TABLE public.invitation_redemption OWNER TO sastlink;
+
+--
+-- Name: COLUMN invitation_redemption.invitation_id; Type: COMMENT; Schema: public; Owner: sastlink
+--
+
+COMMENT ON COLUMN public.invitation_redemption.invitation_id IS '与invitation表映射';
+
+
+--
+-- Name: COLUMN invitation_redemption.uid; Type: COMMENT; Schema: public; Owner: sastlink
+--
+
+COMMENT ON COLUMN public.invitation_redemption.uid IS '注册用户uid';
+
+
+--
+-- Name: COLUMN invitation_redemption.email; Type: COMMENT; Schema: public; Owner: sastlink
+--
+
+COMMENT ON COLUMN public.invitation_redemption.email IS '注册邮箱';
+
+
+--
+-- Name: invitation_redemption_id_seq; Type: SEQUENCE; Schema: public; Owner: sastlink
+--
+
+CREATE SEQUENCE public.invitation_redemption_id_seq
+    AS integer
+    START WITH 1
+    INCREMENT BY 1
+    NO MINVALUE
+    NO MAXVALUE
+    CACHE 1;
+
+
+ALTER TABLE public.invitation_redemption_id_seq OWNER TO sastlink;
+
+--
+-- Name: invitation_redemption_id_seq; Type: SEQUENCE OWNED BY; Schema: public; Owner: sastlink
+--
+
+ALTER SEQUENCE public.invitation_redemption_id_seq OWNED BY public.invitation_redemption.id;
+
+
+--
+-- Name: oauth2_grant; Type: TABLE; Schema: public; Owner: sastlink
+--
+
+CREATE TABLE public.oauth2_grant (
+    id integer NOT NULL,
+    user_id character varying(255) NOT NULL,
+    client_id text NOT NULL,
+    "scope" text NOT NULL,
+    created_at timestamp without time zone DEFAULT now() NOT NULL,
+    updated_at timestamp without time zone DEFAULT now() NOT NULL,
+    last_used_at timestamp without time zone
+);
+
+
+ALTER TABLE public.oauth2_grant OWNER TO sastlink;
+
+--
+-- Name: COLUMN oauth2_grant.user_id; Type: COMMENT; Schema: public; Owner: sastlink
+--
+
+COMMENT ON COLUMN public.oauth2_grant.user_id IS '用户uid';
+
+
+--
+-- Name: COLUMN oauth2_grant.client_id; Type: COMMENT; Schema: public; Owner: sastlink
+--
+
+COMMENT ON COLUMN public.oauth2_grant.client_id IS '与oauth2_clients表id映射';
+
+
+--
+-- Name: COLUMN oauth2_grant."scope"; Type: COMMENT; Schema: public; Owner: sastlink
+--
+
+COMMENT ON COLUMN public.oauth2_grant."scope" IS '用户同意授权的scope，以空格分隔';
+
+
+--
+-- Name: COLUMN oauth2_grant.created_at; Type: COMMENT; Schema: public; Owner: sastlink
+--
+
+COMMENT ON COLUMN public.oauth2_grant.created_at IS '首次授权时间';
+
+
+--
+-- Name: COLUMN oauth2_grant.updated_at; Type: COMMENT; Schema: public; Owner: sastlink
+--
+
+COMMENT ON COLUMN public.oauth2_grant.updated_at IS '最近更新授权时间';
+
+
+--
+-- Name: COLUMN oauth2_grant.last_used_at; Type: COMMENT; Schema: public; Owner: sastlink
+--
+
+COMMENT ON COLUMN public.oauth2_grant.last_used_at IS '应用最近使用授权的时间';
+
+
+--
+-- Name: oauth2_grant_id_seq; Type: SEQUENCE; Schema: public; Owner: sastlink
+--
+
+CREATE SEQUENCE public.oauth2_grant_id_seq
+    AS integer
+    START WITH 1
+    INCREMENT BY 1
+    NO MINVALUE
+    NO MAXVALUE
+    CACHE 1;
+
+
+ALTER TABLE public.oauth2_grant_id_seq OWNER TO sastlink;
+
+--
+-- Name: oauth2_grant_id_seq; Type: SEQUENCE OWNED BY; Schema: public; Owner: sastlink
+--
+
+ALTER SEQUENCE public.oauth2_grant_id_seq OWNED BY public.oauth2_grant.id;
+
+
+--
+-- Name: service_account; Type: TABLE; Schema: public; Owner: sastlink
+--
+
+CREATE TABLE public.service_account (
+    id integer NOT NULL,
+    client_id text NOT NULL,
+    "owner" character varying(255) NOT NULL,
+    purpose character varying(255) NOT NULL,
+    "scope" text NOT NULL,
+    created_by character varying(255) NOT NULL,
+    created_at timestamp without time zone DEFAULT now() NOT NULL,
+    disabled_at timestamp without time zone
+);
+
+
+ALTER TABLE public.service_account OWNER TO sastlink;
+
+--
+-- Name: COLUMN service_account.client_id; Type: COMMENT; Schema: public; Owner: sastlink
+--
+
+COMMENT ON COLUMN public.service_account.client_id IS '与oauth2_clients表id映射';
+
+
+--
+-- Name: COLUMN service_account."owner"; Type: COMMENT; Schema: public; Owner: sastlink
+--
+
+COMMENT ON COLUMN public.service_account."owner" IS '负责人uid';
+
+
+--
+-- Name: COLUMN service_account.purpose; Type: COMMENT; Schema: public; Owner: sastlink
+--
+
+COMMENT ON COLUMN public.service_account.purpose IS '用途';
+
+
+--
+-- Name: COLUMN service_account."scope"; Type: COMMENT; Schema: public; Owner: sastlink
+--
+
+COMMENT ON COLUMN public.service_account."scope" IS '允许client_credentials申请的scope，以空格分隔';
+
+
+--
+-- Name: COLUMN service_account.created_by; Type: COMMENT; Schema: public; Owner: sastlink
+--
+
+COMMENT ON COLUMN public.service_account.created_by IS '创建者uid';
+
+
+--
+-- Name: COLUMN service_account.disabled_at; Type: COMMENT; Schema: public; Owner: sastlink
+--
+
+COMMENT ON COLUMN public.service_account.disabled_at IS '停用时间，停用后不能再获取token';
+
+
+--
+-- Name: service_account_id_seq; Type: SEQUENCE; Schema: public; Owner: sastlink
+--
+
+CREATE SEQUENCE public.service_account_id_seq
+    AS integer
+    START WITH 1
+    INCREMENT BY 1
+    NO MINVALUE
+    NO MAXVALUE
+    CACHE 1;
+
+
+ALTER TABLE public.service_account_id_seq OWNER TO sastlink;
+
+--
+-- Name: service_account_id_seq; Type: SEQUENCE OWNED BY; Schema: public; Owner: sastlink
+--
+
+ALTER SEQUENCE public.service_account_id_seq OWNED BY public.service_account.id;
+
+
+--
+-- Name: oauth2_security_event; Type: TABLE; Schema: public; Owner: sastlink
+--
+
+CREATE TABLE public.oauth2_security_event (
+    id integer NOT NULL,
+    event character varying(64) NOT NULL,
+    client_id character varying(255) NOT NULL,
+    user_id character varying(255) NOT NULL,
+    ip character varying(64) NOT NULL,
+    created_at timestamp without time zone DEFAULT now() NOT NULL
+);
+
+
+ALTER TABLE public.oauth2_security_event OWNER TO sastlink;
+
+--
+-- Name: COLUMN oauth2_security_event.event; Type: COMMENT; Schema: public; Owner: sastlink
+--
+
+COMMENT ON COLUMN public.oauth2_security_event.event IS '事件类型，如refresh_token_reused';
+
+
+--
+-- Name: COLUMN oauth2_security_event.client_id; Type: COMMENT; Schema: public; Owner: sastlink
+--
+
+COMMENT ON COLUMN public.oauth2_security_event.client_id IS '客户端id';
+
+
+--
+-- Name: COLUMN oauth2_security_event.user_id; Type: COMMENT; Schema: public; Owner: sastlink
+--
+
+COMMENT ON COLUMN public.oauth2_security_event.user_id IS '用户uid';
+
+
+--
+-- Name: COLUMN oauth2_security_event.ip; Type: COMMENT; Schema: public; Owner: sastlink
+--
+
+COMMENT ON COLUMN public.oauth2_security_event.ip IS '请求来源ip';
+
+
+--
+-- Name: oauth2_security_event_id_seq; Type: SEQUENCE; Schema: public; Owner: sastlink
+--
+
+CREATE SEQUENCE public.oauth2_security_event_id_seq
+    AS integer
+    START WITH 1
+    INCREMENT BY 1
+    NO MINVALUE
+    NO MAXVALUE
+    CACHE 1;
+
+
+ALTER TABLE public.oauth2_security_event_id_seq OWNER TO sastlink;
+
+--
+-- Name: oauth2_security_event_id_seq; Type: SEQUENCE OWNED BY; Schema: public; Owner: sastlink
+--
+
+ALTER SEQUENCE public.oauth2_security_event_id_seq OWNED BY public.oauth2_security_event.id;
+
+
+--
+-- Name: oauth2_initial_access_token; Type: TABLE; Schema: public; Owner: sastlink
+--
+
+CREATE TABLE public.oauth2_initial_access_token (
+    id integer NOT NULL,
+    token_hash character varying(64) NOT NULL,
+    created_by character varying(255) NOT NULL,
+    note character varying(255),
+    max_uses integer DEFAULT 1 NOT NULL,
+    used_count integer DEFAULT 0 NOT NULL,
+    expires_at timestamp without time zone NOT NULL,
+    created_at timestamp without time zone DEFAULT now() NOT NULL
+);
+
+
+ALTER TABLE public.oauth2_initial_access_token OWNER TO sastlink;
+
+--
+-- Name: COLUMN oauth2_initial_access_token.token_hash; Type: COMMENT; Schema: public; Owner: sastlink
+--
+
+COMMENT ON COLUMN public.oauth2_initial_access_token.token_hash IS 'token的SHA-256，token只在创建时返回';
+
+
+--
+-- Name: COLUMN oauth2_initial_access_token.created_by; Type: COMMENT; Schema: public; Owner: sastlink
+--
+
+COMMENT ON COLUMN public.oauth2_initial_access_token.created_by IS '创建者uid，注册的客户端归属于该用户';
+
+
+--
+-- Name: COLUMN oauth2_initial_access_token.note; Type: COMMENT; Schema: public; Owner: sastlink
+--
+
+COMMENT ON COLUMN public.oauth2_initial_access_token.note IS '备注，如使用的项目';
+
+
+--
+-- Name: COLUMN oauth2_initial_access_token.max_uses; Type: COMMENT; Schema: public; Owner: sastlink
+--
+
+COMMENT ON COLUMN public.oauth2_initial_access_token.max_uses IS '最多注册的客户端数';
+
+
+--
+-- Name: COLUMN oauth2_initial_access_token.used_count; Type: COMMENT; Schema: public; Owner: sastlink
+--
+
+COMMENT ON COLUMN public.oauth2_initial_access_token.used_count IS '已注册的客户端数';
+
+
+--
+-- Name: COLUMN oauth2_initial_access_token.expires_at; Type: COMMENT; Schema: public; Owner: sastlink
+--
+
+COMMENT ON COLUMN public.oauth2_initial_access_token.expires_at IS '过期时间';
+
+
+--
+-- Name: oauth2_initial_access_token_id_seq; Type: SEQUENCE; Schema: public; Owner: sastlink
+--
+
+CREATE SEQUENCE public.oauth2_initial_access_token_id_seq
+    AS integer
+    START WITH 1
+    INCREMENT BY 1
+    NO MINVALUE
+    NO MAXVALUE
+    CACHE 1;
+
+
+ALTER TABLE public.oauth2_initial_access_token_id_seq OWNER TO sastlink;
+
+--
+-- Name: oauth2_initial_access_token_id_seq; Type: SEQUENCE OWNED BY; Schema: public; Owner: sastlink
+--
+
+ALTER SEQUENCE public.oauth2_initial_access_token_id_seq OWNED BY public.oauth2_initial_access_token.id;
+
+
 --
 -- Name: admin id; Type: DEFAULT; Schema: public; Owner: sastlink
 --
@@ -477,6 +1095,62 @@ ALTER TABLE ONLY public.profile ALTER COLUMN id SET DEFAULT nextval('public.prof
 ALTER TABLE ONLY public."user" ALTER COLUMN id SET DEFAULT nextval('public.user_id_seq'::regclass);
 
 
+--
+-- Name: two_factor id; Type: DEFAULT; Schema: public; Owner: sastlink
+--
+
+ALTER TABLE ONLY public.two_factor ALTER COLUMN id SET DEFAULT nextval('public.two_factor_id_seq'::regclass);
+
+
+--
+-- Name: webauthn_credential id; Type: DEFAULT; Schema: public; Owner: sastlink
+--
+
+ALTER TABLE ONLY public.webauthn_credential ALTER COLUMN id SET DEFAULT nextval('public.webauthn_credential_id_seq'::regclass);
+
+
+--
+-- Name: invitation id; Type: DEFAULT; Schema: public; Owner: sastlink
+--
+
+ALTER TABLE ONLY public.invitation ALTER COLUMN id SET DEFAULT nextval('public.invitation_id_seq'::regclass);
+
+
+--
+-- Name: invitation_redemption id; Type: DEFAULT; Schema: public; Owner: sastlink
+--
+
+ALTER TABLE ONLY public.invitation_redemption ALTER COLUMN id SET DEFAULT nextval('public.invitation_redemption_id_seq'::regclass);
+
+
+--
+-- Name: oauth2_grant id; Type: DEFAULT; Schema: public; Owner: sastlink
+--
+
+ALTER TABLE ONLY public.oauth2_grant ALTER COLUMN id SET DEFAULT nextval('public.oauth2_grant_id_seq'::regclass);
+
+
+--
+-- Name: service_account id; Type: DEFAULT; Schema: public; Owner: sastlink
+--
+
+ALTER TABLE ONLY public.service_account ALTER COLUMN id SET DEFAULT nextval('public.service_account_id_seq'::regclass);
+
+
+--
+-- Name: oauth2_security_event id; Type: DEFAULT; Schema: public; Owner: sastlink
+--
+
+ALTER TABLE ONLY public.oauth2_security_event ALTER COLUMN id SET DEFAULT nextval('public.oauth2_security_event_id_seq'::regclass);
+
+
+--
+-- Name: oauth2_initial_access_token id; Type: DEFAULT; Schema: public; Owner: sastlink
+--
+
+ALTER TABLE ONLY public.oauth2_initial_access_token ALTER COLUMN id SET DEFAULT nextval('public.oauth2_initial_access_token_id_seq'::regclass);
+
+
 --
 -- Name: admin admin_pkey; Type: CONSTRAINT; Schema: public; Owner: sastlink
 --
@@ -549,6 +1223,118 @@ ALTER TABLE ONLY public."user"
     ADD CONSTRAINT user_un UNIQUE (uid, id);
 
 
+--
+-- Name: two_factor two_factor_pkey; Type: CONSTRAINT; Schema: public; Owner: sastlink
+--
+
+ALTER TABLE ONLY public.two_factor
+    ADD CONSTRAINT two_factor_pkey PRIMARY KEY (id);
+
+
+--
+-- Name: two_factor two_factor_uid_key; Type: CONSTRAINT; Schema: public; Owner: sastlink
+--
+
+ALTER TABLE ONLY public.two_factor
+    ADD CONSTRAINT two_factor_uid_key UNIQUE (uid);
+
+
+--
+-- Name: webauthn_credential webauthn_credential_pkey; Type: CONSTRAINT; Schema: public; Owner: sastlink
+--
+
+ALTER TABLE ONLY public.webauthn_credential
+    ADD CONSTRAINT webauthn_credential_pkey PRIMARY KEY (id);
+
+
+--
+-- Name: webauthn_credential webauthn_credential_credential_id_key; Type: CONSTRAINT; Schema: public; Owner: sastlink
+--
+
+ALTER TABLE ONLY public.webauthn_credential
+    ADD CONSTRAINT webauthn_credential_credential_id_key UNIQUE (credential_id);
+
+
+--
+-- Name: invitation invitation_pkey; Type: CONSTRAINT; Schema: public; Owner: sastlink
+--
+
+ALTER TABLE ONLY public.invitation
+    ADD CONSTRAINT invitation_pkey PRIMARY KEY (id);
+
+
+--
+-- Name: invitation invitation_code_key; Type: CONSTRAINT; Schema: public; Owner: sastlink
+--
+
+ALTER TABLE ONLY public.invitation
+    ADD CONSTRAINT invitation_code_key UNIQUE (code);
+
+
+--
+-- Name: invitation_redemption invitation_redemption_pkey; Type: CONSTRAINT; Schema: public; Owner: sastlink
+--
+
+ALTER TABLE ONLY public.invitation_redemption
+    ADD CONSTRAINT invitation_redemption_pkey PRIMARY KEY (id);
+
+
+--
+-- Name: oauth2_grant oauth2_grant_pkey; Type: CONSTRAINT; Schema: public; Owner: sastlink
+--
+
+ALTER TABLE ONLY public.oauth2_grant
+    ADD CONSTRAINT oauth2_grant_pkey PRIMARY KEY (id);
+
+
+--
+-- Name: oauth2_grant oauth2_grant_user_client_key; Type: CONSTRAINT; Schema: public; Owner: sastlink
+--
+
+ALTER TABLE ONLY public.oauth2_grant
+    ADD CONSTRAINT oauth2_grant_user_client_key UNIQUE (user_id, client_id);
+
+
+--
+-- Name: service_account service_account_pkey; Type: CONSTRAINT; Schema: public; Owner: sastlink
+--
+
+ALTER TABLE ONLY public.service_account
+    ADD CONSTRAINT service_account_pkey PRIMARY KEY (id);
+
+
+--
+-- Name: service_account service_account_client_id_key; Type: CONSTRAINT; Schema: public; Owner: sastlink
+--
+
+ALTER TABLE ONLY public.service_account
+    ADD CONSTRAINT service_account_client_id_key UNIQUE (client_id);
+
+
+--
+-- Name: oauth2_security_event oauth2_security_event_pkey; Type: CONSTRAINT; Schema: public; Owner: sastlink
+--
+
+ALTER TABLE ONLY public.oauth2_security_event
+    ADD CONSTRAINT oauth2_security_event_pkey PRIMARY KEY (id);
+
+
+--
+-- Name: oauth2_initial_access_token oauth2_initial_access_token_pkey; Type: CONSTRAINT; Schema: public; Owner: sastlink
+--
+
+ALTER TABLE ONLY public.oauth2_initial_access_token
+    ADD CONSTRAINT oauth2_initial_access_token_pkey PRIMARY KEY (id);
+
+
+--
+-- Name: oauth2_initial_access_token oauth2_initial_access_token_token_hash_key; Type: CONSTRAINT; Schema: public; Owner: sastlink
+--
+
+ALTER TABLE ONLY public.oauth2_initial_access_token
+    ADD CONSTRAINT oauth2_initial_access_token_token_hash_key UNIQUE (token_hash);
+
+
 --
 -- Name: idx_oauth2_tokens_access; Type: INDEX; Schema: public; Owner: sastlink
 --
@@ -577,6 +1363,34 @@ CREATE INDEX idx_oauth2_tokens_expires_at ON public.oauth2_tokens USING btree (e
 CREATE INDEX idx_oauth2_tokens_refresh ON public.oauth2_tokens USING btree (refresh);
 
 
+--
+-- Name: webauthn_credential_uid_idx; Type: INDEX; Schema: public; Owner: sastlink
+--
+
+CREATE INDEX webauthn_credential_uid_idx ON public.webauthn_credential USING btree (uid);
+
+
+--
+-- Name: invitation_redemption_invitation_id_idx; Type: INDEX; Schema: public; Owner: sastlink
+--
+
+CREATE INDEX invitation_redemption_invitation_id_idx ON public.invitation_redemption USING btree (invitation_id);
+
+
+--
+-- Name: oauth2_security_event_client_id_idx; Type: INDEX; Schema: public; Owner: sastlink
+--
+
+CREATE INDEX oauth2_security_event_client_id_idx ON public.oauth2_security_event USING btree (client_id);
+
+
+--
+-- Name: oauth2_security_event_user_id_idx; Type: INDEX; Schema: public; Owner: sastlink
+--
+
+CREATE INDEX oauth2_security_event_user_id_idx ON public.oauth2_security_event USING btree (user_id);
+
+
 --
 -- PostgreSQL database dump complete
 --
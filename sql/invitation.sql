@@ -0,0 +1,49 @@
+-- public.invitation definition
+
+-- Drop table
+
+-- DROP TABLE public.invitation;
+
+CREATE TABLE public.invitation (
+	id SERIAL PRIMARY KEY,
+	code varchar(32) NOT NULL UNIQUE, -- 邀请码
+	created_by varchar(255) NOT NULL, -- 创建者uid
+	note varchar(255) NULL, -- 备注，如被邀请人身份
+	org_id int4 NULL, -- 预设组织，与organize表id映射
+	max_uses int4 NOT NULL DEFAULT 1, -- 最大使用次数
+	used_count int4 NOT NULL DEFAULT 0, -- 已使用次数
+	expires_at timestamp NOT NULL, -- 过期时间
+	created_at timestamp NOT NULL DEFAULT now()
+);
+
+-- Column comments
+
+COMMENT ON COLUMN public.invitation.code IS '邀请码';
+COMMENT ON COLUMN public.invitation.created_by IS '创建者uid';
+COMMENT ON COLUMN public.invitation.note IS '备注，如被邀请人身份';
+COMMENT ON COLUMN public.invitation.org_id IS '预设组织，与organize表id映射';
+COMMENT ON COLUMN public.invitation.max_uses IS '最大使用次数';
+COMMENT ON COLUMN public.invitation.used_count IS '已使用次数';
+COMMENT ON COLUMN public.invitation.expires_at IS '过期时间';
+
+
+-- public.invitation_redemption definition
+
+-- Drop table
+
+-- DROP TABLE public.invitation_redemption;
+
+CREATE TABLE public.invitation_redemption (
+	id SERIAL PRIMARY KEY,
+	invitation_id int4 NOT NULL, -- 与invitation表映射
+	uid varchar(255) NOT NULL, -- 注册用户uid
+	email varchar(255) NOT NULL, -- 注册邮箱
+	redeemed_at timestamp NOT NULL DEFAULT now()
+);
+CREATE INDEX invitation_redemption_invitation_id_idx ON public.invitation_redemption USING btree (invitation_id);
+
+-- Column comments
+
+COMMENT ON COLUMN public.invitation_redemption.invitation_id IS '与invitation表映射';
+COMMENT ON COLUMN public.invitation_redemption.uid IS '注册用户uid';
+COMMENT ON COLUMN public.invitation_redemption.email IS '注册邮箱';
@@ -0,0 +1,25 @@
+-- public.oauth2_grant definition
+
+-- Drop table
+
+-- DROP TABLE public.oauth2_grant;
+
+CREATE TABLE public.oauth2_grant (
+	id SERIAL PRIMARY KEY,
+	user_id varchar(255) NOT NULL, -- 用户uid
+	client_id text NOT NULL, -- 与oauth2_clients表id映射
+	"scope" text NOT NULL, -- 用户同意授权的scope，以空格分隔
+	created_at timestamp NOT NULL DEFAULT now(), -- 首次授权时间
+	updated_at timestamp NOT NULL DEFAULT now(), -- 最近更新授权时间
+	last_used_at timestamp NULL, -- 应用最近使用授权的时间
+	CONSTRAINT oauth2_grant_user_client_key UNIQUE (user_id, client_id)
+);
+
+-- Column comments
+
+COMMENT ON COLUMN public.oauth2_grant.user_id IS '用户uid';
+COMMENT ON COLUMN public.oauth2_grant.client_id IS '与oauth2_clients表id映射';
+COMMENT ON COLUMN public.oauth2_grant."scope" IS '用户同意授权的scope，以空格分隔';
+COMMENT ON COLUMN public.oauth2_grant.created_at IS '首次授权时间';
+COMMENT ON COLUMN public.oauth2_grant.updated_at IS '最近更新授权时间';
+COMMENT ON COLUMN public.oauth2_grant.last_used_at IS '应用最近使用授权的时间';
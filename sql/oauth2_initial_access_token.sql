@@ -0,0 +1,25 @@
+-- public.oauth2_initial_access_token definition
+
+-- Drop table
+
+-- DROP TABLE public.oauth2_initial_access_token;
+
+CREATE TABLE public.oauth2_initial_access_token (
+	id SERIAL PRIMARY KEY,
+	token_hash varchar(64) NOT NULL UNIQUE, -- token的SHA-256，token只在创建时返回
+	created_by varchar(255) NOT NULL, -- 创建者uid，注册的客户端归属于该用户
+	note varchar(255) NULL, -- 备注，如使用的项目
+	max_uses int4 NOT NULL DEFAULT 1, -- 最多注册的客户端数
+	used_count int4 NOT NULL DEFAULT 0, -- 已注册的客户端数
+	expires_at timestamp NOT NULL, -- 过期时间
+	created_at timestamp NOT NULL DEFAULT now()
+);
+
+-- Column comments
+
+COMMENT ON COLUMN public.oauth2_initial_access_token.token_hash IS 'token的SHA-256，token只在创建时返回';
+COMMENT ON COLUMN public.oauth2_initial_access_token.created_by IS '创建者uid，注册的客户端归属于该用户';
+COMMENT ON COLUMN public.oauth2_initial_access_token.note IS '备注，如使用的项目';
+COMMENT ON COLUMN public.oauth2_initial_access_token.max_uses IS '最多注册的客户端数';
+COMMENT ON COLUMN public.oauth2_initial_access_token.used_count IS '已注册的客户端数';
+COMMENT ON COLUMN public.oauth2_initial_access_token.expires_at IS '过期时间';
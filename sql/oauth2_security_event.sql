@@ -0,0 +1,24 @@
+-- public.oauth2_security_event definition
+
+-- Drop table
+
+-- DROP TABLE public.oauth2_security_event;
+
+CREATE TABLE public.oauth2_security_event (
+	id SERIAL PRIMARY KEY,
+	event varchar(64) NOT NULL, -- 事件类型，如refresh_token_reused
+	client_id varchar(255) NOT NULL, -- 客户端id
+	user_id varchar(255) NOT NULL, -- 用户uid
+	ip varchar(64) NOT NULL, -- 请求来源ip
+	created_at timestamp NOT NULL DEFAULT now()
+);
+
+CREATE INDEX oauth2_security_event_client_id_idx ON public.oauth2_security_event (client_id);
+CREATE INDEX oauth2_security_event_user_id_idx ON public.oauth2_security_event (user_id);
+
+-- Column comments
+
+COMMENT ON COLUMN public.oauth2_security_event.event IS '事件类型，如refresh_token_reused';
+COMMENT ON COLUMN public.oauth2_security_event.client_id IS '客户端id';
+COMMENT ON COLUMN public.oauth2_security_event.user_id IS '用户uid';
+COMMENT ON COLUMN public.oauth2_security_event.ip IS '请求来源ip';
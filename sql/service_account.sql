@@ -0,0 +1,25 @@
+-- public.service_account definition
+
+-- Drop table
+
+-- DROP TABLE public.service_account;
+
+CREATE TABLE public.service_account (
+	id SERIAL PRIMARY KEY,
+	client_id text NOT NULL UNIQUE, -- 与oauth2_clients表id映射
+	"owner" varchar(255) NOT NULL, -- 负责人uid
+	purpose varchar(255) NOT NULL, -- 用途
+	"scope" text NOT NULL, -- 允许client_credentials申请的scope，以空格分隔
+	created_by varchar(255) NOT NULL, -- 创建者uid
+	created_at timestamp NOT NULL DEFAULT now(),
+	disabled_at timestamp NULL -- 停用时间，停用后不能再获取token
+);
+
+-- Column comments
+
+COMMENT ON COLUMN public.service_account.client_id IS '与oauth2_clients表id映射';
+COMMENT ON COLUMN public.service_account."owner" IS '负责人uid';
+COMMENT ON COLUMN public.service_account.purpose IS '用途';
+COMMENT ON COLUMN public.service_account."scope" IS '允许client_credentials申请的scope，以空格分隔';
+COMMENT ON COLUMN public.service_account.created_by IS '创建者uid';
+COMMENT ON COLUMN public.service_account.disabled_at IS '停用时间，停用后不能再获取token';
@@ -0,0 +1,22 @@
+-- public.two_factor definition
+
+-- Drop table
+
+-- DROP TABLE public.two_factor;
+
+CREATE TABLE public.two_factor (
+	id SERIAL PRIMARY KEY,
+	uid varchar(255) NOT NULL UNIQUE, -- 与user表uid映射
+	secret varchar(64) NOT NULL, -- TOTP密钥(base32)
+	enabled bool NOT NULL DEFAULT false, -- 是否已确认启用
+	recovery_codes _varchar NULL, -- 恢复码(sha256)
+	created_at timestamp NOT NULL DEFAULT now(),
+	enabled_at timestamp NULL
+);
+
+-- Column comments
+
+COMMENT ON COLUMN public.two_factor.uid IS '与user表uid映射';
+COMMENT ON COLUMN public.two_factor.secret IS 'TOTP密钥(base32)';
+COMMENT ON COLUMN public.two_factor.enabled IS '是否已确认启用';
+COMMENT ON COLUMN public.two_factor.recovery_codes IS '恢复码(sha256)';
@@ -14,5 +14,10 @@ CREATE TABLE public."user" (
 	github_id varchar(255) NULL,
 	wechat_id varchar(255) NULL,
 	is_deleted bool NOT NULL,
-	"password" varchar(255) NOT NULL
+	"password" varchar(255) NOT NULL,
+	deletion_scheduled_at timestamp NULL -- 申请注销后数据清除的时间
 );
+
+-- Column comments
+
+COMMENT ON COLUMN public."user".deletion_scheduled_at IS '申请注销后数据清除的时间';
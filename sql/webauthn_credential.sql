@@ -0,0 +1,29 @@
+-- public.webauthn_credential definition
+
+-- Drop table
+
+-- DROP TABLE public.webauthn_credential;
+
+CREATE TABLE public.webauthn_credential (
+	id SERIAL PRIMARY KEY,
+	uid varchar(255) NOT NULL, -- 与user表uid映射
+	credential_id varchar(1400) NOT NULL UNIQUE, -- 凭据ID(base64url)
+	public_key bytea NOT NULL, -- COSE格式公钥
+	sign_count int8 NOT NULL DEFAULT 0, -- 签名计数器
+	aaguid varchar(36) NULL, -- 认证器型号
+	transports _varchar NULL, -- 传输方式
+	name varchar(64) NOT NULL, -- 用户设置的名称
+	created_at timestamp NOT NULL DEFAULT now(),
+	last_used_at timestamp NULL
+);
+CREATE INDEX webauthn_credential_uid_idx ON public.webauthn_credential USING btree (uid);
+
+-- Column comments
+
+COMMENT ON COLUMN public.webauthn_credential.uid IS '与user表uid映射';
+COMMENT ON COLUMN public.webauthn_credential.credential_id IS '凭据ID(base64url)';
+COMMENT ON COLUMN public.webauthn_credential.public_key IS 'COSE格式公钥';
+COMMENT ON COLUMN public.webauthn_credential.sign_count IS '签名计数器';
+COMMENT ON COLUMN public.webauthn_credential.aaguid IS '认证器型号';
+COMMENT ON COLUMN public.webauthn_credential.transports IS '传输方式';
+COMMENT ON COLUMN public.webauthn_credential.name IS '用户设置的名称';
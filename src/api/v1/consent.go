@@ -0,0 +1,54 @@
+package v1
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/NJUPT-SAST/sast-link-backend/model/result"
+	"github.com/NJUPT-SAST/sast-link-backend/service"
+	"github.com/gin-gonic/gin"
+	"github.com/go-oauth2/oauth2/v4/errors"
+)
+
+// DecideConsent record the decision of the user on the consent screen,
+// the frontend should then retry the authorization request.
+func DecideConsent(c *gin.Context) {
+	clientID := c.PostForm("client_id")
+	approved, err := strconv.ParseBool(c.PostForm("approve"))
+	if clientID == "" || err != nil {
+		c.JSON(http.StatusOK, result.Failed(result.RequestParamError))
+		return
+	}
+	uid := c.GetString("uid")
+	if err := service.DecideConsent(c, uid, clientID, c.PostForm("scope"), approved); err != nil {
+		controllerLogger.Errorln("DecideConsent Err", err)
+		c.JSON(http.StatusOK, result.Failed(result.HandleError(err)))
+		return
+	}
+	c.JSON(http.StatusOK, result.Success(nil))
+}
+
+// checkConsent return the consent state of uid for the authorization request,
+// errors are converted to oauth2 errors to redirect.
+func checkConsent(r *http.Request, uid string) (service.ConsentState, error) {
+	force := hasValue(strings.Fields(r.FormValue("prompt")), "consent")
+	state, err := service.CheckConsent(r.Context(), uid, r.FormValue("client_id"), r.FormValue("scope"), force)
+	switch err {
+	case result.InvalidScope:
+		return state, errors.ErrInvalidScope
+	case result.ClientErr:
+		return state, errors.ErrInvalidClient
+	}
+	return state, err
+}
+
+// consentRequired is the response for the frontend to show the consent screen
+func consentRequired(r *http.Request) result.Response {
+	consent, err := service.ConsentOf(r.Context(), r.FormValue("client_id"), r.FormValue("scope"))
+	if err != nil {
+		controllerLogger.Errorln("ConsentOf Err", err)
+		return result.Failed(result.HandleError(err))
+	}
+	return result.FailedWithData(result.ConsentRequired, consent)
+}
@@ -0,0 +1,120 @@
+package v1
+
+import (
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/NJUPT-SAST/sast-link-backend/model"
+	"github.com/NJUPT-SAST/sast-link-backend/model/result"
+	"github.com/NJUPT-SAST/sast-link-backend/service"
+	"github.com/gin-gonic/gin"
+	"github.com/go-oauth2/oauth2/v4"
+)
+
+// DeviceCode is the device authorization endpoint of RFC 8628,
+// the device shows the user code and polls the token endpoint.
+func DeviceCode(c *gin.Context) {
+	client, ok := authenticateClient(c)
+	if !ok {
+		return
+	}
+	deviceCode, userCode, err := service.StartDeviceAuthorization(c, client.ID, c.PostForm("scope"))
+	if err != nil {
+		switch err {
+		case result.PermissionDenied:
+			oauthError(c, http.StatusBadRequest, "unauthorized_client")
+		case result.InvalidScope:
+			oauthError(c, http.StatusBadRequest, "invalid_scope")
+		default:
+			controllerLogger.Errorln("StartDeviceAuthorization Err", err)
+			oauthError(c, http.StatusInternalServerError, "server_error")
+		}
+		return
+	}
+	verificationURI := service.DeviceVerificationURI()
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusOK, gin.H{
+		"device_code":               deviceCode,
+		"user_code":                 userCode,
+		"verification_uri":          verificationURI,
+		"verification_uri_complete": verificationURI + "?user_code=" + url.QueryEscape(userCode),
+		"expires_in":                int64(model.DEVICE_CODE_EXP.Seconds()),
+		"interval":                  int64(model.DEVICE_POLL_INTERVAL.Seconds()),
+	})
+}
+
+// DeviceConsent return the client and scopes of the device waiting for `user_code`
+func DeviceConsent(c *gin.Context) {
+	uid := c.GetString("uid")
+	consent, err := service.DeviceConsent(c, uid, c.Query("user_code"))
+	if err != nil {
+		if respondLocked(c, err) {
+			return
+		}
+		c.JSON(http.StatusOK, result.Failed(result.HandleError(err)))
+		return
+	}
+	c.JSON(http.StatusOK, result.Success(consent))
+}
+
+// DecideDevice approve or deny the device waiting for `user_code`
+func DecideDevice(c *gin.Context) {
+	uid := c.GetString("uid")
+	approved, err := strconv.ParseBool(c.PostForm("approve"))
+	if err != nil {
+		c.JSON(http.StatusOK, result.Failed(result.RequestParamError))
+		return
+	}
+	if err := service.DecideDevice(c, uid, c.PostForm("user_code"), approved); err != nil {
+		if respondLocked(c, err) {
+			return
+		}
+		c.JSON(http.StatusOK, result.Failed(result.HandleError(err)))
+		return
+	}
+	c.JSON(http.StatusOK, result.Success(nil))
+}
+
+// handleDeviceTokenRequest issue tokens to the device once the user approved it,
+// through the manager with the token config of the password grant.
+func handleDeviceTokenRequest(c *gin.Context) {
+	client, ok := authenticateClient(c)
+	if !ok {
+		return
+	}
+	// polling faster than the interval is slow_down, this is the limit of the client
+	if locked, ok := service.CheckClientRate(c, client).(result.LockedError); ok {
+		c.Header("Retry-After", strconv.FormatInt(locked.RetryAfterSeconds(), 10))
+		oauthError(c, http.StatusTooManyRequests, errRateLimited.Error())
+		return
+	}
+	if allowed, err := service.ClientGrantAllowed(c, client.ID, service.DeviceGrantType); err != nil || !allowed {
+		oauthError(c, http.StatusBadRequest, "unauthorized_client")
+		return
+	}
+	auth, err := service.PollDevice(c, client.ID, c.PostForm("device_code"))
+	if err != nil {
+		switch err {
+		case service.ErrAuthorizationPending, service.ErrSlowDown, service.ErrDeviceAccessDenied,
+			service.ErrDeviceCodeExpired, service.ErrDeviceCodeMismatch:
+			oauthError(c, http.StatusBadRequest, err.Error())
+		default:
+			oauthError(c, http.StatusInternalServerError, "server_error")
+		}
+		return
+	}
+	ti, err := srv.Manager.GenerateAccessToken(c, oauth2.PasswordCredentials, &oauth2.TokenGenerateRequest{
+		ClientID: client.ID,
+		// the client is authenticated above, maybe with the previous secret
+		ClientSecret: client.Secret,
+		UserID:       auth.UserID,
+		Scope:        auth.Scope,
+		Request:      c.Request,
+	})
+	if err != nil {
+		tokenError(c.Writer, err, true)
+		return
+	}
+	_ = standardTokenResponse(c.Writer, srv.GetTokenData(ti), nil)
+}
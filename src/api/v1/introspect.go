@@ -0,0 +1,143 @@
+package v1
+
+import (
+	"net/http"
+
+	"github.com/NJUPT-SAST/sast-link-backend/model"
+	"github.com/NJUPT-SAST/sast-link-backend/service"
+	"github.com/gin-gonic/gin"
+	"github.com/go-oauth2/oauth2/v4"
+)
+
+// IntrospectToken is the token introspection endpoint of RFC 7662,
+// any confidential client can introspect tokens for its resource server.
+func IntrospectToken(c *gin.Context) {
+	client, ok := authenticateClient(c)
+	if !ok {
+		return
+	}
+	// public clients have no resource server to protect
+	if client.IsPublic() {
+		oauthError(c, http.StatusUnauthorized, "invalid_client")
+		return
+	}
+	token := c.PostForm("token")
+	if token == "" {
+		oauthError(c, http.StatusBadRequest, "invalid_request")
+		return
+	}
+	ti, isRefresh := loadToken(c, token, c.PostForm("token_type_hint"))
+	c.Header("Cache-Control", "no-store")
+	c.Header("Pragma", "no-cache")
+	if ti == nil {
+		c.JSON(http.StatusOK, gin.H{"active": false})
+		return
+	}
+	createAt, expiresIn := ti.GetAccessCreateAt(), ti.GetAccessExpiresIn()
+	if isRefresh {
+		createAt, expiresIn = ti.GetRefreshCreateAt(), ti.GetRefreshExpiresIn()
+	}
+	data := gin.H{
+		"active":     true,
+		"scope":      ti.GetScope(),
+		"client_id":  ti.GetClientID(),
+		"sub":        ti.GetUserID(),
+		"iat":        createAt.Unix(),
+		"token_type": "Bearer",
+	}
+	// refresh tokens may never expire
+	if expiresIn > 0 {
+		data["exp"] = createAt.Add(expiresIn).Unix()
+	}
+	c.JSON(http.StatusOK, data)
+}
+
+// RevokeToken is the token revocation endpoint of RFC 7009, revoking either
+// token of a grant revokes both since they are saved in the same row.
+func RevokeToken(c *gin.Context) {
+	client, ok := authenticateClient(c)
+	if !ok {
+		return
+	}
+	token := c.PostForm("token")
+	if token == "" {
+		oauthError(c, http.StatusBadRequest, "invalid_request")
+		return
+	}
+	ti, isRefresh := loadToken(c, token, c.PostForm("token_type_hint"))
+	// invalid tokens are not an error, the client has nothing to clean up
+	if ti == nil {
+		c.Status(http.StatusOK)
+		return
+	}
+	if ti.GetClientID() != client.ID {
+		oauthError(c, http.StatusBadRequest, "unauthorized_client")
+		return
+	}
+	var err error
+	if isRefresh {
+		err = srv.Manager.RemoveRefreshToken(c, token)
+	} else {
+		err = srv.Manager.RemoveAccessToken(c, token)
+	}
+	if err != nil {
+		controllerLogger.Errorln("revoke token fail:", err)
+		oauthError(c, http.StatusServiceUnavailable, "server_error")
+		return
+	}
+	c.Status(http.StatusOK)
+}
+
+// authenticateClient check client_secret_basic or client_secret_post credentials,
+// public clients only send client_id. The error response is written if it fails.
+func authenticateClient(c *gin.Context) (*model.OAuthClient, bool) {
+	clientID, secret, basic := c.Request.BasicAuth()
+	if !basic {
+		clientID, secret = c.PostForm("client_id"), c.PostForm("client_secret")
+	}
+	client, err := oauthClient(c, clientID)
+	if err != nil || !service.ClientApproved(client) || !client.VerifyPassword(secret) {
+		if basic {
+			c.Header("WWW-Authenticate", `Basic realm="sast-link"`)
+		}
+		oauthError(c, http.StatusUnauthorized, "invalid_client")
+		return nil, false
+	}
+	return client, true
+}
+
+// loadToken find an active access or refresh token, the hint is tried first
+func loadToken(c *gin.Context, token, hint string) (ti oauth2.TokenInfo, isRefresh bool) {
+	loadAccess := func() oauth2.TokenInfo {
+		ti, err := srv.Manager.LoadAccessToken(c, token)
+		if err != nil {
+			return nil
+		}
+		return ti
+	}
+	loadRefresh := func() oauth2.TokenInfo {
+		ti, err := srv.Manager.LoadRefreshToken(c, token)
+		if err != nil {
+			return nil
+		}
+		return ti
+	}
+	if hint == "refresh_token" {
+		if ti = loadRefresh(); ti != nil {
+			return ti, true
+		}
+		return loadAccess(), false
+	}
+	if ti = loadAccess(); ti != nil {
+		return ti, false
+	}
+	if ti = loadRefresh(); ti != nil {
+		return ti, true
+	}
+	return nil, false
+}
+
+func oauthError(c *gin.Context, status int, code string) {
+	c.Header("Cache-Control", "no-store")
+	c.JSON(status, gin.H{"error": code})
+}
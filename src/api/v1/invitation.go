@@ -0,0 +1,69 @@
+package v1
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/NJUPT-SAST/sast-link-backend/model/result"
+	"github.com/NJUPT-SAST/sast-link-backend/service"
+	"github.com/gin-gonic/gin"
+)
+
+// CreateInvitation generate an invitation code,
+// `maxUses` defaults to 1 and `expiresIn` (hours) defaults to 7 days.
+func CreateInvitation(ctx *gin.Context) {
+	uid := ctx.GetString("uid")
+	maxUses, err := strconv.Atoi(ctx.DefaultPostForm("maxUses", "1"))
+	if err != nil {
+		ctx.JSON(http.StatusOK, result.Failed(result.RequestParamError))
+		return
+	}
+	expiresIn, err := strconv.Atoi(ctx.DefaultPostForm("expiresIn", "168"))
+	if err != nil {
+		ctx.JSON(http.StatusOK, result.Failed(result.RequestParamError))
+		return
+	}
+	var orgId *int
+	if s := ctx.PostForm("orgId"); s != "" {
+		id, err := strconv.Atoi(s)
+		if err != nil {
+			ctx.JSON(http.StatusOK, result.Failed(result.OrgIdError))
+			return
+		}
+		orgId = &id
+	}
+
+	invitation, err := service.CreateInvitation(uid, maxUses, time.Duration(expiresIn)*time.Hour, orgId, ctx.PostForm("note"))
+	if err != nil {
+		controllerLogger.Errorf("create invitation fail: %s", err.Error())
+		ctx.JSON(http.StatusOK, result.Failed(result.HandleError(err)))
+		return
+	}
+	ctx.JSON(http.StatusOK, result.Success(invitation))
+}
+
+// Invitations list invitations and who registered with them
+func Invitations(ctx *gin.Context) {
+	invitations, err := service.Invitations()
+	if err != nil {
+		controllerLogger.Errorf("list invitations fail: %s", err.Error())
+		ctx.JSON(http.StatusOK, result.Failed(result.HandleError(err)))
+		return
+	}
+	ctx.JSON(http.StatusOK, result.Success(invitations))
+}
+
+// ExpireInvitation stop an invitation by `id`
+func ExpireInvitation(ctx *gin.Context) {
+	id, err := strconv.ParseUint(ctx.PostForm("id"), 10, 32)
+	if err != nil {
+		ctx.JSON(http.StatusOK, result.Failed(result.RequestParamError))
+		return
+	}
+	if err := service.ExpireInvitation(uint(id)); err != nil {
+		ctx.JSON(http.StatusOK, result.Failed(result.HandleError(err)))
+		return
+	}
+	ctx.JSON(http.StatusOK, result.Success(nil))
+}
@@ -115,19 +115,10 @@ func OauthGithubCallback(c *gin.Context) {
 		// directly return token
 		uid := userInfo.UserID
 		log.Debugf("User already registered and bounded github: %s", uid)
-		token, err := util.GenerateTokenWithExp(c, model.LoginJWTSubKey(uid), model.LOGIN_TOKEN_EXP)
-		if err != nil {
-			c.JSON(http.StatusOK, result.Failed(result.GenerateToken))
-			return
-		}
-		if err := model.Rdb.Set(c, model.LoginTokenKey(uid), token, model.LOGIN_TOKEN_EXP).Err(); err != nil {
-			log.Errorf("model.Rdb.Set ::: %s", err.Error())
-			c.JSON(http.StatusOK, result.Failed(result.InternalErr))
+		if requireTwoFactor(c, uid) {
 			return
 		}
-		c.JSON(http.StatusOK, result.Success(gin.H{
-			model.LOGIN_TOKEN_SUB: token,
-		}))
+		issueLoginToken(c, uid)
 		return
 	}
 }
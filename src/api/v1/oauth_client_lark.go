@@ -135,15 +135,10 @@ func OauthLarkCallback(c *gin.Context) {
 		// directly return token
 		uid := userLarkInfo.UserID
 		log.Debugf("User already registered and bounded lark: %s", uid)
-		token, err := util.GenerateTokenWithExp(c, model.LoginJWTSubKey(uid), model.LOGIN_TOKEN_EXP)
-		if err != nil {
-			c.JSON(http.StatusOK, result.Failed(result.GenerateToken))
+		if requireTwoFactor(c, uid) {
 			return
 		}
-		model.Rdb.Set(c, model.LoginTokenKey(uid), token, model.LOGIN_TOKEN_EXP)
-		c.JSON(http.StatusOK, result.Success(gin.H{
-			model.LOGIN_TOKEN_SUB: token,
-		}))
+		issueLoginToken(c, uid)
 		return
 	}
 }
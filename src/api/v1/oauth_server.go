@@ -4,6 +4,7 @@ import (
 	"context"
 	"encoding/json"
 	"net/http"
+	"strconv"
 	"strings"
 	"time"
 
@@ -12,11 +13,11 @@ import (
 	"github.com/NJUPT-SAST/sast-link-backend/model"
 	"github.com/NJUPT-SAST/sast-link-backend/model/result"
 	"github.com/NJUPT-SAST/sast-link-backend/service"
-	"github.com/NJUPT-SAST/sast-link-backend/util"
 	"github.com/gin-gonic/gin"
+	"github.com/go-oauth2/oauth2/v4"
 	"github.com/go-oauth2/oauth2/v4/errors"
+	"github.com/go-oauth2/oauth2/v4/generates"
 	"github.com/go-oauth2/oauth2/v4/manage"
-	"github.com/go-oauth2/oauth2/v4/models"
 	"github.com/go-oauth2/oauth2/v4/server"
 	"github.com/jackc/pgx/v4/pgxpool"
 	"github.com/sirupsen/logrus"
@@ -25,12 +26,13 @@ import (
 )
 
 var (
-	srv            *server.Server
-	pgxConn, _     = pgxpool.Connect(context.Background(), config.Config.Sub("oauth.server").GetString("db_uri"))
-	tokenAdapter   = pgx4adapter.NewPool(pgxConn)
-	tokenStore, _  = pg.NewTokenStore(tokenAdapter, pg.WithTokenStoreGCInterval(time.Minute))
-	clientAdapter  = pgx4adapter.NewPool(pgxConn)
-	clientStore, _ = pg.NewClientStore(clientAdapter)
+	srv           *server.Server
+	pgxConn, _    = pgxpool.Connect(context.Background(), config.Config.Sub("oauth.server").GetString("db_uri"))
+	tokenAdapter  = pgx4adapter.NewPool(pgxConn)
+	tokenStore, _ = pg.NewTokenStore(tokenAdapter, pg.WithTokenStoreGCInterval(time.Minute))
+	clientStore   = model.OAuthClientStore{}
+	// RFC 6749 has no error for rate limits, it is responded with 429
+	errRateLimited = errors.New("rate_limit_exceeded")
 )
 
 // ClientStoreItem data item
@@ -49,12 +51,32 @@ func InitServer() {
 	mg := manage.NewDefaultManager()
 	mg.MapTokenStorage(tokenStore)
 	mg.SetAuthorizeCodeTokenCfg(manage.DefaultAuthorizeCodeTokenCfg)
-	// use PostgreSQL client store with pgx.Connection adapter
+	// every refresh issues a new refresh token and removes the old one,
+	// the lifetime slides from the latest refresh
+	mg.SetRefreshTokenCfg(&manage.RefreshingConfig{
+		IsGenerateRefresh:  true,
+		IsRemoveAccess:     true,
+		IsRemoveRefreshing: true,
+		IsResetRefreshTime: true,
+	})
+	// clients are saved in the oauth2_clients table of go-oauth2-pg with metadata
 	mg.MapClientStorage(clientStore)
+	// redirect URIs are validated by clientManager
+	mg.SetValidateURIHandler(func(baseURI, redirectURI string) error { return nil })
+	var accessGenerate oauth2.AccessGenerate = generates.NewAccessGenerate()
+	if service.JWTAccessToken() {
+		accessGenerate = &service.JWTAccessGenerate{}
+	}
+	mg.MapAccessGenerate(clientAccessGenerate{accessGenerate})
 
-	srv = server.NewServer(server.NewConfig(), mg)
+	// go-oauth2 takes a missing code_challenge_method as plain even without PKCE,
+	// the method is checked by clientManager instead of the config
+	srv = server.NewServer(server.NewConfig(), &clientManager{mg})
 	srv.SetClientInfoHandler(clientInfoHandler)
+	srv.SetClientAuthorizedHandler(clientAuthorizedHandler)
 	srv.SetUserAuthorizationHandler(userAuthorizeHandler)
+	srv.SetClientScopeHandler(clientScopeHandler)
+	srv.SetRefreshingScopeHandler(refreshingScopeHandler)
 	// TODO: error handler
 	srv.SetInternalErrorHandler(InternalErrorHandler)
 	srv.SetResponseErrorHandler(ResponseErrorHandler)
@@ -62,7 +84,117 @@ func InitServer() {
 	srv.SetResponseTokenHandler(ResponseTokenHandler)
 }
 
+// clientManager check redirect URIs against all the URIs registered by the client,
+// the validate handler of go-oauth2 only knows the client domain.
+// It also requires PKCE for the clients that need it, and authenticates the client
+// on refreshing which go-oauth2 only does for the other grants.
+type clientManager struct {
+	*manage.Manager
+}
+
+func (m *clientManager) GenerateAuthToken(ctx context.Context, rt oauth2.ResponseType, tgr *oauth2.TokenGenerateRequest) (oauth2.TokenInfo, error) {
+	if err := checkRedirectURI(ctx, tgr.ClientID, tgr.RedirectURI); err != nil {
+		return nil, err
+	}
+	client, err := oauthClient(ctx, tgr.ClientID)
+	if err != nil {
+		return nil, err
+	}
+	if err := service.CheckCodeChallenge(client, tgr.CodeChallenge, tgr.CodeChallengeMethod); err != nil {
+		return nil, err
+	}
+	return m.Manager.GenerateAuthToken(ctx, rt, tgr)
+}
+
+func (m *clientManager) GenerateAccessToken(ctx context.Context, gt oauth2.GrantType, tgr *oauth2.TokenGenerateRequest) (oauth2.TokenInfo, error) {
+	if err := checkRedirectURI(ctx, tgr.ClientID, tgr.RedirectURI); err != nil {
+		return nil, err
+	}
+	return m.Manager.GenerateAccessToken(ctx, gt, tgr)
+}
+
+func (m *clientManager) RefreshAccessToken(ctx context.Context, tgr *oauth2.TokenGenerateRequest) (oauth2.TokenInfo, error) {
+	ti, err := m.Manager.LoadRefreshToken(ctx, tgr.Refresh)
+	if err != nil {
+		return nil, err
+	}
+	if ti.GetClientID() != tgr.ClientID {
+		return nil, errors.ErrInvalidGrant
+	}
+	client, err := oauthClient(ctx, tgr.ClientID)
+	if err != nil {
+		return nil, err
+	}
+	// public clients have an empty secret
+	if !client.VerifyPassword(tgr.ClientSecret) {
+		return nil, errors.ErrInvalidClient
+	}
+	return m.Manager.RefreshAccessToken(ctx, tgr)
+}
+
+func checkRedirectURI(ctx context.Context, clientID, redirectURI string) error {
+	if redirectURI == "" {
+		return nil
+	}
+	client, err := oauthClient(ctx, clientID)
+	if err != nil {
+		return err
+	}
+	if !service.ValidRedirectURI(client, redirectURI) {
+		return errors.ErrInvalidRedirectURI
+	}
+	return nil
+}
+
+// clientAccessGenerate apply the token lifetimes of the client policy for every grant
+// and refreshing, before the token is generated since JWT access tokens carry `exp`.
+type clientAccessGenerate struct {
+	oauth2.AccessGenerate
+}
+
+func (g clientAccessGenerate) Token(ctx context.Context, data *oauth2.GenerateBasic, isGenRefresh bool) (string, string, error) {
+	if client, ok := data.Client.(*model.OAuthClient); ok {
+		if ttl := service.ClientAccessTokenTTL(client); ttl > 0 {
+			data.TokenInfo.SetAccessExpiresIn(ttl)
+		}
+		if ttl := service.ClientRefreshTokenTTL(client); ttl > 0 && isGenRefresh {
+			data.TokenInfo.SetRefreshExpiresIn(ttl)
+		}
+	}
+	return g.AccessGenerate.Token(ctx, data, isGenRefresh)
+}
+
+// checkClientRate count a request of clientID to the token or userinfo endpoint,
+// unknown clients are left to the endpoint to reject.
+func checkClientRate(ctx context.Context, clientID string) error {
+	client, err := oauthClient(ctx, clientID)
+	if err != nil {
+		return nil
+	}
+	return service.CheckClientRate(ctx, client)
+}
+
+// oauthClient return the client with metadata from the client store
+func oauthClient(ctx context.Context, clientID string) (*model.OAuthClient, error) {
+	cli, err := srv.Manager.GetClient(ctx, clientID)
+	if err != nil {
+		return nil, err
+	}
+	client, ok := cli.(*model.OAuthClient)
+	if !ok {
+		return nil, errors.ErrInvalidClient
+	}
+	return client, nil
+}
+
 func InternalErrorHandler(err error) (re *errors.Response) {
+	// clients over their rate limit
+	if locked, ok := err.(result.LockedError); ok {
+		re = errors.NewResponse(errRateLimited, http.StatusTooManyRequests)
+		re.Description = result.TooManyAttempts.ErrMsg
+		re.Header = http.Header{"Retry-After": {strconv.FormatInt(locked.RetryAfterSeconds(), 10)}}
+		return re
+	}
 	log.Log.Errorf("Oauth2 ::: InternalErrorHandler:[%s]", err.Error())
 	error := errors.NewResponse(err, http.StatusInternalServerError)
 	error.ErrorCode = 500
@@ -109,42 +241,152 @@ func ResponseTokenHandler(w http.ResponseWriter, data map[string]interface{}, he
 
 // Create client
 func CreateClient(c *gin.Context) {
-	redirectURI := c.PostForm("redirect_uri")
-	if redirectURI == "" {
-		c.JSON(http.StatusBadRequest, result.Failed(result.RequestParamError))
+	uid := c.GetString("uid")
+	client, err := service.CreateClient(uid, clientMetadata(c))
+	if err != nil {
+		c.JSON(http.StatusOK, result.Failed(result.HandleError(err)))
 		return
 	}
+	view := clientView(client)
+	if !client.Public {
+		view["client_secret"] = client.Secret
+	}
+	c.JSON(http.StatusOK, result.Success(view))
+}
 
-	token := c.GetHeader("TOKEN")
-	uid, err := util.GetUsername(token, model.LOGIN_TOKEN_SUB)
-	if err != nil || uid == "" {
-		c.JSON(http.StatusOK, result.Failed(result.TokenError))
+// Clients list the clients created by the user, secrets are not included
+func Clients(c *gin.Context) {
+	uid := c.GetString("uid")
+	clients, err := service.Clients(uid)
+	if err != nil {
+		c.JSON(http.StatusOK, result.Failed(result.HandleError(err)))
 		return
 	}
+	list := make([]gin.H, 0, len(clients))
+	for i := range clients {
+		list = append(list, clientView(&clients[i]))
+	}
+	c.JSON(http.StatusOK, result.Success(list))
+}
 
-	clientID := util.GenerateUUID()
-	secret, err := util.GenerateRandomString(32)
+// UpdateClient replace the metadata of a client with the form
+func UpdateClient(c *gin.Context) {
+	uid := c.GetString("uid")
+	client, err := service.UpdateClient(c, uid, c.PostForm("client_id"), clientMetadata(c))
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, result.Failed(result.InternalErr))
+		c.JSON(http.StatusOK, result.Failed(result.HandleError(err)))
 		return
 	}
+	c.JSON(http.StatusOK, result.Success(clientView(client)))
+}
 
-	cErr := clientStore.Create(&models.Client{
-		ID:     clientID,
-		Secret: secret,
-		Domain: redirectURI,
-		UserID: uid,
+// RotateClientSecret generate a new client secret, the old one is
+// still accepted for `overlap` seconds so deployments can switch.
+func RotateClientSecret(c *gin.Context) {
+	uid := c.GetString("uid")
+	overlap, err := strconv.Atoi(c.DefaultPostForm("overlap", "0"))
+	if err != nil {
+		c.JSON(http.StatusOK, result.Failed(result.RequestParamError))
+		return
+	}
+	client, err := service.RotateClientSecret(c, uid, c.PostForm("client_id"), time.Duration(overlap)*time.Second)
+	if err != nil {
+		c.JSON(http.StatusOK, result.Failed(result.HandleError(err)))
+		return
+	}
+	view := clientView(client)
+	view["client_secret"] = client.Secret
+	c.JSON(http.StatusOK, result.Success(view))
+}
+
+// SetClientPolicy set the token lifetimes and rate limit of any client by `client_id`,
+// `access_token_ttl` and `refresh_token_ttl` are in seconds, 0 for the default of the grant,
+// and `rate_limit` is requests per minute, 0 for `oauth.server.rate_limit`.
+func SetClientPolicy(c *gin.Context) {
+	accessTokenTTL, err := strconv.Atoi(c.DefaultPostForm("access_token_ttl", "0"))
+	if err != nil {
+		c.JSON(http.StatusOK, result.Failed(result.RequestParamError))
+		return
+	}
+	refreshTokenTTL, err := strconv.Atoi(c.DefaultPostForm("refresh_token_ttl", "0"))
+	if err != nil {
+		c.JSON(http.StatusOK, result.Failed(result.RequestParamError))
+		return
+	}
+	rateLimit, err := strconv.ParseInt(c.DefaultPostForm("rate_limit", "0"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusOK, result.Failed(result.RequestParamError))
+		return
+	}
+	client, err := service.SetClientPolicy(c, c.GetString("uid"), c.PostForm("client_id"), &service.ClientPolicy{
+		AccessTokenTTL:  time.Duration(accessTokenTTL) * time.Second,
+		RefreshTokenTTL: time.Duration(refreshTokenTTL) * time.Second,
+		RateLimit:       rateLimit,
 	})
+	if err != nil {
+		c.JSON(http.StatusOK, result.Failed(result.HandleError(err)))
+		return
+	}
+	c.JSON(http.StatusOK, result.Success(clientView(client)))
+}
 
-	if cErr != nil {
-		c.JSON(http.StatusBadRequest, result.Failed(result.InternalErr))
+// DeleteClient delete a client and revoke its tokens
+func DeleteClient(c *gin.Context) {
+	uid := c.GetString("uid")
+	if err := service.DeleteClient(c, uid, c.PostForm("client_id")); err != nil {
+		c.JSON(http.StatusOK, result.Failed(result.HandleError(err)))
 		return
 	}
+	c.JSON(http.StatusOK, result.Success(nil))
+}
+
+// clientMetadata read the metadata of a client from the form,
+// `redirect_uri`, `post_logout_redirect_uri` and `grant_type` can be repeated,
+// `loopback_redirect` allows any port on loopback redirect URIs for native apps,
+// and `scope` is space separated.
+func clientMetadata(c *gin.Context) *service.ClientMetadata {
+	public, _ := strconv.ParseBool(c.PostForm("public"))
+	requirePKCE, _ := strconv.ParseBool(c.PostForm("require_pkce"))
+	loopbackRedirect, _ := strconv.ParseBool(c.PostForm("loopback_redirect"))
+	return &service.ClientMetadata{
+		Name:                   c.PostForm("name"),
+		Description:            c.PostForm("description"),
+		Logo:                   c.PostForm("logo"),
+		Homepage:               c.PostForm("homepage"),
+		RedirectURIs:           c.PostFormArray("redirect_uri"),
+		GrantTypes:             c.PostFormArray("grant_type"),
+		Scopes:                 strings.Fields(c.PostForm("scope")),
+		RequirePKCE:            requirePKCE,
+		LoopbackRedirect:       loopbackRedirect,
+		PostLogoutRedirectURIs: c.PostFormArray("post_logout_redirect_uri"),
+		BackchannelLogoutURI:   c.PostForm("backchannel_logout_uri"),
+		Public:                 public,
+	}
+}
 
-	c.JSON(http.StatusOK, result.Success(gin.H{
-		"client_id":     clientID,
-		"client_secret": secret,
-	}))
+func clientView(client *model.OAuthClient) gin.H {
+	return gin.H{
+		"client_id":                  client.ID,
+		"name":                       client.Name,
+		"description":                client.Description,
+		"logo":                       client.Logo,
+		"homepage":                   client.Homepage,
+		"redirect_uris":              service.ClientRedirectURIs(client),
+		"grant_types":                client.GrantTypes,
+		"scopes":                     service.ClientScopes(client),
+		"public":                     client.Public,
+		"require_pkce":               service.PKCERequired(client),
+		"loopback_redirect":          client.LoopbackRedirect,
+		"post_logout_redirect_uris":  client.PostLogoutRedirectURIs,
+		"backchannel_logout_uri":     client.BackchannelLogoutURI,
+		"access_token_ttl":           client.AccessTokenTTL,
+		"refresh_token_ttl":          client.RefreshTokenTTL,
+		"rate_limit":                 client.RateLimit,
+		"status":                     client.Status,
+		"previous_secret_expires_at": client.PreviousSecretExpiresAt,
+		"created_at":                 client.CreatedAt,
+		"updated_at":                 client.UpdatedAt,
+	}
 }
 
 func OauthUserInfo(c *gin.Context) {
@@ -158,55 +400,42 @@ func OauthUserInfo(c *gin.Context) {
 	accessToken := strings.Split(bearerToken, " ")[1]
 	mg := srv.Manager
 	ti, err := mg.LoadAccessToken(c, accessToken)
-	if err != nil {
+	// tokens of service accounts have no user, they use the directory instead
+	if err != nil || ti.GetUserID() == "" {
 		c.JSON(http.StatusOK, result.Failed(result.AccessTokenErr))
 		return
 	}
-	// TODO: scope check
-	ti.GetScope()
+	if err := checkClientRate(c, ti.GetClientID()); err != nil {
+		respondLocked(c, err)
+		return
+	}
+	service.TouchApp(ti.GetUserID(), ti.GetClientID())
 
-	user, err := service.OauthUserInfo(ti.GetUserID())
+	claims, err := service.OauthUserClaims(ti.GetUserID(), ti.GetScope())
 	if err != nil {
 		controllerLogger.WithFields(
 			logrus.Fields{
-				"username": user.Uid,
+				"username": ti.GetUserID(),
 			}).Error(err)
-		c.JSON(http.StatusOK, result.Failed(result.GetUserinfoFail))
-		return
-	}
-
-	profileInfo, serErr := service.GetProfileInfo(*user.Uid)
-	if serErr != nil {
-		controllerLogger.Errorln("GetProfile service wrong", serErr)
-		c.JSON(http.StatusOK, result.Failed(result.HandleError(serErr)))
-		return
-	}
-
-	if dep, org, getOrgErr := service.GetProfileOrg(profileInfo.OrgId); getOrgErr != nil {
-		controllerLogger.Errorln("GetProfileOrg Err", getOrgErr)
-		c.JSON(http.StatusOK, result.Failed(result.HandleError(getOrgErr)))
-		return
-	} else {
-		c.JSON(http.StatusOK, result.Success(gin.H{
-			"nickname": profileInfo.Nickname,
-			"userId":   user.Uid,
-			"dep":      dep,
-			"org":      org,
-			"email":    profileInfo.Email,
-			"avatar":   profileInfo.Avatar,
-			"bio":      profileInfo.Bio,
-			"link":     profileInfo.Link,
-			"badge":    profileInfo.Badge,
-			"hide":     profileInfo.Hide,
-		}))
+		c.JSON(http.StatusOK, result.Failed(result.HandleErrorWithArgu(err, result.GetUserinfoFail)))
 		return
 	}
+	c.JSON(http.StatusOK, result.Success(claims))
 }
 
 func Authorize(c *gin.Context) {
 	r := c.Request
 	w := c.Writer
 	_ = r.ParseForm()
+	if service.HasScope(r.FormValue("scope"), "openid") {
+		authorizeOIDC(c)
+		return
+	}
+	redirectURI, ok := authorizeRedirectURI(c, r.FormValue("client_id"), r.FormValue("redirect_uri"))
+	if !ok {
+		return
+	}
+	r.Form.Set("redirect_uri", redirectURI)
 	// Redirect user to login page if user not login or
 	// Get code directly if user has logged in
 	err := srv.HandleAuthorizeRequest(w, r)
@@ -216,62 +445,111 @@ func Authorize(c *gin.Context) {
 	}
 }
 
-// Get AccessToken
-func AccessToken(c *gin.Context) {
-	w := c.Writer
-	r := c.Request
-	err := srv.HandleTokenRequest(w, r)
-
-	// FIXME: err is always nil
+// authorizeRedirectURI resolve the redirect URI of an authorization request,
+// an unregistered one gets an error page since errors are only redirected
+// to the registered redirect URIs.
+func authorizeRedirectURI(c *gin.Context, clientID, redirectURI string) (string, bool) {
+	client, err := oauthClient(c, clientID)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, result.Failed(result.InternalErr.Wrap(err)))
-		return
+		c.JSON(http.StatusOK, result.Failed(result.ClientErr))
+		return "", false
 	}
+	uri, err := service.ResolveRedirectURI(client, redirectURI)
+	if err != nil {
+		c.JSON(http.StatusOK, result.Failed(result.HandleError(err)))
+		return "", false
+	}
+	return uri, true
+}
+
+// Get AccessToken
+func AccessToken(c *gin.Context) {
+	handleTokenRequest(c)
 }
 
 // Refresh AccessToken
 func RefreshToken(c *gin.Context) {
-	w := c.Writer
-	r := c.Request
-	err := srv.HandleTokenRequest(w, r)
-	if err == nil {
-		c.JSON(http.StatusInternalServerError, result.Failed(result.InternalErr.Wrap(err)))
-		return
-	}
+	handleTokenRequest(c)
 }
 
+// clientInfoHandler identify the client of a token request and apply its rate limit
 func clientInfoHandler(r *http.Request) (clientID, clientSecret string, err error) {
+	if clientID, clientSecret, err = requestClient(r); err != nil {
+		return "", "", err
+	}
+	if err := checkClientRate(r.Context(), clientID); err != nil {
+		return "", "", err
+	}
+	return clientID, clientSecret, nil
+}
+
+func requestClient(r *http.Request) (clientID, clientSecret string, err error) {
 	_ = r.ParseMultipartForm(0)
 	_ = r.ParseForm()
-	if r.Form.Get("grant_type") == "refresh_token" {
+	// client_secret_basic
+	if clientID, clientSecret, ok := r.BasicAuth(); ok {
+		return clientID, clientSecret, nil
+	}
+	clientID = r.Form.Get("client_id")
+	if clientID == "" && r.Form.Get("grant_type") == "refresh_token" {
+		// public clients may refresh with the token alone, confidential ones
+		// still have to authenticate below (RFC 6749 section 6)
 		ti, err := srv.Manager.LoadRefreshToken(r.Context(), r.Form.Get("refresh_token"))
 		if err != nil {
 			return "", "", result.RefreshTokenErr
 		}
 		clientID = ti.GetClientID()
-		if clientID == "" {
-			return "", "", result.ClientErr
-		}
-		cli, err := srv.Manager.GetClient(r.Context(), clientID)
-		if err != nil {
-			return "", "", result.ClientErr
-		}
-		clientSecret = cli.GetSecret()
-		if clientSecret == "" {
-			return "", "", result.ClientErr
-		}
-		return clientID, clientSecret, nil
 	}
-	clientID = r.Form.Get("client_id")
 	if clientID == "" {
 		return "", "", result.ClientErr
 	}
 	clientSecret = r.Form.Get("client_secret")
 	if clientSecret == "" {
-		return "", "", result.ClientErr
+		// public clients have no secret, their codes are protected by PKCE
+		cli, err := srv.Manager.GetClient(r.Context(), clientID)
+		if err != nil || !cli.IsPublic() {
+			return "", "", result.ClientErr
+		}
 	}
 	return clientID, clientSecret, nil
+}
 
+// clientAuthorizedHandler reject grant types not allowed for the client
+func clientAuthorizedHandler(clientID string, grant oauth2.GrantType) (allowed bool, err error) {
+	allowed, err = service.ClientGrantAllowed(context.Background(), clientID, grant.String())
+	if err == result.ClientErr {
+		return false, errors.ErrInvalidClient
+	}
+	return allowed, err
+}
+
+// clientScopeHandler reject scopes not registered or not allowed for the client,
+// tgr.Scope is set to the granted scope when it is empty.
+// Requests without a user are client_credentials of service accounts.
+func clientScopeHandler(tgr *oauth2.TokenGenerateRequest) (allowed bool, err error) {
+	ctx := context.Background()
+	if tgr.Request != nil {
+		ctx = tgr.Request.Context()
+	}
+	var scope string
+	if tgr.UserID == "" {
+		scope, err = service.GrantedServiceScope(tgr.ClientID, tgr.Scope)
+	} else {
+		scope, err = service.GrantedScope(ctx, tgr.ClientID, tgr.Scope)
+	}
+	if err != nil {
+		if err == result.InvalidScope {
+			return false, nil
+		}
+		return false, err
+	}
+	tgr.Scope = scope
+	return true, nil
+}
+
+// refreshingScopeHandler only allow narrowing the scope of the refresh token
+func refreshingScopeHandler(tgr *oauth2.TokenGenerateRequest, oldScope string) (allowed bool, err error) {
+	return service.ScopeAllowed(tgr.Scope, strings.Fields(oldScope)), nil
 }
 
 func getTokenByUUID(c context.Context, uuid string) (token string, err error) {
@@ -300,7 +578,7 @@ func userAuthorizeHandler(w http.ResponseWriter, r *http.Request) (userID string
 		return
 	}
 
-	username, err := util.IdentityFromToken(token, model.LOGIN_TOKEN_SUB)
+	username, _, err := service.CheckLoginToken(r.Context(), token)
 	log.Log.Println("Oauth2 ::: username: ", username)
 	if err != nil || username == "" {
 		if r.Form == nil {
@@ -315,30 +593,18 @@ func userAuthorizeHandler(w http.ResponseWriter, r *http.Request) (userID string
 		return
 	}
 
-	rToken, err := model.Rdb.Get(r.Context(), model.LoginTokenKey(username)).Result()
+	state, err := checkConsent(r, username)
 	if err != nil {
-		if r.Form == nil {
-			_ = r.ParseForm()
-		}
-
-		w.Header().Set("Content-Type", "application/json")
-		response := result.Failed(result.TokenError)
-		log.Log.Errorln("Oauth2 ::: token is invalid")
-		json, _ := json.Marshal(response)
-		w.Write(json)
-		return
+		return "", err
 	}
-	if rToken != token {
-		if r.Form == nil {
-			_ = r.ParseForm()
-		}
-
+	switch state {
+	case service.ConsentDenied:
+		return "", errors.ErrAccessDenied
+	case service.ConsentRequired:
 		w.Header().Set("Content-Type", "application/json")
-		response := result.Failed(result.TokenError)
-		log.Log.Errorln("Oauth2 ::: token is invalid")
-		json, _ := json.Marshal(response)
+		json, _ := json.Marshal(consentRequired(r))
 		w.Write(json)
-		return
+		return "", nil
 	}
 	return username, nil
 }
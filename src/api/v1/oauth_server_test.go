@@ -0,0 +1,61 @@
+package v1
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/NJUPT-SAST/sast-link-backend/model"
+	"github.com/NJUPT-SAST/sast-link-backend/util"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestAuthorizePKCE run authorization requests through srv and clientManager
+func TestAuthorizePKCE(t *testing.T) {
+	redirectURI := "https://app.example.org/callback"
+	confidential := &model.OAuthClient{ID: util.GenerateUUID(), Secret: "secret", Domain: redirectURI, UserID: "b21010101", RedirectURIs: []string{redirectURI}}
+	public := &model.OAuthClient{ID: util.GenerateUUID(), Domain: redirectURI, Public: true, UserID: "b21010101", RedirectURIs: []string{redirectURI}}
+	for _, client := range []*model.OAuthClient{confidential, public} {
+		if err := model.CreateOAuthClient(client); err != nil {
+			t.Fatal(err)
+		}
+		defer model.DeleteOAuthClient(client.ID)
+	}
+
+	verifier := "dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk"
+	sum := sha256.Sum256([]byte(verifier))
+	challenge := base64.RawURLEncoding.EncodeToString(sum[:])
+	cases := []struct {
+		name   string
+		client *model.OAuthClient
+		pkce   url.Values
+		ok     bool
+	}{
+		{"confidential without PKCE", confidential, url.Values{}, true},
+		{"confidential with S256", confidential, url.Values{"code_challenge": {challenge}, "code_challenge_method": {"S256"}}, true},
+		{"confidential with plain", confidential, url.Values{"code_challenge": {verifier}, "code_challenge_method": {"plain"}}, false},
+		{"challenge without method is plain", confidential, url.Values{"code_challenge": {verifier}}, false},
+		{"public without PKCE", public, url.Values{}, false},
+		{"public with S256", public, url.Values{"code_challenge": {challenge}, "code_challenge_method": {"S256"}}, true},
+	}
+	for _, c := range cases {
+		query := url.Values{
+			"response_type": {"code"},
+			"client_id":     {c.client.ID},
+			"redirect_uri":  {redirectURI},
+		}
+		for k, v := range c.pkce {
+			query[k] = v
+		}
+		r := httptest.NewRequest("GET", "/api/v1/oauth2/authorize?"+query.Encode(), nil)
+		req, err := srv.ValidationAuthorizeRequest(r)
+		if !assert.NoError(t, err, c.name) {
+			continue
+		}
+		req.UserID = "b21010101"
+		_, err = srv.GetAuthorizeToken(r.Context(), req)
+		assert.Equal(t, c.ok, err == nil, "%s: %v", c.name, err)
+	}
+}
@@ -0,0 +1,394 @@
+package v1
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/NJUPT-SAST/sast-link-backend/config"
+	"github.com/NJUPT-SAST/sast-link-backend/model"
+	"github.com/NJUPT-SAST/sast-link-backend/model/result"
+	"github.com/NJUPT-SAST/sast-link-backend/service"
+	"github.com/gin-gonic/gin"
+	"github.com/go-oauth2/oauth2/v4"
+	"github.com/go-oauth2/oauth2/v4/errors"
+	"github.com/go-oauth2/oauth2/v4/server"
+)
+
+// prompt=login is satisfied by a login within this time
+const promptLoginMaxAge = 2 * time.Minute
+
+// OpenIDConfiguration serve the discovery document of OpenID Connect
+func OpenIDConfiguration(c *gin.Context) {
+	alg, err := service.OIDCSigningAlg()
+	if err != nil {
+		controllerLogger.Errorln("load ID token key fail:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "server_error"})
+		return
+	}
+	issuer := service.OIDCIssuer()
+	authorizationEndpoint := config.Config.GetString("oidc.authorization_endpoint")
+	if authorizationEndpoint == "" {
+		authorizationEndpoint = issuer + "/api/v1/oauth2/authorize"
+	}
+	endSessionEndpoint := config.Config.GetString("oidc.end_session_endpoint")
+	if endSessionEndpoint == "" {
+		endSessionEndpoint = issuer + "/api/v1/oauth2/logout"
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"issuer":                                issuer,
+		"authorization_endpoint":                authorizationEndpoint,
+		"token_endpoint":                        issuer + "/api/v1/oauth2/token",
+		"userinfo_endpoint":                     issuer + "/api/v1/oauth2/oidc/userinfo",
+		"jwks_uri":                              issuer + "/api/v1/oauth2/jwks",
+		"introspection_endpoint":                issuer + "/api/v1/oauth2/introspect",
+		"revocation_endpoint":                   issuer + "/api/v1/oauth2/revoke",
+		"device_authorization_endpoint":         issuer + "/api/v1/oauth2/device/code",
+		"registration_endpoint":                 issuer + "/api/v1/oauth2/register",
+		"end_session_endpoint":                  endSessionEndpoint,
+		"backchannel_logout_supported":          true,
+		"backchannel_logout_session_supported":  true,
+		"scopes_supported":                      service.ScopeNames(),
+		"response_types_supported":              []string{"code"},
+		"grant_types_supported":                 []string{"authorization_code", "refresh_token", service.DeviceGrantType, service.ClientCredentialsGrantType},
+		"subject_types_supported":               []string{"public"},
+		"id_token_signing_alg_values_supported": []string{alg},
+		"token_endpoint_auth_methods_supported": []string{"client_secret_basic", "client_secret_post", "none"},
+		"code_challenge_methods_supported":      []string{"S256"},
+		"claims_supported": []string{
+			"iss", "sub", "aud", "exp", "iat", "auth_time", "nonce", "at_hash", "sid",
+			"name", "nickname", "preferred_username", "picture", "email", "email_verified",
+			"dep", "org", "badge",
+		},
+	})
+}
+
+// JWKS serve the public keys of ID tokens and JWT access tokens
+func JWKS(c *gin.Context) {
+	keys, err := service.JWKS()
+	if err != nil {
+		controllerLogger.Errorln("load signing keys fail:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "server_error"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"keys": keys})
+}
+
+// OIDCUserInfo is the standard UserInfo endpoint,
+// the claims follow the scope of the access token.
+func OIDCUserInfo(c *gin.Context) {
+	accessToken, ok := srv.BearerAuth(c.Request)
+	if !ok {
+		c.Header("WWW-Authenticate", `Bearer error="invalid_request"`)
+		c.Status(http.StatusUnauthorized)
+		return
+	}
+	ti, err := srv.Manager.LoadAccessToken(c, accessToken)
+	if err != nil {
+		c.Header("WWW-Authenticate", `Bearer error="invalid_token"`)
+		c.Status(http.StatusUnauthorized)
+		return
+	}
+	// tokens of service accounts have no user
+	if ti.GetUserID() == "" {
+		c.Header("WWW-Authenticate", `Bearer error="invalid_token"`)
+		c.Status(http.StatusUnauthorized)
+		return
+	}
+	if !service.HasScope(ti.GetScope(), "openid") {
+		c.Header("WWW-Authenticate", `Bearer error="insufficient_scope", scope="openid"`)
+		c.Status(http.StatusForbidden)
+		return
+	}
+	if locked, ok := checkClientRate(c, ti.GetClientID()).(result.LockedError); ok {
+		c.Header("Retry-After", strconv.FormatInt(locked.RetryAfterSeconds(), 10))
+		c.Status(http.StatusTooManyRequests)
+		return
+	}
+	service.TouchApp(ti.GetUserID(), ti.GetClientID())
+	claims, err := service.UserClaims(ti.GetUserID(), ti.GetScope())
+	if err != nil {
+		controllerLogger.Errorln("UserClaims Err", err)
+		c.Header("WWW-Authenticate", `Bearer error="invalid_token"`)
+		c.Status(http.StatusUnauthorized)
+		return
+	}
+	c.JSON(http.StatusOK, claims)
+}
+
+// authorizeOIDC handle authorization requests with `openid` scope,
+// it supports `nonce`, `max_age` and `prompt` parameters on top of OAuth.
+func authorizeOIDC(c *gin.Context) {
+	r := c.Request
+	req, err := srv.ValidationAuthorizeRequest(r)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, result.Failed(result.HandleErrorWithArgu(err, result.InternalErr)))
+		return
+	}
+	redirectURI, ok := authorizeRedirectURI(c, req.ClientID, req.RedirectURI)
+	if !ok {
+		return
+	}
+	req.RedirectURI = redirectURI
+
+	if req.ResponseType != oauth2.Code {
+		redirectOIDCError(c, req, "unsupported_response_type", "only the code flow is supported")
+		return
+	}
+	prompts := strings.Fields(r.FormValue("prompt"))
+	promptNone := hasValue(prompts, "none")
+	if promptNone && len(prompts) > 1 {
+		redirectOIDCError(c, req, "invalid_request", "prompt none can not be combined with other values")
+		return
+	}
+	maxAge := time.Duration(-1)
+	if s := r.FormValue("max_age"); s != "" {
+		seconds, err := strconv.Atoi(s)
+		if err != nil || seconds < 0 {
+			redirectOIDCError(c, req, "invalid_request", "invalid max_age")
+			return
+		}
+		maxAge = time.Duration(seconds) * time.Second
+	}
+	if hasValue(prompts, "login") && (maxAge < 0 || maxAge > promptLoginMaxAge) {
+		maxAge = promptLoginMaxAge
+	}
+
+	uid, sid, err := service.CheckLoginToken(c, r.FormValue("part"))
+	if err != nil {
+		if promptNone {
+			redirectOIDCError(c, req, "login_required", "")
+			return
+		}
+		controllerLogger.Errorln("Oauth2 ::: token is invalid")
+		c.JSON(http.StatusOK, result.Failed(result.TokenError))
+		return
+	}
+	authTime, err := service.AuthTime(c, sid)
+	if err != nil {
+		c.JSON(http.StatusOK, result.Failed(result.HandleError(err)))
+		return
+	}
+	if maxAge >= 0 && time.Since(authTime) > maxAge {
+		if promptNone {
+			redirectOIDCError(c, req, "login_required", "")
+			return
+		}
+		// frontend should log in again and retry with the new token
+		c.JSON(http.StatusOK, result.Failed(result.ReauthRequired))
+		return
+	}
+
+	state, err := checkConsent(r, uid)
+	if err != nil {
+		data, _, _ := srv.GetErrorData(err)
+		redirectOIDC(c, req, data)
+		return
+	}
+	switch state {
+	case service.ConsentDenied:
+		redirectOIDCError(c, req, "access_denied", "")
+		return
+	case service.ConsentRequired:
+		if promptNone {
+			redirectOIDCError(c, req, "consent_required", "")
+			return
+		}
+		c.JSON(http.StatusOK, consentRequired(r))
+		return
+	}
+
+	req.UserID = uid
+	ti, err := srv.GetAuthorizeToken(c, req)
+	if err != nil {
+		data, _, _ := srv.GetErrorData(err)
+		redirectOIDC(c, req, data)
+		return
+	}
+	if err := service.SaveOIDCAuthorization(c, ti.GetCode(), r.FormValue("nonce"), sid, authTime); err != nil {
+		controllerLogger.Errorln("SaveOIDCAuthorization Err", err)
+		redirectOIDCError(c, req, "server_error", "")
+		return
+	}
+	redirectOIDC(c, req, srv.GetAuthorizeData(req.ResponseType, ti))
+}
+
+// EndSession is the RP-initiated logout endpoint of OpenID Connect, the frontend
+// passes the login token as `part` like authorization requests. The session is
+// logged out and the user goes back to post_logout_redirect_uri if it is registered.
+func EndSession(c *gin.Context) {
+	r := c.Request
+	_ = r.ParseForm()
+	req, err := service.CheckLogoutRequest(c, r.FormValue("id_token_hint"), r.FormValue("client_id"), r.FormValue("post_logout_redirect_uri"))
+	if err != nil {
+		c.JSON(http.StatusOK, result.Failed(result.HandleError(err)))
+		return
+	}
+	// users not logged in have no session to end
+	if uid, sid, err := service.CheckLoginToken(c, r.FormValue("part")); err == nil {
+		// the hint of another user can not log out the current one
+		if req.UserID != "" && req.UserID != uid {
+			c.JSON(http.StatusOK, result.Failed(result.InvalidIDTokenHint))
+			return
+		}
+		if err := service.RevokeSession(c, uid, sid); err != nil {
+			c.JSON(http.StatusOK, result.Failed(result.HandleError(err)))
+			return
+		}
+	}
+	if req.RedirectURI == "" {
+		c.JSON(http.StatusOK, result.Success(nil))
+		return
+	}
+	uri, err := url.Parse(req.RedirectURI)
+	if err != nil {
+		c.JSON(http.StatusOK, result.Failed(result.InvalidRedirectURI))
+		return
+	}
+	if state := r.FormValue("state"); state != "" {
+		query := uri.Query()
+		query.Set("state", state)
+		uri.RawQuery = query.Encode()
+	}
+	c.Redirect(http.StatusFound, uri.String())
+}
+
+func redirectOIDCError(c *gin.Context, req *server.AuthorizeRequest, code, description string) {
+	data := map[string]interface{}{"error": code}
+	if description != "" {
+		data["error_description"] = description
+	}
+	redirectOIDC(c, req, data)
+}
+
+func redirectOIDC(c *gin.Context, req *server.AuthorizeRequest, data map[string]interface{}) {
+	uri, err := srv.GetRedirectURI(req, data)
+	if err != nil {
+		c.JSON(http.StatusOK, result.Failed(result.ClientErr))
+		return
+	}
+	c.Redirect(http.StatusFound, uri)
+}
+
+// handleTokenRequest is srv.HandleTokenRequest with ID token,
+// responses to OpenID Connect clients follow RFC 6749 instead of result.Response.
+func handleTokenRequest(c *gin.Context) {
+	w, r := c.Writer, c.Request
+	ctx := r.Context()
+	// go-oauth2 does not know the device grant
+	if r.FormValue("grant_type") == service.DeviceGrantType {
+		handleDeviceTokenRequest(c)
+		return
+	}
+	if r.FormValue("grant_type") == oauth2.Refreshing.String() {
+		if err := service.CheckRefreshTokenReuse(ctx, c.ClientIP(), r.FormValue("refresh_token")); err != nil {
+			tokenError(w, refreshError(err), false)
+			return
+		}
+	}
+	gt, tgr, err := srv.ValidationTokenRequest(r)
+	if err != nil {
+		tokenError(w, err, false)
+		return
+	}
+
+	var auth *model.OIDCAuthorization
+	standard := false
+	switch gt {
+	case oauth2.AuthorizationCode:
+		if auth, err = service.OIDCAuthorization(ctx, tgr.Code); err != nil {
+			tokenError(w, err, false)
+			return
+		}
+		standard = auth != nil
+	case oauth2.Refreshing:
+		if ti, err := srv.Manager.LoadRefreshToken(ctx, tgr.Refresh); err == nil {
+			standard = service.HasScope(ti.GetScope(), "openid")
+			// the refresh token is rotated once, a concurrent request reuses it
+			if err := service.ClaimRefreshToken(ctx, c.ClientIP(), ti); err != nil {
+				tokenError(w, refreshError(err), standard)
+				return
+			}
+		}
+	case oauth2.ClientCredentials:
+		// only service accounts use it, there are no legacy clients to keep
+		standard = true
+	}
+
+	ti, err := srv.GetAccessToken(ctx, gt, tgr)
+	if err != nil {
+		if gt == oauth2.Refreshing {
+			service.ReleaseRefreshToken(ctx, tgr.Refresh)
+		}
+		tokenError(w, err, standard)
+		return
+	}
+	data := srv.GetTokenData(ti)
+	if !standard {
+		_ = ResponseTokenHandler(w, data, nil)
+		return
+	}
+	if auth != nil {
+		service.DeleteOIDCAuthorization(ctx, tgr.Code)
+		if service.HasScope(ti.GetScope(), "openid") {
+			idToken, err := service.IDToken(ti.GetClientID(), ti.GetUserID(), ti.GetScope(), ti.GetAccess(), auth)
+			if err != nil {
+				controllerLogger.Errorln("sign ID token fail:", err)
+				tokenError(w, err, true)
+				return
+			}
+			data["id_token"] = idToken
+			if auth.Sid != "" {
+				service.AddSessionClient(ctx, auth.Sid, ti.GetClientID())
+			}
+		}
+	}
+	_ = standardTokenResponse(w, data, nil)
+}
+
+// refreshError report reused refresh tokens as invalid_grant
+func refreshError(err error) error {
+	if err == service.ErrRefreshTokenReused {
+		return errors.ErrInvalidGrant
+	}
+	return err
+}
+
+func tokenError(w http.ResponseWriter, err error, standard bool) {
+	data, statusCode, header := srv.GetErrorData(err)
+	if standard {
+		_ = standardTokenResponse(w, data, header, statusCode)
+		return
+	}
+	_ = ResponseTokenHandler(w, data, header, statusCode)
+}
+
+// standardTokenResponse write the token response of RFC 6749
+func standardTokenResponse(w http.ResponseWriter, data map[string]interface{}, header http.Header, statusCode ...int) error {
+	w.Header().Set("Content-Type", "application/json;charset=UTF-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Pragma", "no-cache")
+	for key := range header {
+		w.Header().Set(key, header.Get(key))
+	}
+	status := http.StatusOK
+	if len(statusCode) > 0 && statusCode[0] > 0 {
+		status = statusCode[0]
+	}
+	w.WriteHeader(status)
+	// error_code is an extension of go-oauth2, not part of the standard
+	delete(data, "error_code")
+	return json.NewEncoder(w).Encode(data)
+}
+
+func hasValue(values []string, v string) bool {
+	for _, value := range values {
+		if value == v {
+			return true
+		}
+	}
+	return false
+}
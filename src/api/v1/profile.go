@@ -4,24 +4,13 @@ import (
 	"github.com/NJUPT-SAST/sast-link-backend/model"
 	"github.com/NJUPT-SAST/sast-link-backend/model/result"
 	"github.com/NJUPT-SAST/sast-link-backend/service"
-	"github.com/NJUPT-SAST/sast-link-backend/util"
 	"github.com/gin-gonic/gin"
 	"github.com/gin-gonic/gin/binding"
 	"net/http"
 )
 
 func GetProfile(ctx *gin.Context) {
-	token := ctx.GetHeader("TOKEN")
-	if token == "" {
-		ctx.JSON(http.StatusBadRequest, result.Failed(result.RequestParamError))
-		return
-	}
-	uid, err := util.IdentityFromToken(token, model.LOGIN_TOKEN_SUB)
-	if uid == "" || err != nil {
-		controllerLogger.Errorln("Can`t get username by token", err)
-		ctx.JSON(http.StatusOK, result.Failed(result.TokenError))
-		return
-	}
+	uid := ctx.GetString("uid")
 
 	profileInfo, serErr := service.GetProfileInfo(uid)
 	if serErr != nil {
@@ -49,22 +38,11 @@ func GetProfile(ctx *gin.Context) {
 	}
 }
 func ChangeProfile(ctx *gin.Context) {
-	token := ctx.GetHeader("TOKEN")
-	if token == "" {
-		ctx.JSON(http.StatusBadRequest, result.Failed(result.RequestParamError))
-		return
-	}
-
-	uid, err := util.IdentityFromToken(token, model.LOGIN_TOKEN_SUB)
-	if uid == "" || err != nil {
-		controllerLogger.Errorln("Can`t get username by token", err)
-		ctx.JSON(http.StatusOK, result.Failed(result.TokenError))
-		return
-	}
+	uid := ctx.GetString("uid")
 
 	//get profile info from body
 	profile := model.Profile{}
-	if err = ctx.ShouldBindBodyWith(&profile, binding.JSON); err != nil {
+	if err := ctx.ShouldBindBodyWith(&profile, binding.JSON); err != nil {
 		controllerLogger.Errorln("get profile from request body wrong", err)
 		ctx.JSON(http.StatusBadRequest, result.Failed(result.RequestParamError))
 		return
@@ -79,18 +57,7 @@ func ChangeProfile(ctx *gin.Context) {
 }
 
 func UploadAvatar(ctx *gin.Context) {
-	token := ctx.GetHeader("TOKEN")
-	if token == "" {
-		ctx.JSON(http.StatusBadRequest, result.Failed(result.RequestParamError))
-		return
-	}
-
-	uid, err := util.IdentityFromToken(token, model.LOGIN_TOKEN_SUB)
-	if uid == "" || err != nil {
-		controllerLogger.Errorln("Can`t get username by token", err)
-		ctx.JSON(http.StatusOK, result.Failed(result.TokenError))
-		return
-	}
+	uid := ctx.GetString("uid")
 
 	//obtain avatar file from body
 	avatar, err := ctx.FormFile("avatarFile")
@@ -108,8 +75,54 @@ func UploadAvatar(ctx *gin.Context) {
 
 	ctx.JSON(http.StatusOK, result.Success(filePath))
 }
+
+// ChangeEmail send a verification code to the new email after re-authenticating,
+// TOTP or recovery code is required in `code` if 2FA is enabled.
 func ChangeEmail(ctx *gin.Context) {
-	ctx.JSON(200, "success")
+	uid := ctx.GetString("uid")
+	email := ctx.PostForm("email")
+	password := ctx.PostForm("password")
+	if email == "" {
+		ctx.JSON(http.StatusOK, result.Failed(result.RequestParamError))
+		return
+	}
+	if password == "" {
+		ctx.JSON(http.StatusOK, result.Failed(result.PasswordEmpty))
+		return
+	}
+
+	if err := service.RequestEmailChange(ctx, uid, password, ctx.PostForm("code"), email); err != nil {
+		controllerLogger.Errorln("RequestEmailChange Err", err)
+		if respondLocked(ctx, err) {
+			return
+		}
+		ctx.JSON(http.StatusOK, result.Failed(result.HandleError(err)))
+		return
+	}
+	ctx.JSON(http.StatusOK, result.Success(nil))
+}
+
+// ConfirmEmail change the email with the code sent by ChangeEmail
+func ConfirmEmail(ctx *gin.Context) {
+	uid := ctx.GetString("uid")
+	captcha := ctx.PostForm("captcha")
+	if captcha == "" {
+		ctx.JSON(http.StatusOK, result.Failed(result.RequestParamError))
+		return
+	}
+
+	email, err := service.ConfirmEmailChange(ctx, uid, captcha)
+	if err != nil {
+		controllerLogger.Errorln("ConfirmEmailChange Err", err)
+		if respondLocked(ctx, err) {
+			return
+		}
+		ctx.JSON(http.StatusOK, result.Failed(result.HandleError(err)))
+		return
+	}
+	ctx.JSON(http.StatusOK, result.Success(gin.H{
+		"email": email,
+	}))
 }
 
 func DealCensorRes(ctx *gin.Context) {
@@ -144,17 +157,7 @@ func DealCensorRes(ctx *gin.Context) {
 
 // BindStatus : get third party login bind status
 func BindStatus(ctx *gin.Context) {
-	token := ctx.GetHeader("TOKEN")
-	if token == "" {
-		ctx.JSON(http.StatusBadRequest, result.Failed(result.RequestParamError))
-		return
-	}
-	uid, err := util.IdentityFromToken(token, model.LOGIN_TOKEN_SUB)
-	if uid == "" || err != nil {
-		controllerLogger.Errorln("Can`t get username by token", err)
-		ctx.JSON(http.StatusOK, result.Failed(result.TokenError))
-		return
-	}
+	uid := ctx.GetString("uid")
 	bindList, serErr := service.GetBindList(uid)
 	if serErr != nil {
 		controllerLogger.Errorln("GetBindStatus service wrong", serErr)
@@ -163,3 +166,31 @@ func BindStatus(ctx *gin.Context) {
 	}
 	ctx.JSON(http.StatusOK, result.Success(bindList))
 }
+
+// ConnectedApps list the OAuth applications the user has authorized
+func ConnectedApps(ctx *gin.Context) {
+	uid := ctx.GetString("uid")
+	apps, err := service.ConnectedApps(ctx, uid)
+	if err != nil {
+		controllerLogger.Errorln("ConnectedApps service wrong", err)
+		ctx.JSON(http.StatusOK, result.Failed(result.HandleError(err)))
+		return
+	}
+	ctx.JSON(http.StatusOK, result.Success(apps))
+}
+
+// RevokeApp revoke the tokens and consent the user gave to an OAuth application
+func RevokeApp(ctx *gin.Context) {
+	uid := ctx.GetString("uid")
+	clientID := ctx.PostForm("client_id")
+	if clientID == "" {
+		ctx.JSON(http.StatusOK, result.Failed(result.RequestParamError))
+		return
+	}
+	if err := service.RevokeApp(ctx, uid, clientID); err != nil {
+		controllerLogger.Errorln("RevokeApp service wrong", err)
+		ctx.JSON(http.StatusOK, result.Failed(result.HandleError(err)))
+		return
+	}
+	ctx.JSON(http.StatusOK, result.Success(nil))
+}
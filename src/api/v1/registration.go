@@ -0,0 +1,297 @@
+package v1
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/NJUPT-SAST/sast-link-backend/model"
+	"github.com/NJUPT-SAST/sast-link-backend/model/result"
+	"github.com/NJUPT-SAST/sast-link-backend/service"
+	"github.com/gin-gonic/gin"
+)
+
+// clientRegistration is the client metadata of RFC 7591 section 2
+type clientRegistration struct {
+	ClientID                string   `json:"client_id"`
+	RedirectURIs            []string `json:"redirect_uris"`
+	GrantTypes              []string `json:"grant_types"`
+	TokenEndpointAuthMethod string   `json:"token_endpoint_auth_method"`
+	ClientName              string   `json:"client_name"`
+	ClientURI               string   `json:"client_uri"`
+	LogoURI                 string   `json:"logo_uri"`
+	Scope                   string   `json:"scope"`
+	ApplicationType         string   `json:"application_type"`
+	PostLogoutRedirectURIs  []string `json:"post_logout_redirect_uris"`
+	BackchannelLogoutURI    string   `json:"backchannel_logout_uri"`
+}
+
+// RegisterClient is the client registration endpoint of RFC 7591,
+// it requires an initial access token issued by admins.
+func RegisterClient(c *gin.Context) {
+	initialToken, ok := srv.BearerAuth(c.Request)
+	if !ok {
+		registrationTokenError(c)
+		return
+	}
+	metadata, ok := registrationMetadata(c)
+	if !ok {
+		return
+	}
+	client, registrationToken, err := service.RegisterClient(initialToken, metadata)
+	if err != nil {
+		registrationError(c, err)
+		return
+	}
+	view := registrationView(client)
+	if !client.Public {
+		view["client_secret"] = client.Secret
+		view["client_secret_expires_at"] = 0
+	}
+	view["registration_access_token"] = registrationToken
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusCreated, view)
+}
+
+// RegisteredClient read a registered client by its registration access token, RFC 7592
+func RegisteredClient(c *gin.Context) {
+	registrationToken, ok := srv.BearerAuth(c.Request)
+	if !ok {
+		registrationTokenError(c)
+		return
+	}
+	client, err := service.RegisteredClient(c, c.Param("client_id"), registrationToken)
+	if err != nil {
+		registrationError(c, err)
+		return
+	}
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusOK, registrationView(client))
+}
+
+// UpdateRegisteredClient replace the metadata of a registered client, RFC 7592
+func UpdateRegisteredClient(c *gin.Context) {
+	registrationToken, ok := srv.BearerAuth(c.Request)
+	if !ok {
+		registrationTokenError(c)
+		return
+	}
+	metadata, ok := registrationMetadata(c)
+	if !ok {
+		return
+	}
+	client, err := service.UpdateRegisteredClient(c, c.Param("client_id"), registrationToken, metadata)
+	if err != nil {
+		registrationError(c, err)
+		return
+	}
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusOK, registrationView(client))
+}
+
+// DeleteRegisteredClient delete a registered client, RFC 7592
+func DeleteRegisteredClient(c *gin.Context) {
+	registrationToken, ok := srv.BearerAuth(c.Request)
+	if !ok {
+		registrationTokenError(c)
+		return
+	}
+	if err := service.DeleteRegisteredClient(c, c.Param("client_id"), registrationToken); err != nil {
+		registrationError(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// registrationMetadata read the JSON client metadata,
+// the error response is written if it is invalid.
+func registrationMetadata(c *gin.Context) (*service.ClientMetadata, bool) {
+	var req clientRegistration
+	if err := c.ShouldBindJSON(&req); err != nil {
+		oauthError(c, http.StatusBadRequest, "invalid_client_metadata")
+		return nil, false
+	}
+	// RFC 7592 section 2.2, the client_id in the body must be the one updated
+	if id := c.Param("client_id"); id != "" && req.ClientID != id {
+		oauthError(c, http.StatusBadRequest, "invalid_client_metadata")
+		return nil, false
+	}
+	metadata := &service.ClientMetadata{
+		Name:                   req.ClientName,
+		Logo:                   req.LogoURI,
+		Homepage:               req.ClientURI,
+		RedirectURIs:           req.RedirectURIs,
+		GrantTypes:             req.GrantTypes,
+		Scopes:                 strings.Fields(req.Scope),
+		PostLogoutRedirectURIs: req.PostLogoutRedirectURIs,
+		BackchannelLogoutURI:   req.BackchannelLogoutURI,
+	}
+	switch req.TokenEndpointAuthMethod {
+	case "", "client_secret_basic", "client_secret_post":
+	case "none":
+		metadata.Public = true
+	default:
+		oauthError(c, http.StatusBadRequest, "invalid_client_metadata")
+		return nil, false
+	}
+	// OIDC Dynamic Client Registration section 2, native apps listen on loopback ports
+	switch req.ApplicationType {
+	case "", "web":
+	case "native":
+		metadata.LoopbackRedirect = true
+	default:
+		oauthError(c, http.StatusBadRequest, "invalid_client_metadata")
+		return nil, false
+	}
+	return metadata, true
+}
+
+func registrationView(client *model.OAuthClient) gin.H {
+	authMethod := "client_secret_basic"
+	if client.Public {
+		authMethod = "none"
+	}
+	appType := "web"
+	if client.LoopbackRedirect {
+		appType = "native"
+	}
+	view := gin.H{
+		"client_id":                  client.ID,
+		"client_name":                client.Name,
+		"client_uri":                 client.Homepage,
+		"logo_uri":                   client.Logo,
+		"redirect_uris":              service.ClientRedirectURIs(client),
+		"grant_types":                client.GrantTypes,
+		"token_endpoint_auth_method": authMethod,
+		"scope":                      strings.Join(service.ClientScopes(client), " "),
+		"registration_client_uri":    service.OIDCIssuer() + "/api/v1/oauth2/register/" + client.ID,
+		"status":                     client.Status,
+		"application_type":           appType,
+	}
+	if len(client.PostLogoutRedirectURIs) > 0 {
+		view["post_logout_redirect_uris"] = client.PostLogoutRedirectURIs
+	}
+	if client.BackchannelLogoutURI != "" {
+		// the sid claim is always in logout tokens
+		view["backchannel_logout_uri"] = client.BackchannelLogoutURI
+		view["backchannel_logout_session_required"] = true
+	}
+	if client.CreatedAt != nil {
+		view["client_id_issued_at"] = client.CreatedAt.Unix()
+	}
+	return view
+}
+
+func registrationTokenError(c *gin.Context) {
+	c.Header("WWW-Authenticate", `Bearer error="invalid_token"`)
+	oauthError(c, http.StatusUnauthorized, "invalid_token")
+}
+
+func registrationError(c *gin.Context, err error) {
+	switch err {
+	case service.ErrInvalidRegistrationToken:
+		registrationTokenError(c)
+	case result.RequestParamError, result.InvalidScope:
+		oauthError(c, http.StatusBadRequest, "invalid_client_metadata")
+	case result.InvalidRedirectURI:
+		oauthError(c, http.StatusBadRequest, "invalid_redirect_uri")
+	default:
+		controllerLogger.Errorln("client registration fail:", err)
+		oauthError(c, http.StatusInternalServerError, "server_error")
+	}
+}
+
+// CreateInitialAccessToken issue a token for a project to register `maxUses` clients,
+// `expiresIn` (hours) defaults to 7 days.
+func CreateInitialAccessToken(ctx *gin.Context) {
+	uid := ctx.GetString("uid")
+	maxUses, err := strconv.Atoi(ctx.DefaultPostForm("maxUses", "1"))
+	if err != nil {
+		ctx.JSON(http.StatusOK, result.Failed(result.RequestParamError))
+		return
+	}
+	expiresIn, err := strconv.Atoi(ctx.DefaultPostForm("expiresIn", "168"))
+	if err != nil {
+		ctx.JSON(http.StatusOK, result.Failed(result.RequestParamError))
+		return
+	}
+	token, initial, err := service.CreateInitialAccessToken(uid, maxUses, time.Duration(expiresIn)*time.Hour, ctx.PostForm("note"))
+	if err != nil {
+		controllerLogger.Errorf("create initial access token fail: %s", err.Error())
+		ctx.JSON(http.StatusOK, result.Failed(result.HandleError(err)))
+		return
+	}
+	ctx.JSON(http.StatusOK, result.Success(gin.H{
+		"token":              token,
+		"initialAccessToken": initial,
+	}))
+}
+
+// InitialAccessTokens list initial access tokens and their usage
+func InitialAccessTokens(ctx *gin.Context) {
+	tokens, err := service.InitialAccessTokens()
+	if err != nil {
+		controllerLogger.Errorf("list initial access tokens fail: %s", err.Error())
+		ctx.JSON(http.StatusOK, result.Failed(result.HandleError(err)))
+		return
+	}
+	ctx.JSON(http.StatusOK, result.Success(tokens))
+}
+
+// ExpireInitialAccessToken stop an initial access token by `id`
+func ExpireInitialAccessToken(ctx *gin.Context) {
+	id, err := strconv.ParseUint(ctx.PostForm("id"), 10, 32)
+	if err != nil {
+		ctx.JSON(http.StatusOK, result.Failed(result.RequestParamError))
+		return
+	}
+	if err := service.ExpireInitialAccessToken(uint(id)); err != nil {
+		ctx.JSON(http.StatusOK, result.Failed(result.HandleError(err)))
+		return
+	}
+	ctx.JSON(http.StatusOK, result.Success(nil))
+}
+
+// RegisteredClients list registered clients by `status`, pending ones by default
+func RegisteredClients(ctx *gin.Context) {
+	clients, err := service.RegisteredClients(ctx.DefaultQuery("status", model.CLIENT_PENDING))
+	if err != nil {
+		ctx.JSON(http.StatusOK, result.Failed(result.HandleError(err)))
+		return
+	}
+	views := make([]gin.H, 0, len(clients))
+	for i := range clients {
+		view := clientView(&clients[i])
+		view["owner"] = clients[i].UserID
+		views = append(views, view)
+	}
+	ctx.JSON(http.StatusOK, result.Success(views))
+}
+
+// SecurityEvents list the latest security events of OAuth tokens,
+// optionally by `client_id` and `user_id`
+func SecurityEvents(ctx *gin.Context) {
+	events, err := service.SecurityEvents(ctx.Query("client_id"), ctx.Query("user_id"))
+	if err != nil {
+		controllerLogger.Errorf("list security events fail: %s", err.Error())
+		ctx.JSON(http.StatusOK, result.Failed(result.HandleError(err)))
+		return
+	}
+	ctx.JSON(http.StatusOK, result.Success(events))
+}
+
+// ReviewClient approve or reject a registered client by `client_id` and `approved`
+func ReviewClient(ctx *gin.Context) {
+	approved, err := strconv.ParseBool(ctx.PostForm("approved"))
+	if err != nil {
+		ctx.JSON(http.StatusOK, result.Failed(result.RequestParamError))
+		return
+	}
+	client, err := service.ReviewClient(ctx, ctx.GetString("uid"), ctx.PostForm("client_id"), approved)
+	if err != nil {
+		ctx.JSON(http.StatusOK, result.Failed(result.HandleError(err)))
+		return
+	}
+	ctx.JSON(http.StatusOK, result.Success(clientView(client)))
+}
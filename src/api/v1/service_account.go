@@ -0,0 +1,72 @@
+package v1
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/NJUPT-SAST/sast-link-backend/model/result"
+	"github.com/NJUPT-SAST/sast-link-backend/service"
+	"github.com/gin-gonic/gin"
+)
+
+// CreateServiceAccount let `client_id` use the client_credentials grant,
+// `scope` is space separated and `owner` is the uid responsible for it.
+func CreateServiceAccount(ctx *gin.Context) {
+	uid := ctx.GetString("uid")
+	account, err := service.CreateServiceAccount(ctx, uid, ctx.PostForm("client_id"), ctx.PostForm("owner"),
+		ctx.PostForm("purpose"), strings.Fields(ctx.PostForm("scope")))
+	if err != nil {
+		controllerLogger.Errorf("create service account fail: %s", err.Error())
+		ctx.JSON(http.StatusOK, result.Failed(result.HandleError(err)))
+		return
+	}
+	ctx.JSON(http.StatusOK, result.Success(account))
+}
+
+// ServiceAccounts list service accounts and their owners
+func ServiceAccounts(ctx *gin.Context) {
+	accounts, err := service.ServiceAccounts()
+	if err != nil {
+		controllerLogger.Errorf("list service accounts fail: %s", err.Error())
+		ctx.JSON(http.StatusOK, result.Failed(result.HandleError(err)))
+		return
+	}
+	ctx.JSON(http.StatusOK, result.Success(accounts))
+}
+
+// DisableServiceAccount stop a service account by `id`
+func DisableServiceAccount(ctx *gin.Context) {
+	id, err := strconv.ParseUint(ctx.PostForm("id"), 10, 32)
+	if err != nil {
+		ctx.JSON(http.StatusOK, result.Failed(result.RequestParamError))
+		return
+	}
+	if err := service.DisableServiceAccount(uint(id)); err != nil {
+		ctx.JSON(http.StatusOK, result.Failed(result.HandleError(err)))
+		return
+	}
+	ctx.JSON(http.StatusOK, result.Success(nil))
+}
+
+// DirectoryUsers look up members by `uid` with an access token of the `directory` scope,
+// usually issued to service accounts by the client_credentials grant.
+func DirectoryUsers(c *gin.Context) {
+	accessToken, ok := srv.BearerAuth(c.Request)
+	if !ok {
+		c.JSON(http.StatusOK, result.Failed(result.AccessTokenErr))
+		return
+	}
+	ti, err := srv.Manager.LoadAccessToken(c, accessToken)
+	if err != nil {
+		c.JSON(http.StatusOK, result.Failed(result.AccessTokenErr))
+		return
+	}
+	users, err := service.DirectoryUsers(c.QueryArray("uid"), ti.GetScope())
+	if err != nil {
+		controllerLogger.Errorf("look up directory fail: %s", err.Error())
+		c.JSON(http.StatusOK, result.Failed(result.HandleError(err)))
+		return
+	}
+	c.JSON(http.StatusOK, result.Success(users))
+}
@@ -0,0 +1,138 @@
+package v1
+
+import (
+	"net/http"
+
+	"github.com/NJUPT-SAST/sast-link-backend/model"
+	"github.com/NJUPT-SAST/sast-link-backend/model/result"
+	"github.com/NJUPT-SAST/sast-link-backend/service"
+	"github.com/gin-gonic/gin"
+)
+
+// EnrollTOTP generate a new TOTP secret,
+// frontend render the `uri` as QR code for authenticator apps.
+func EnrollTOTP(ctx *gin.Context) {
+	uid := ctx.GetString("uid")
+
+	secret, uri, err := service.EnrollTOTP(uid)
+	if err != nil {
+		ctx.JSON(http.StatusOK, result.Failed(result.HandleError(err)))
+		return
+	}
+	ctx.JSON(http.StatusOK, result.Success(gin.H{
+		"secret": secret,
+		"uri":    uri,
+	}))
+}
+
+// ConfirmTOTP enable 2FA with the first code from authenticator,
+// the recovery codes are only returned here.
+func ConfirmTOTP(ctx *gin.Context) {
+	uid := ctx.GetString("uid")
+	code := ctx.PostForm("code")
+	if code == "" {
+		ctx.JSON(http.StatusOK, result.Failed(result.RequestParamError))
+		return
+	}
+
+	recoveryCodes, err := service.ConfirmTOTP(ctx, uid, code)
+	if err != nil {
+		if respondLocked(ctx, err) {
+			return
+		}
+		ctx.JSON(http.StatusOK, result.Failed(result.HandleError(err)))
+		return
+	}
+	ctx.JSON(http.StatusOK, result.Success(gin.H{
+		"recoveryCodes": recoveryCodes,
+	}))
+}
+
+// DisableTOTP disable 2FA, require password and a TOTP or recovery code
+func DisableTOTP(ctx *gin.Context) {
+	uid := ctx.GetString("uid")
+	password := ctx.PostForm("password")
+	code := ctx.PostForm("code")
+	if password == "" {
+		ctx.JSON(http.StatusOK, result.Failed(result.PasswordEmpty))
+		return
+	}
+	if code == "" {
+		ctx.JSON(http.StatusOK, result.Failed(result.RequestParamError))
+		return
+	}
+
+	if err := service.DisableTOTP(ctx, uid, password, code); err != nil {
+		if respondLocked(ctx, err) {
+			return
+		}
+		ctx.JSON(http.StatusOK, result.Failed(result.HandleErrorWithArgu(err, result.VerifyPasswordError)))
+		return
+	}
+	ctx.JSON(http.StatusOK, result.Success(nil))
+}
+
+// LoginTOTP is the second step of login when 2FA is enabled,
+// TWO-FACTOR-TICKET is returned by Login after the password is verified.
+func LoginTOTP(ctx *gin.Context) {
+	ticket := ctx.GetHeader("TWO-FACTOR-TICKET")
+	code := ctx.PostForm("code")
+	if ticket == "" {
+		ctx.JSON(http.StatusOK, result.Failed(result.CheckTicketNotfound))
+		return
+	}
+	if code == "" {
+		ctx.JSON(http.StatusOK, result.Failed(result.RequestParamError))
+		return
+	}
+
+	uid, err := service.CheckTwoFactorTicket(ctx, ticket)
+	if err != nil {
+		ctx.JSON(http.StatusOK, result.Failed(result.HandleError(err)))
+		return
+	}
+	if err := service.VerifyTwoFactor(ctx, uid, code); err != nil {
+		controllerLogger.Errorf("verify two factor fail: %s", err.Error())
+		if respondLocked(ctx, err) {
+			return
+		}
+		ctx.JSON(http.StatusOK, result.Failed(result.HandleError(err)))
+		return
+	}
+	// the ticket can only be used once
+	model.Rdb.Del(ctx, model.TwoFactorTicketKey(uid))
+
+	if !bindOauthTicket(ctx, uid) {
+		return
+	}
+	issueLoginToken(ctx, uid)
+}
+
+// requireTwoFactor respond with TWO-FACTOR-TICKET if uid enabled 2FA,
+// return false if the login token can be issued directly.
+func requireTwoFactor(ctx *gin.Context, uid string) bool {
+	enabled, err := service.TwoFactorEnabled(uid)
+	if err != nil {
+		controllerLogger.Errorf("check two factor fail: %s", err.Error())
+		ctx.JSON(http.StatusOK, result.Failed(result.InternalErr))
+		return true
+	}
+	if !enabled {
+		return false
+	}
+
+	ticket, err := service.GenerateTwoFactorTicket(ctx, uid)
+	if err != nil {
+		ctx.JSON(http.StatusOK, result.Failed(result.GenerateToken))
+		return true
+	}
+	ctx.JSON(http.StatusOK, result.Response{
+		Success: false,
+		ErrCode: result.TwoFactorRequired.ErrCode,
+		ErrMsg:  result.TwoFactorRequired.ErrMsg,
+		Data: gin.H{
+			model.TWO_FACTOR_TICKET_SUB: ticket,
+		},
+	})
+	return true
+}
@@ -1,8 +1,9 @@
 package v1
 
 import (
+	"errors"
 	"net/http"
-	"regexp"
+	"strconv"
 	"strings"
 
 	"github.com/NJUPT-SAST/sast-link-backend/model"
@@ -12,6 +13,7 @@ import (
 	"github.com/NJUPT-SAST/sast-link-backend/service"
 	"github.com/NJUPT-SAST/sast-link-backend/util"
 	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
 )
 
 var controllerLogger = log.Log
@@ -50,7 +52,14 @@ func Register(ctx *gin.Context) {
 		return
 	}
 
-	creErr := service.CreateUserAndProfile(username, password)
+	// a ticket got without invitation has no code saved
+	invitation, err := model.Rdb.Get(ctx, model.RegisterInvitationKey(ticket)).Result()
+	if err != nil && err != redis.Nil {
+		controllerLogger.Errorf("get register invitation fail: %s", err.Error())
+		ctx.JSON(http.StatusOK, result.Failed(result.HandleError(err)))
+		return
+	}
+	creErr := service.CreateUserAndProfile(username, password, invitation)
 	if creErr != nil {
 		ctx.JSON(http.StatusOK, result.Failed(result.HandleError(creErr)))
 		return
@@ -80,6 +89,9 @@ func CheckVerifyCode(ctx *gin.Context) {
 
 	codeError := service.CheckVerifyCode(ctx, ticket, code, flag)
 	if codeError != nil {
+		if respondLocked(ctx, codeError) {
+			return
+		}
 		ctx.JSON(http.StatusOK, result.Failed(result.HandleError(codeError)))
 		return
 	}
@@ -95,10 +107,14 @@ func UserInfo(ctx *gin.Context) {
 		return
 	}
 
-	ctx.JSON(http.StatusOK, result.Success(gin.H{
+	info := gin.H{
 		"email":  user.Email,
 		"userId": user.Uid,
-	}))
+	}
+	if user.DeletionScheduledAt != nil {
+		info["deleteAt"] = user.DeletionScheduledAt.Unix()
+	}
+	ctx.JSON(http.StatusOK, result.Success(info))
 }
 
 func SendEmail(ctx *gin.Context) {
@@ -123,10 +139,9 @@ func SendEmail(ctx *gin.Context) {
 		ctx.JSON(http.StatusUnauthorized, result.Failed(result.HandleErrorWithArgu(usernameErr, result.TicketNotCorrect)))
 		return
 	}
-	// verify if the user email correct
-	matched, _ := regexp.MatchString("^[BPFQbpfq](1[7-9]|2[0-9])([0-3])\\d{5}@njupt.edu.cn$", username)
-	if !matched {
-		ctx.JSON(http.StatusOK, result.Failed(result.UserEmailError))
+	// eligibility is checked when the ticket is issued, only check the deny list here
+	if err := service.CheckEmailPolicy(username); err != nil {
+		ctx.JSON(http.StatusOK, result.Failed(result.HandleError(err)))
 		return
 	}
 
@@ -166,7 +181,10 @@ func VerifyAccount(ctx *gin.Context) {
 		return
 	}
 
-	ticket, err := service.VerifyAccount(ctx, username, flag)
+	// only used by register, for people outside the account policy
+	invitation := strings.ToUpper(strings.TrimSpace(ctx.Query("invitation")))
+
+	ticket, err := service.VerifyAccount(ctx, username, flag, invitation)
 	if err != nil {
 		controllerLogger.Errorf("verify account fail: %s", err.Error())
 		ctx.JSON(http.StatusOK, result.Failed(result.HandleError(err)))
@@ -197,9 +215,12 @@ func Login(ctx *gin.Context) {
 		return
 	}
 
-	uid, err := service.Login(username, password)
+	uid, err := service.Login(ctx, username, password)
 	if err != nil {
 		controllerLogger.Errorf("login fail: %s", err.Error())
+		if respondLocked(ctx, err) {
+			return
+		}
 		ctx.AbortWithStatusJSON(http.StatusUnauthorized, result.Failed(result.HandleErrorWithArgu(err, result.VerifyPasswordError)))
 		return
 	}
@@ -208,78 +229,125 @@ func Login(ctx *gin.Context) {
 		return
 	}
 
+	// The password is correct, the token is issued after the second factor
+	if requireTwoFactor(ctx, uid) {
+		return
+	}
+	if !bindOauthTicket(ctx, username) {
+		return
+	}
+	issueLoginToken(ctx, uid)
+}
+
+// respondLocked respond with the seconds to wait in `Retry-After` header
+// if err is a result.LockedError, return false for other errors.
+func respondLocked(ctx *gin.Context, err error) bool {
+	var locked result.LockedError
+	if !errors.As(err, &locked) {
+		return false
+	}
+	ctx.Header("Retry-After", strconv.FormatInt(locked.RetryAfterSeconds(), 10))
+	ctx.JSON(http.StatusOK, result.Locked(locked))
+	return true
+}
+
+// bindOauthTicket bind the third party account in OAUTH-TICKET header to username,
+// return false if the response has been written.
+func bindOauthTicket(ctx *gin.Context, username string) bool {
 	// Oauth: check if need to bound oauth servers like lark, github...
 	// TODO: use cookie to manage ticket etc...
 	oauthTicket := ctx.Request.Header.Get("OAUTH-TICKET")
-	if oauthTicket != "" {
-		log.Log.Debugf("Login ::: Header ::: OAUTH-TICKET ::: %v\n", oauthTicket)
+	if oauthTicket == "" {
+		return true
+	}
+	log.Log.Debugf("Login ::: Header ::: OAUTH-TICKET ::: %v\n", oauthTicket)
+
+	audience, err := util.TokenAudience(oauthTicket)
+	if err != nil {
+		ctx.JSON(http.StatusOK, result.Failed(result.OauthTokenError))
+		log.Log.Errorln("util.TokenAudience ::: ", err)
+		return false
+	}
+	flagIn := audience[0][strings.LastIndex(audience[0], "-")+1:]
+
+	log.Debugf("Login ::: Oauth ::: flagIn ::: %v", flagIn)
 
-		audience, err := util.TokenAudience(oauthTicket)
+	switch flagIn {
+	case model.OAUTH_LARK_SUB:
+		unionID, err := util.IdentityFromToken(oauthTicket, model.OAUTH_LARK_SUB)
 		if err != nil {
 			ctx.JSON(http.StatusOK, result.Failed(result.OauthTokenError))
-			log.Log.Errorln("util.TokenAudience ::: ", err)
-			return
+			log.Log.Errorln("util.IdentityFromToken ::: ", err)
+			return false
 		}
-		flagIn := strings.Split(audience[0], "-")[1]
-
-		log.Debugf("Login ::: Oauth ::: flagIn ::: %v", flagIn)
 
-		switch flagIn {
-		case model.OAUTH_LARK_SUB:
-			unionID, err := util.IdentityFromToken(oauthTicket, model.OAUTH_LARK_SUB)
-			if err != nil {
-				ctx.JSON(http.StatusOK, result.Failed(result.OauthTokenError))
-				log.Log.Errorln("util.IdentityFromToken ::: ", err)
-				return
-			}
+		oauthLarkUserInfo, _ := model.Rdb.Get(ctx, unionID).Result()
 
-			oauthLarkUserInfo, _ := model.Rdb.Get(ctx, unionID).Result()
+		log.Debugf("Login ::: Oauth ::: unionID ::: %v", unionID)
+		log.Debugf("Login ::: Oauth ::: lark info ::: %v", oauthLarkUserInfo)
 
-			log.Debugf("Login ::: Oauth ::: unionID ::: %v", unionID)
-			log.Debugf("Login ::: Oauth ::: lark info ::: %v", oauthLarkUserInfo)
+		service.UpsetOauthInfo(username, model.LARK_CLIENT_TYPE, unionID, oauthLarkUserInfo)
 
-			service.UpsetOauthInfo(username, model.LARK_CLIENT_TYPE, unionID, oauthLarkUserInfo)
+	case model.OAUTH_GITHUB_SUB:
+		unionID, err := util.IdentityFromToken(oauthTicket, model.OAUTH_GITHUB_SUB)
+		if err != nil {
+			ctx.JSON(http.StatusOK, result.Failed(result.OauthTokenError))
+			log.Log.Errorln("util.IdentityFromToken ::: ", err)
+			return false
+		}
 
-		case model.OAUTH_GITHUB_SUB:
-			unionID, err := util.IdentityFromToken(oauthTicket, model.OAUTH_GITHUB_SUB)
-			if err != nil {
-				ctx.JSON(http.StatusOK, result.Failed(result.OauthTokenError))
-				log.Log.Errorln("util.IdentityFromToken ::: ", err)
-				return
-			}
+		oauthGithubUserInfo, _ := model.Rdb.Get(ctx, unionID).Result()
 
-			oauthGithubUserInfo, _ := model.Rdb.Get(ctx, unionID).Result()
+		log.Debugf("Login ::: Oauth ::: github info ::: %v", oauthGithubUserInfo)
 
-			log.Debugf("Login ::: Oauth ::: github info ::: %v", oauthGithubUserInfo)
+		service.UpsetOauthInfo(username, model.GITHUB_CLIENT_TYPE, unionID, oauthGithubUserInfo)
+	default:
+		log.Errorf("Login ::: Oauth ::: flagIn ::: %v", flagIn)
+		ctx.JSON(http.StatusOK, result.Failed(result.OauthTokenError))
+		return false
+	}
+	return true
+}
 
-			service.UpsetOauthInfo(username, model.GITHUB_CLIENT_TYPE, unionID, oauthGithubUserInfo)
-		default:
-			log.Errorf("Login ::: Oauth ::: flagIn ::: %v", flagIn)
-			ctx.JSON(http.StatusOK, result.Failed(result.OauthTokenError))
-			return
-		}
+// issueLoginToken create a session and return its token pair
+func issueLoginToken(ctx *gin.Context, uid string) {
+	token, refreshToken, err := service.GenerateLoginToken(ctx, uid)
+	if err != nil {
+		controllerLogger.Errorf("generate login token fail: %s", err.Error())
+		ctx.JSON(http.StatusOK, result.Failed(result.HandleErrorWithArgu(err, result.GenerateToken)))
+		return
 	}
+	ctx.JSON(http.StatusOK, result.Success(gin.H{
+		model.LOGIN_TOKEN_SUB: token,
+		"refreshToken":        refreshToken,
+		"expiresIn":           int64(model.LOGIN_TOKEN_EXP.Seconds()),
+	}))
+}
 
-	// Set Token with expire time and return
-	token, err := util.GenerateTokenWithExp(ctx, model.LoginJWTSubKey(uid), model.LOGIN_TOKEN_EXP)
+// Refresh exchange the refresh token for a new token pair
+func Refresh(ctx *gin.Context) {
+	refreshToken := ctx.PostForm("refreshToken")
+	if refreshToken == "" {
+		ctx.JSON(http.StatusOK, result.Failed(result.RequestParamError))
+		return
+	}
+	token, next, err := service.RefreshLoginToken(ctx, refreshToken)
 	if err != nil {
-		ctx.JSON(http.StatusOK, result.Failed(result.GenerateToken))
+		controllerLogger.Errorf("refresh login token fail: %s", err.Error())
+		ctx.JSON(http.StatusOK, result.Failed(result.HandleError(err)))
+		return
 	}
-	model.Rdb.Set(ctx, model.LoginTokenKey(uid), token, model.LOGIN_TOKEN_EXP)
 	ctx.JSON(http.StatusOK, result.Success(gin.H{
 		model.LOGIN_TOKEN_SUB: token,
+		"refreshToken":        next,
+		"expiresIn":           int64(model.LOGIN_TOKEN_EXP.Seconds()),
 	}))
 }
 
 // Modify paassword
 func ChangePassword(ctx *gin.Context) {
 	// Get username from token
-	token := ctx.GetHeader("TOKEN")
-	uid, err := util.IdentityFromToken(token, model.LOGIN_TOKEN_SUB)
-	if err != nil || uid == "" {
-		ctx.JSON(http.StatusOK, result.Failed(result.TokenError))
-		return
-	}
+	uid := ctx.GetString("uid")
 	// Get password from form
 	oldPassword := ctx.PostForm("oldPassword")
 	newPassword := ctx.PostForm("newPassword")
@@ -288,8 +356,11 @@ func ChangePassword(ctx *gin.Context) {
 		return
 	}
 	// Modify password
-	err = service.ModifyPassword(ctx, uid, oldPassword, newPassword)
+	err := service.ModifyPassword(ctx, uid, oldPassword, newPassword)
 	if err != nil {
+		if respondLocked(ctx, err) {
+			return
+		}
 		ctx.JSON(http.StatusOK, result.Failed(result.HandleError(err)))
 		return
 	}
@@ -342,18 +413,92 @@ func ResetPassword(ctx *gin.Context) {
 }
 
 func Logout(ctx *gin.Context) {
-	//verify information
-	token := ctx.GetHeader("TOKEN")
-	if token == "" {
-		ctx.JSON(http.StatusBadRequest, result.Failed(result.RequestParamError))
+	// only log out the current session
+	uid := ctx.GetString("uid")
+	if err := service.RevokeSession(ctx, uid, ctx.GetString("sid")); err != nil {
+		ctx.JSON(http.StatusOK, result.Failed(result.HandleError(err)))
+		return
+	}
+	ctx.JSON(http.StatusOK, result.Success(nil))
+}
+
+// Sessions list logged-in devices of user
+func Sessions(ctx *gin.Context) {
+	uid := ctx.GetString("uid")
+	sessions, err := service.Sessions(ctx, uid)
+	if err != nil {
+		ctx.JSON(http.StatusOK, result.Failed(result.HandleError(err)))
 		return
 	}
-	//remove Token from uid
-	uid, err := util.IdentityFromToken(token, model.LOGIN_TOKEN_SUB)
-	if err != nil || uid == "" {
-		ctx.JSON(http.StatusOK, result.Failed(result.TokenError))
+	list := make([]gin.H, 0, len(sessions))
+	for _, session := range sessions {
+		list = append(list, gin.H{
+			"id":        session.ID,
+			"device":    session.Device,
+			"userAgent": session.UserAgent,
+			"ip":        session.IP,
+			"createdAt": session.CreatedAt,
+			"lastSeen":  session.LastSeen,
+			"current":   session.ID == ctx.GetString("sid"),
+		})
+	}
+	ctx.JSON(http.StatusOK, result.Success(list))
+}
+
+func RevokeSession(ctx *gin.Context) {
+	uid := ctx.GetString("uid")
+	sid := ctx.PostForm("id")
+	if sid == "" {
+		ctx.JSON(http.StatusOK, result.Failed(result.RequestParamError))
+		return
+	}
+	if err := service.RevokeSession(ctx, uid, sid); err != nil {
+		ctx.JSON(http.StatusOK, result.Failed(result.HandleError(err)))
+		return
+	}
+	ctx.JSON(http.StatusOK, result.Success(nil))
+}
+
+// RevokeOtherSessions log out all devices except the current one
+func RevokeOtherSessions(ctx *gin.Context) {
+	uid := ctx.GetString("uid")
+	if err := service.RevokeOtherSessions(ctx, uid, ctx.GetString("sid")); err != nil {
+		ctx.JSON(http.StatusOK, result.Failed(result.HandleError(err)))
+		return
+	}
+	ctx.JSON(http.StatusOK, result.Success(nil))
+}
+
+// DeleteAccount schedule deletion of the account after re-authenticating,
+// TOTP or recovery code is required in `code` if 2FA is enabled.
+func DeleteAccount(ctx *gin.Context) {
+	uid := ctx.GetString("uid")
+	password := ctx.PostForm("password")
+	if password == "" {
+		ctx.JSON(http.StatusOK, result.Failed(result.PasswordEmpty))
+		return
+	}
+
+	deleteAt, err := service.RequestDeletion(ctx, uid, password, ctx.PostForm("code"))
+	if err != nil {
+		controllerLogger.Errorf("request deletion fail: %s", err.Error())
+		if respondLocked(ctx, err) {
+			return
+		}
+		ctx.JSON(http.StatusOK, result.Failed(result.HandleError(err)))
+		return
+	}
+	ctx.JSON(http.StatusOK, result.Success(gin.H{
+		"deleteAt": deleteAt.Unix(),
+	}))
+}
+
+// CancelDeleteAccount keep the account during the grace period of deletion
+func CancelDeleteAccount(ctx *gin.Context) {
+	uid := ctx.GetString("uid")
+	if err := service.CancelDeletion(uid); err != nil {
+		ctx.JSON(http.StatusOK, result.Failed(result.HandleError(err)))
 		return
 	}
-	model.Rdb.Del(ctx, model.LoginTokenKey(uid))
 	ctx.JSON(http.StatusOK, result.Success(nil))
 }
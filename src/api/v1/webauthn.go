@@ -0,0 +1,148 @@
+package v1
+
+import (
+	"encoding/base64"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/NJUPT-SAST/sast-link-backend/model"
+	"github.com/NJUPT-SAST/sast-link-backend/model/result"
+	"github.com/NJUPT-SAST/sast-link-backend/service"
+	"github.com/NJUPT-SAST/sast-link-backend/util"
+	"github.com/gin-gonic/gin"
+)
+
+// Binary fields of PublicKeyCredential are posted as base64url
+
+// BeginWebAuthnRegister return the options of navigator.credentials.create()
+func BeginWebAuthnRegister(ctx *gin.Context) {
+	uid := ctx.GetString("uid")
+
+	options, err := service.BeginWebAuthnRegistration(ctx, uid)
+	if err != nil {
+		ctx.JSON(http.StatusOK, result.Failed(result.HandleError(err)))
+		return
+	}
+	ctx.JSON(http.StatusOK, result.Success(options))
+}
+
+// FinishWebAuthnRegister save the passkey created by authenticator
+func FinishWebAuthnRegister(ctx *gin.Context) {
+	uid := ctx.GetString("uid")
+	clientDataJSON, err1 := base64.RawURLEncoding.DecodeString(ctx.PostForm("clientDataJSON"))
+	attestationObject, err2 := base64.RawURLEncoding.DecodeString(ctx.PostForm("attestationObject"))
+	if err1 != nil || err2 != nil || len(clientDataJSON) == 0 || len(attestationObject) == 0 {
+		ctx.JSON(http.StatusOK, result.Failed(result.RequestParamError))
+		return
+	}
+	name := strings.TrimSpace(ctx.PostForm("name"))
+	if len([]rune(name)) > 64 {
+		ctx.JSON(http.StatusOK, result.Failed(result.RequestParamError))
+		return
+	}
+	var transports []string
+	if t := ctx.PostForm("transports"); t != "" {
+		transports = strings.Split(t, ",")
+	}
+
+	err := service.FinishWebAuthnRegistration(ctx, uid, name, clientDataJSON, attestationObject, transports)
+	if err != nil {
+		ctx.JSON(http.StatusOK, result.Failed(result.HandleError(err)))
+		return
+	}
+	ctx.JSON(http.StatusOK, result.Success(nil))
+}
+
+// BeginWebAuthnLogin return the options of navigator.credentials.get(),
+// LOGIN-TICKET is optional, without it the user choose a passkey in browser.
+func BeginWebAuthnLogin(ctx *gin.Context) {
+	var uid string
+	if ticket := ctx.GetHeader("LOGIN-TICKET"); ticket != "" {
+		username, err := util.IdentityFromToken(ticket, model.LOGIN_TICKET_SUB)
+		if err != nil || username == "" {
+			ctx.JSON(http.StatusOK, result.Failed(result.HandleErrorWithArgu(err, result.TicketNotCorrect)))
+			return
+		}
+		uid = username
+	}
+
+	options, err := service.BeginWebAuthnLogin(ctx, uid)
+	if err != nil {
+		ctx.JSON(http.StatusOK, result.Failed(result.HandleError(err)))
+		return
+	}
+	ctx.JSON(http.StatusOK, result.Success(options))
+}
+
+// FinishWebAuthnLogin replace the password step of Login
+func FinishWebAuthnLogin(ctx *gin.Context) {
+	credentialID := ctx.PostForm("id")
+	clientDataJSON, err1 := base64.RawURLEncoding.DecodeString(ctx.PostForm("clientDataJSON"))
+	authenticatorData, err2 := base64.RawURLEncoding.DecodeString(ctx.PostForm("authenticatorData"))
+	signature, err3 := base64.RawURLEncoding.DecodeString(ctx.PostForm("signature"))
+	userHandle, err4 := base64.RawURLEncoding.DecodeString(ctx.PostForm("userHandle"))
+	if err1 != nil || err2 != nil || err3 != nil || err4 != nil || credentialID == "" {
+		ctx.JSON(http.StatusOK, result.Failed(result.RequestParamError))
+		return
+	}
+
+	uid, verified, err := service.FinishWebAuthnLogin(ctx, credentialID, clientDataJSON, authenticatorData, signature, userHandle)
+	if err != nil {
+		controllerLogger.Errorf("webauthn login fail: %s", err.Error())
+		ctx.JSON(http.StatusOK, result.Failed(result.HandleError(err)))
+		return
+	}
+
+	// A passkey verified with PIN or biometrics is already multi-factor
+	if !verified && requireTwoFactor(ctx, uid) {
+		return
+	}
+	if !bindOauthTicket(ctx, uid) {
+		return
+	}
+	issueLoginToken(ctx, uid)
+}
+
+// WebAuthnCredentials list passkeys of user
+func WebAuthnCredentials(ctx *gin.Context) {
+	uid := ctx.GetString("uid")
+
+	credentials, err := service.WebAuthnCredentials(uid)
+	if err != nil {
+		ctx.JSON(http.StatusOK, result.Failed(result.HandleError(err)))
+		return
+	}
+	ctx.JSON(http.StatusOK, result.Success(credentials))
+}
+
+func RenameWebAuthnCredential(ctx *gin.Context) {
+	uid := ctx.GetString("uid")
+	id, err := strconv.ParseUint(ctx.PostForm("id"), 10, 0)
+	name := strings.TrimSpace(ctx.PostForm("name"))
+	if err != nil || name == "" || len([]rune(name)) > 64 {
+		ctx.JSON(http.StatusOK, result.Failed(result.RequestParamError))
+		return
+	}
+
+	if err := service.RenameWebAuthnCredential(uid, uint(id), name); err != nil {
+		ctx.JSON(http.StatusOK, result.Failed(result.HandleError(err)))
+		return
+	}
+	ctx.JSON(http.StatusOK, result.Success(nil))
+}
+
+func DeleteWebAuthnCredential(ctx *gin.Context) {
+	uid := ctx.GetString("uid")
+	id, err := strconv.ParseUint(ctx.PostForm("id"), 10, 0)
+	if err != nil {
+		ctx.JSON(http.StatusOK, result.Failed(result.RequestParamError))
+		return
+	}
+
+	if err := service.DeleteWebAuthnCredential(uid, uint(id)); err != nil {
+		ctx.JSON(http.StatusOK, result.Failed(result.HandleError(err)))
+		return
+	}
+	ctx.JSON(http.StatusOK, result.Success(nil))
+}
@@ -27,6 +27,7 @@ require (
 	github.com/tidwall/gjson v1.12.1
 	github.com/vgarvardt/go-oauth2-pg/v4 v4.4.3
 	github.com/vgarvardt/go-pg-adapter v1.0.0
+	golang.org/x/crypto v0.22.0
 	golang.org/x/oauth2 v0.12.0
 )
 
@@ -92,7 +93,6 @@ require (
 	github.com/ugorji/go/codec v1.2.11 // indirect
 	github.com/vgarvardt/pgx-helpers/v4 v4.0.0-20200225100150-876aee3d1a22 // indirect
 	golang.org/x/arch v0.4.0 // indirect
-	golang.org/x/crypto v0.22.0 // indirect
 	golang.org/x/net v0.21.0 // indirect
 	golang.org/x/sync v0.1.0 // indirect
 	golang.org/x/sys v0.19.0 // indirect
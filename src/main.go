@@ -1,11 +1,16 @@
 package main
 
 import (
+	"context"
+
 	"github.com/NJUPT-SAST/sast-link-backend/log"
 	"github.com/NJUPT-SAST/sast-link-backend/router"
+	"github.com/NJUPT-SAST/sast-link-backend/service"
 )
 
 func main() {
+	service.StartAccountPurger(context.Background())
+	service.StartBackchannelLogout(context.Background())
 	router := router.InitRouter()
 	// _ = router.Run()
 	log.Log.Errorln(router.Run())
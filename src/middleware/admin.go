@@ -0,0 +1,25 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/NJUPT-SAST/sast-link-backend/model/result"
+	"github.com/NJUPT-SAST/sast-link-backend/service"
+	"github.com/gin-gonic/gin"
+)
+
+// Admin only let users in the admin table pass, use it after JWT
+func Admin(c *gin.Context) {
+	uid := c.GetString("uid")
+	isAdmin, err := service.IsAdmin(uid)
+	if err != nil {
+		middlewareLogger.Errorf("check admin fail: %s", err.Error())
+		c.AbortWithStatusJSON(http.StatusOK, result.Failed(result.InternalErr))
+		return
+	}
+	if !isAdmin {
+		c.AbortWithStatusJSON(http.StatusOK, result.Failed(result.PermissionDenied))
+		return
+	}
+	c.Next()
+}
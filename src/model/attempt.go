@@ -0,0 +1,64 @@
+package model
+
+import (
+	"context"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// increase the counter and start its window on the first failure
+var recordFailureScript = redis.NewScript(`
+local n = redis.call("INCR", KEYS[1])
+if n == 1 then
+	redis.call("PEXPIRE", KEYS[1], ARGV[1])
+end
+return n
+`)
+
+// like recordFailureScript, also return the remaining window
+var countRequestScript = redis.NewScript(`
+local n = redis.call("INCR", KEYS[1])
+if n == 1 then
+	redis.call("PEXPIRE", KEYS[1], ARGV[1])
+end
+return {n, redis.call("PTTL", KEYS[1])}
+`)
+
+// CountRequest count a request of key in a fixed window,
+// return the count and the time left in the window.
+func CountRequest(ctx context.Context, key string, window time.Duration) (int64, time.Duration, error) {
+	res, err := countRequestScript.Run(ctx, Rdb, []string{RequestCountKey(key)}, window.Milliseconds()).Int64Slice()
+	if err != nil {
+		return 0, 0, err
+	}
+	return res[0], time.Duration(res[1]) * time.Millisecond, nil
+}
+
+// RecordFailedAttempt count a failure of key, the counter is reset after window
+func RecordFailedAttempt(ctx context.Context, key string, window time.Duration) (int64, error) {
+	return recordFailureScript.Run(ctx, Rdb, []string{FailedAttemptsKey(key)}, window.Milliseconds()).Int64()
+}
+
+// ResetFailedAttempts clear the counter of key after a success
+func ResetFailedAttempts(ctx context.Context, key string) error {
+	return Rdb.Del(ctx, FailedAttemptsKey(key)).Err()
+}
+
+// Lockout lock key for d
+func Lockout(ctx context.Context, key string, d time.Duration) error {
+	return Rdb.Set(ctx, LockoutKey(key), 1, d).Err()
+}
+
+// LockoutRemaining return how long key is still locked, 0 if not locked
+func LockoutRemaining(ctx context.Context, key string) (time.Duration, error) {
+	ttl, err := Rdb.PTTL(ctx, LockoutKey(key)).Result()
+	if err != nil {
+		return 0, err
+	}
+	// -2 if not exist, -1 if no expire
+	if ttl < 0 {
+		return 0, nil
+	}
+	return ttl, nil
+}
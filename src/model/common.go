@@ -12,21 +12,41 @@ const (
 	OAUTH_TICKET_EXP    = time.Minute * 3
 	// This is not login token expire time, this is login ticket expire time
 	LOGIN_TICKET_EXP = time.Minute * 5
-	// This is login token expire time
-	LOGIN_TOKEN_EXP    = time.Hour * 24 * 7
-	OAUTH_USER_INFO_EXP = time.Minute * 5
-
-	LARK_CLIENT_TYPE = "lark"
+	// This is login token (access JWT) expire time, renew it with the refresh token
+	LOGIN_TOKEN_EXP = time.Minute * 30
+	// Refresh token expire time, it slides forward on every refresh
+	REFRESH_TOKEN_EXP = time.Hour * 24 * 7
+	// Absolute lifetime of a session, no matter how often it is refreshed
+	SESSION_MAX_AGE = time.Hour * 24 * 30
+	// Minimum interval to update last seen time of session
+	SESSION_TOUCH_INTERVAL = time.Minute
+	OAUTH_USER_INFO_EXP    = time.Minute * 5
+	// Time to enter the TOTP or recovery code after password verified
+	TWO_FACTOR_TICKET_EXP = time.Minute * 5
+	// Time to finish a WebAuthn ceremony
+	WEBAUTHN_CHALLENGE_EXP = time.Minute * 5
+	// Same as the authorization code of go-oauth2
+	OIDC_CODE_EXP = time.Minute * 10
+	// ID token expire time
+	ID_TOKEN_EXP = time.Hour
+	// Time to resume the authorization request after the user decides on the consent screen
+	CONSENT_DECISION_EXP = time.Minute * 5
+	// Time for the user to approve a device, RFC 8628 `expires_in`
+	DEVICE_CODE_EXP = time.Minute * 10
+	// Minimum interval of devices polling the token endpoint, RFC 8628 `interval`
+	DEVICE_POLL_INTERVAL = time.Second * 5
+
+	LARK_CLIENT_TYPE   = "lark"
 	GITHUB_CLIENT_TYPE = "github"
 
-
 	// For JWT
-	LOGIN_TOKEN_SUB     = "loginToken"
-	LOGIN_TICKET_SUB    = "loginTicket"
-	REGIST_TICKET_SUB   = "registerTicket"
-	RESETPWD_TICKET_SUB = "resetPwdTicket"
-	OAUTH_LARK_SUB      = "oauthLarkToken"
-	OAUTH_GITHUB_SUB    = "oauthGithubToken"
+	LOGIN_TOKEN_SUB       = "loginToken"
+	LOGIN_TICKET_SUB      = "loginTicket"
+	REGIST_TICKET_SUB     = "registerTicket"
+	RESETPWD_TICKET_SUB   = "resetPwdTicket"
+	OAUTH_LARK_SUB        = "oauthLarkToken"
+	OAUTH_GITHUB_SUB      = "oauthGithubToken"
+	TWO_FACTOR_TICKET_SUB = "twoFactorTicket"
 )
 
 var (
@@ -47,8 +67,48 @@ func LoginTicketKey(username string) string {
 	return "LOGIN_TICKET:" + username
 }
 
-func LoginTokenKey(username string) string {
-	return "TOKEN:" + username
+// SessionKey save the login session, the session id is the `jti` of login token
+func SessionKey(sid string) string {
+	return "SESSION:" + sid
+}
+
+// UsedRefreshTokenKey save hashes of rotated refresh tokens of session for reuse detection
+func UsedRefreshTokenKey(sid string) string {
+	return "SESSION_USED_REFRESH:" + sid
+}
+
+// UserSessionsKey index the session ids of user
+func UserSessionsKey(uid string) string {
+	return "USER_SESSIONS:" + uid
+}
+
+func TwoFactorTicketKey(username string) string {
+	return "TWO_FACTOR_TICKET:" + username
+}
+
+// TOTPUsedStepKey save the last accepted TOTP time step of user
+func TOTPUsedStepKey(username string) string {
+	return "TOTP_USED_STEP:" + username
+}
+
+// WebAuthnChallengeKey save the ceremony of an unused challenge
+func WebAuthnChallengeKey(challenge string) string {
+	return "WEBAUTHN_CHALLENGE:" + challenge
+}
+
+// FailedAttemptsKey count failures like `login:account:<uid>` in a window
+func FailedAttemptsKey(key string) string {
+	return "FAILED_ATTEMPTS:" + key
+}
+
+// RequestCountKey count requests like `client:<id>` in a window
+func RequestCountKey(key string) string {
+	return "REQUEST_COUNT:" + key
+}
+
+// LockoutKey exists while key is locked
+func LockoutKey(key string) string {
+	return "LOCKOUT:" + key
 }
 
 func CaptchaKey(username string) string {
@@ -72,6 +132,53 @@ func LoginJWTSubKey(username string) string {
 	return fmt.Sprintf("%s-%s", username, LOGIN_TOKEN_SUB)
 }
 
+func TwoFactorTicketJWTSubKey(username string) string {
+	return fmt.Sprintf("%s-%s", username, TWO_FACTOR_TICKET_SUB)
+}
+
+// OIDCCodeKey save nonce and auth_time of an authorization code
+func OIDCCodeKey(code string) string {
+	return "OIDC_CODE:" + code
+}
+
+// ConsentDecisionKey save the latest consent decision of uid for clientID
+func ConsentDecisionKey(uid, clientID string) string {
+	return fmt.Sprintf("CONSENT:%s:%s", uid, clientID)
+}
+
+// SessionClientsKey index the clients which got an ID token in the login session
+func SessionClientsKey(sid string) string {
+	return "SESSION_CLIENTS:" + sid
+}
+
+// BackchannelLogoutKey is the queue of logout tokens to deliver
+const BackchannelLogoutKey = "BACKCHANNEL_LOGOUT"
+
+// UsedOAuthRefreshKey save the grant chain of a rotated OAuth refresh token by its hash
+func UsedOAuthRefreshKey(hash string) string {
+	return "OAUTH_USED_REFRESH:" + hash
+}
+
+// DeviceCodeKey save the device authorization of a device code
+func DeviceCodeKey(deviceCode string) string {
+	return "DEVICE_CODE:" + deviceCode
+}
+
+// DeviceUserCodeKey save the device code of a user code
+func DeviceUserCodeKey(userCode string) string {
+	return "DEVICE_USER_CODE:" + userCode
+}
+
+// RegisterInvitationKey save the invitation code used to get the register ticket
+func RegisterInvitationKey(ticket string) string {
+	return "REGISTER_INVITATION:" + ticket
+}
+
+// ChangeEmailKey save the new email address waiting for verification
+func ChangeEmailKey(uid string) string {
+	return "CHANGE_EMAIL:" + uid
+}
+
 func VerifyCodeKey(username string) string {
 	return "VerifyCode:" + username
 }
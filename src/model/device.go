@@ -0,0 +1,104 @@
+package model
+
+import (
+	"context"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// status of a device authorization
+const (
+	DEVICE_PENDING  = "pending"
+	DEVICE_APPROVED = "approved"
+	DEVICE_DENIED   = "denied"
+)
+
+// DeviceAuthorization is a device authorization request of RFC 8628,
+// saved in redis hash `DEVICE_CODE:<device_code>` until the device gets its token.
+type DeviceAuthorization struct {
+	ClientID string `redis:"client_id"`
+	Scope    string `redis:"scope"`
+	UserCode string `redis:"user_code"`
+	Status   string `redis:"status"`
+	// UserID is the user who approved or denied the device
+	UserID string `redis:"user_id"`
+	// Interval is the polling interval in seconds, increased when the device polls too fast
+	Interval int64 `redis:"interval"`
+	LastPoll int64 `redis:"last_poll"`
+}
+
+// updateDeviceScript set fields of a device authorization unless it expired,
+// HSET alone would recreate the hash without expiration.
+var updateDeviceScript = redis.NewScript(`
+if redis.call("EXISTS", KEYS[1]) == 0 then
+	return 0
+end
+redis.call("HSET", KEYS[1], unpack(ARGV))
+return 1
+`)
+
+// claimDeviceScript delete a device authorization in the status ARGV[1],
+// only one of the concurrent polls gets it.
+var claimDeviceScript = redis.NewScript(`
+if redis.call("HGET", KEYS[1], "status") ~= ARGV[1] then
+	return 0
+end
+redis.call("DEL", KEYS[1], KEYS[2])
+return 1
+`)
+
+// SaveDeviceAuthorization save a new device authorization,
+// return false if the user code is taken.
+func SaveDeviceAuthorization(ctx context.Context, deviceCode string, auth *DeviceAuthorization, exp time.Duration) (bool, error) {
+	ok, err := Rdb.SetNX(ctx, DeviceUserCodeKey(auth.UserCode), deviceCode, exp).Result()
+	if err != nil || !ok {
+		return false, err
+	}
+	pipe := Rdb.TxPipeline()
+	pipe.HSet(ctx, DeviceCodeKey(deviceCode), auth)
+	pipe.Expire(ctx, DeviceCodeKey(deviceCode), exp)
+	_, err = pipe.Exec(ctx)
+	return err == nil, err
+}
+
+// DeviceAuthorizationByCode return nil if the device code does not exist or expired
+func DeviceAuthorizationByCode(ctx context.Context, deviceCode string) (*DeviceAuthorization, error) {
+	cmd := Rdb.HGetAll(ctx, DeviceCodeKey(deviceCode))
+	if err := cmd.Err(); err != nil {
+		return nil, err
+	}
+	if len(cmd.Val()) == 0 {
+		return nil, nil
+	}
+	auth := &DeviceAuthorization{}
+	if err := cmd.Scan(auth); err != nil {
+		return nil, err
+	}
+	return auth, nil
+}
+
+// DeviceCodeByUserCode return empty string if the user code does not exist or expired
+func DeviceCodeByUserCode(ctx context.Context, userCode string) (string, error) {
+	deviceCode, err := Rdb.Get(ctx, DeviceUserCodeKey(userCode)).Result()
+	if err == redis.Nil {
+		return "", nil
+	}
+	return deviceCode, err
+}
+
+// UpdateDeviceAuthorization set fields of the device authorization, the expiration is kept,
+// return false if the device code expired.
+func UpdateDeviceAuthorization(ctx context.Context, deviceCode string, fields map[string]interface{}) (bool, error) {
+	args := make([]interface{}, 0, len(fields)*2)
+	for field, value := range fields {
+		args = append(args, field, value)
+	}
+	return updateDeviceScript.Run(ctx, Rdb, []string{DeviceCodeKey(deviceCode)}, args...).Bool()
+}
+
+// ClaimDeviceAuthorization delete the device authorization if it is in status,
+// return false if it is not or another request claimed it first.
+func ClaimDeviceAuthorization(ctx context.Context, deviceCode, userCode, status string) (bool, error) {
+	return claimDeviceScript.Run(ctx, Rdb, []string{DeviceCodeKey(deviceCode), DeviceUserCodeKey(userCode)}, status).Bool()
+}
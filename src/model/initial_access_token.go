@@ -0,0 +1,76 @@
+package model
+
+import (
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// InitialAccessToken let a project register OAuth clients by RFC 7591,
+// it can register MaxUses clients before ExpiresAt.
+type InitialAccessToken struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	TokenHash string    `json:"-" gorm:"not null"`
+	CreatedBy string    `json:"created_by" gorm:"not null"`
+	Note      string    `json:"note"`
+	MaxUses   int       `json:"max_uses" gorm:"not null"`
+	UsedCount int       `json:"used_count" gorm:"not null"`
+	ExpiresAt time.Time `json:"expires_at" gorm:"not null"`
+	CreatedAt time.Time `json:"created_at" gorm:"not null"`
+}
+
+func CreateInitialAccessToken(token *InitialAccessToken) error {
+	return Db.Table("oauth2_initial_access_token").Create(token).Error
+}
+
+// InitialAccessTokenByHash return nil if the token does not exist
+func InitialAccessTokenByHash(hash string) (*InitialAccessToken, error) {
+	var token InitialAccessToken
+	err := Db.Table("oauth2_initial_access_token").Where("token_hash = ?", hash).First(&token).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &token, nil
+}
+
+// InitialAccessTokens list initial access tokens, the latest first
+func InitialAccessTokens() ([]InitialAccessToken, error) {
+	var tokens []InitialAccessToken
+	err := Db.Table("oauth2_initial_access_token").Order("id desc").Find(&tokens).Error
+	return tokens, err
+}
+
+// CreateRegisteredOAuthClient create the client registered with the initial access token
+// of client.InitialAccessTokenID and count the registration in one transaction,
+// return false if the token is used up or expired.
+func CreateRegisteredOAuthClient(client *OAuthClient) (bool, error) {
+	item, err := client.item()
+	if err != nil {
+		return false, err
+	}
+	created := false
+	err = Db.Transaction(func(tx *gorm.DB) error {
+		res := tx.Table("oauth2_initial_access_token").
+			Where("id = ? AND used_count < max_uses AND expires_at > ?", client.InitialAccessTokenID, time.Now()).
+			Update("used_count", gorm.Expr("used_count + 1"))
+		if res.Error != nil || res.RowsAffected == 0 {
+			return res.Error
+		}
+		if err := tx.Table("oauth2_clients").Create(item).Error; err != nil {
+			return err
+		}
+		created = true
+		return nil
+	})
+	return created && err == nil, err
+}
+
+// ExpireInitialAccessToken make the token unusable from now on
+func ExpireInitialAccessToken(id uint) (bool, error) {
+	res := Db.Table("oauth2_initial_access_token").Where("id = ? AND expires_at > ?", id, time.Now()).Update("expires_at", time.Now())
+	return res.RowsAffected > 0, res.Error
+}
@@ -0,0 +1,98 @@
+package model
+
+import (
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Invitation let people outside the account policy register,
+// it can be used MaxUses times before ExpiresAt.
+type Invitation struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	Code      string    `json:"code" gorm:"not null"`
+	CreatedBy string    `json:"created_by" gorm:"not null"`
+	Note      string    `json:"note"`
+	OrgId     *int      `json:"org_id"`
+	MaxUses   int       `json:"max_uses" gorm:"not null"`
+	UsedCount int       `json:"used_count" gorm:"not null"`
+	ExpiresAt time.Time `json:"expires_at" gorm:"not null"`
+	CreatedAt time.Time `json:"created_at" gorm:"not null"`
+
+	Redemptions []InvitationRedemption `json:"redemptions" gorm:"-"`
+}
+
+// InvitationRedemption is a registration with an invitation
+type InvitationRedemption struct {
+	ID           uint      `json:"-" gorm:"primaryKey"`
+	InvitationID uint      `json:"-" gorm:"not null"`
+	Uid          string    `json:"uid" gorm:"not null"`
+	Email        string    `json:"email" gorm:"not null"`
+	RedeemedAt   time.Time `json:"redeemed_at" gorm:"not null"`
+}
+
+func CreateInvitation(invitation *Invitation) error {
+	return Db.Table("invitation").Create(invitation).Error
+}
+
+// InvitationByCode return nil if the code does not exist
+func InvitationByCode(code string) (*Invitation, error) {
+	var invitation Invitation
+	err := Db.Table("invitation").Where("code = ?", code).First(&invitation).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &invitation, nil
+}
+
+// Invitations list invitations with their redemptions, the latest first
+func Invitations() ([]Invitation, error) {
+	var invitations []Invitation
+	if err := Db.Table("invitation").Order("id desc").Find(&invitations).Error; err != nil {
+		return nil, err
+	}
+	var redemptions []InvitationRedemption
+	if err := Db.Table("invitation_redemption").Order("id").Find(&redemptions).Error; err != nil {
+		return nil, err
+	}
+	byInvitation := make(map[uint][]InvitationRedemption)
+	for _, r := range redemptions {
+		byInvitation[r.InvitationID] = append(byInvitation[r.InvitationID], r)
+	}
+	for i := range invitations {
+		invitations[i].Redemptions = byInvitation[invitations[i].ID]
+		if invitations[i].Redemptions == nil {
+			invitations[i].Redemptions = []InvitationRedemption{}
+		}
+	}
+	return invitations, nil
+}
+
+// ExpireInvitation make the invitation unusable from now on
+func ExpireInvitation(id uint) (bool, error) {
+	res := Db.Table("invitation").Where("id = ? AND expires_at > ?", id, time.Now()).Update("expires_at", time.Now())
+	return res.RowsAffected > 0, res.Error
+}
+
+// redeemInvitation take one use of the invitation in tx,
+// return nil if it is expired or used up.
+func redeemInvitation(tx *gorm.DB, code string) (*Invitation, error) {
+	res := tx.Table("invitation").
+		Where("code = ? AND used_count < max_uses AND expires_at > ?", code, time.Now()).
+		Update("used_count", gorm.Expr("used_count + 1"))
+	if res.Error != nil {
+		return nil, res.Error
+	}
+	if res.RowsAffected == 0 {
+		return nil, nil
+	}
+	var invitation Invitation
+	if err := tx.Table("invitation").Where("code = ?", code).First(&invitation).Error; err != nil {
+		return nil, err
+	}
+	return &invitation, nil
+}
@@ -0,0 +1,62 @@
+package model
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// BackchannelLogout is a logout of session Sid to notify the client,
+// queued in the redis sorted set `BACKCHANNEL_LOGOUT` by the time of the next attempt.
+type BackchannelLogout struct {
+	// ID keeps the same logout queued twice apart
+	ID       string `json:"id"`
+	ClientID string `json:"client_id"`
+	UserID   string `json:"user_id"`
+	Sid      string `json:"sid"`
+	Attempts int    `json:"attempts"`
+}
+
+// take the due logouts off the queue, so that only one server delivers them
+var popBackchannelLogoutsScript = redis.NewScript(`
+local logouts = redis.call("ZRANGEBYSCORE", KEYS[1], "-inf", ARGV[1], "LIMIT", 0, ARGV[2])
+if #logouts > 0 then
+	redis.call("ZREM", KEYS[1], unpack(logouts))
+end
+return logouts
+`)
+
+// EnqueueBackchannelLogouts queue logouts to deliver at
+func EnqueueBackchannelLogouts(ctx context.Context, at time.Time, logouts ...*BackchannelLogout) error {
+	if len(logouts) == 0 {
+		return nil
+	}
+	members := make([]redis.Z, 0, len(logouts))
+	for _, logout := range logouts {
+		b, err := json.Marshal(logout)
+		if err != nil {
+			return err
+		}
+		members = append(members, redis.Z{Score: float64(at.Unix()), Member: b})
+	}
+	return Rdb.ZAdd(ctx, BackchannelLogoutKey, members...).Err()
+}
+
+// PopBackchannelLogouts take at most limit logouts due at now off the queue
+func PopBackchannelLogouts(ctx context.Context, now time.Time, limit int) ([]BackchannelLogout, error) {
+	members, err := popBackchannelLogoutsScript.Run(ctx, Rdb, []string{BackchannelLogoutKey}, now.Unix(), limit).StringSlice()
+	if err != nil {
+		return nil, err
+	}
+	logouts := make([]BackchannelLogout, 0, len(members))
+	for _, member := range members {
+		var logout BackchannelLogout
+		if err := json.Unmarshal([]byte(member), &logout); err != nil {
+			return nil, err
+		}
+		logouts = append(logouts, logout)
+	}
+	return logouts, nil
+}
@@ -0,0 +1,223 @@
+package model
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/go-oauth2/oauth2/v4"
+	"gorm.io/gorm"
+)
+
+// review status of dynamically registered clients, clients created by members
+// have no status and are approved.
+const (
+	CLIENT_PENDING  = "pending"
+	CLIENT_APPROVED = "approved"
+	CLIENT_REJECTED = "rejected"
+)
+
+// OAuthClient is an application using SAST Link as its OAuth provider,
+// saved as JSON in the `data` column of the oauth2_clients table.
+// The fields without tag are compatible with the models.Client
+// saved by go-oauth2-pg, the others are metadata of the client.
+type OAuthClient struct {
+	ID     string
+	Secret string
+	// Domain is the first of RedirectURIs, go-oauth2 redirects to it by default
+	Domain string
+	Public bool
+	UserID string
+
+	// Name and Logo are shown to users on the consent screen
+	Name        string `json:"name,omitempty"`
+	Logo        string `json:"logo,omitempty"`
+	Description string `json:"description,omitempty"`
+	Homepage    string `json:"homepage,omitempty"`
+	// RedirectURIs the client can redirect to, only Domain if empty.
+	// They are compared exactly, LoopbackRedirect ignores the port of loopback URIs.
+	RedirectURIs     []string `json:"redirect_uris,omitempty"`
+	LoopbackRedirect bool     `json:"loopback_redirect,omitempty"`
+	// PostLogoutRedirectURIs the client can be redirected to after RP-initiated logout
+	PostLogoutRedirectURIs []string `json:"post_logout_redirect_uris,omitempty"`
+	// BackchannelLogoutURI receives logout tokens when login sessions of its users end
+	BackchannelLogoutURI string `json:"backchannel_logout_uri,omitempty"`
+	// GrantTypes the client is allowed to use, no restriction if empty
+	GrantTypes []string `json:"grant_types,omitempty"`
+	// RequirePKCE let confidential clients opt in to mandatory PKCE,
+	// it is always required for public clients.
+	RequirePKCE bool `json:"require_pkce,omitempty"`
+	// Scopes the client is allowed to request, all registered scopes if empty
+	Scopes []string `json:"scopes,omitempty"`
+	// AccessTokenTTL and RefreshTokenTTL are the lifetime of tokens in seconds,
+	// the default of the grant if zero.
+	AccessTokenTTL  int64 `json:"access_token_ttl,omitempty"`
+	RefreshTokenTTL int64 `json:"refresh_token_ttl,omitempty"`
+	// RateLimit is the requests per minute to the token and userinfo endpoints,
+	// `oauth.server.rate_limit` if zero. The TTLs and it are set by admins.
+	RateLimit int64 `json:"rate_limit,omitempty"`
+	// PreviousSecret is still accepted until PreviousSecretExpiresAt after rotation
+	PreviousSecret          string     `json:"previous_secret,omitempty"`
+	PreviousSecretExpiresAt *time.Time `json:"previous_secret_expires_at,omitempty"`
+	// Status is the admin review of clients registered by RFC 7591
+	Status string `json:"status,omitempty"`
+	// InitialAccessTokenID is the token the client is registered with,
+	// RegistrationTokenHash is the SHA-256 of its registration access token.
+	InitialAccessTokenID  uint       `json:"initial_access_token_id,omitempty"`
+	RegistrationTokenHash string     `json:"registration_token_hash,omitempty"`
+	CreatedAt             *time.Time `json:"created_at,omitempty"`
+	UpdatedAt             *time.Time `json:"updated_at,omitempty"`
+}
+
+func (c *OAuthClient) GetID() string {
+	return c.ID
+}
+
+func (c *OAuthClient) GetSecret() string {
+	return c.Secret
+}
+
+// VerifyPassword implement oauth2.ClientPasswordVerifier,
+// the previous secret is accepted during the overlap window of rotation.
+func (c *OAuthClient) VerifyPassword(secret string) bool {
+	if subtle.ConstantTimeCompare([]byte(secret), []byte(c.Secret)) == 1 {
+		return true
+	}
+	return c.PreviousSecret != "" &&
+		c.PreviousSecretExpiresAt != nil && time.Now().Before(*c.PreviousSecretExpiresAt) &&
+		subtle.ConstantTimeCompare([]byte(secret), []byte(c.PreviousSecret)) == 1
+}
+
+func (c *OAuthClient) GetDomain() string {
+	return c.Domain
+}
+
+func (c *OAuthClient) IsPublic() bool {
+	return c.Public
+}
+
+func (c *OAuthClient) GetUserID() string {
+	return c.UserID
+}
+
+type oauthClientItem struct {
+	ID     string
+	Secret string
+	Domain string
+	Data   []byte
+}
+
+// OAuthClientStore is the oauth2.ClientStore of the oauth server,
+// unlike go-oauth2-pg, it keeps the metadata of clients.
+type OAuthClientStore struct{}
+
+// GetByID return nil if the client does not exist
+func (OAuthClientStore) GetByID(ctx context.Context, id string) (oauth2.ClientInfo, error) {
+	client, err := OAuthClientByID(ctx, id)
+	if err != nil || client == nil {
+		return nil, err
+	}
+	return client, nil
+}
+
+// OAuthClientByID return nil if the client does not exist
+func OAuthClientByID(ctx context.Context, id string) (*OAuthClient, error) {
+	var item oauthClientItem
+	err := Db.WithContext(ctx).Table("oauth2_clients").Where("id = ?", id).First(&item).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var client OAuthClient
+	if err := json.Unmarshal(item.Data, &client); err != nil {
+		return nil, err
+	}
+	return &client, nil
+}
+
+// OAuthClientsOf list clients created by uid
+func OAuthClientsOf(uid string) ([]OAuthClient, error) {
+	var items []oauthClientItem
+	err := Db.Table("oauth2_clients").Where("data->>'UserID' = ?", uid).Find(&items).Error
+	if err != nil {
+		return nil, err
+	}
+	return decodeOAuthClients(items)
+}
+
+// OAuthClientsByStatus list registered clients in the review status
+func OAuthClientsByStatus(status string) ([]OAuthClient, error) {
+	var items []oauthClientItem
+	err := Db.Table("oauth2_clients").Where("data->>'status' = ?", status).Find(&items).Error
+	if err != nil {
+		return nil, err
+	}
+	return decodeOAuthClients(items)
+}
+
+func decodeOAuthClients(items []oauthClientItem) ([]OAuthClient, error) {
+	clients := make([]OAuthClient, 0, len(items))
+	for _, item := range items {
+		var client OAuthClient
+		if err := json.Unmarshal(item.Data, &client); err != nil {
+			return nil, err
+		}
+		clients = append(clients, client)
+	}
+	return clients, nil
+}
+
+func CreateOAuthClient(client *OAuthClient) error {
+	item, err := client.item()
+	if err != nil {
+		return err
+	}
+	return Db.Table("oauth2_clients").Create(item).Error
+}
+
+func UpdateOAuthClient(client *OAuthClient) error {
+	item, err := client.item()
+	if err != nil {
+		return err
+	}
+	return Db.Table("oauth2_clients").Where("id = ?", client.ID).Updates(map[string]interface{}{
+		"secret": item.Secret,
+		"domain": item.Domain,
+		"data":   item.Data,
+	}).Error
+}
+
+// RevokeClientTokens delete all tokens issued to the client
+func RevokeClientTokens(clientID string) error {
+	return Db.Exec("DELETE FROM oauth2_tokens WHERE data->>'ClientID' = ?", clientID).Error
+}
+
+// DeleteOAuthClient delete the client with its tokens and grants
+func DeleteOAuthClient(clientID string) error {
+	return Db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec("DELETE FROM oauth2_tokens WHERE data->>'ClientID' = ?", clientID).Error; err != nil {
+			return err
+		}
+		if err := tx.Table("oauth2_grant").Where("client_id = ?", clientID).Delete(&OAuthGrant{}).Error; err != nil {
+			return err
+		}
+		return tx.Table("oauth2_clients").Where("id = ?", clientID).Delete(&oauthClientItem{}).Error
+	})
+}
+
+func (c *OAuthClient) item() (*oauthClientItem, error) {
+	data, err := json.Marshal(c)
+	if err != nil {
+		return nil, err
+	}
+	return &oauthClientItem{
+		ID:     c.ID,
+		Secret: c.Secret,
+		Domain: c.Domain,
+		Data:   data,
+	}, nil
+}
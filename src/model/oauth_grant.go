@@ -0,0 +1,143 @@
+package model
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// OAuthGrant is the scopes a user has consented a client to access,
+// later authorizations within them skip the consent screen.
+type OAuthGrant struct {
+	ID        uint      `json:"-" gorm:"primaryKey"`
+	UserID    string    `json:"-" gorm:"not null"`
+	ClientID  string    `json:"client_id" gorm:"not null"`
+	Scope     string    `json:"scope" gorm:"not null"`
+	CreatedAt time.Time `json:"created_at" gorm:"not null"`
+	UpdatedAt time.Time `json:"updated_at" gorm:"not null"`
+	// LastUsedAt is updated when the client reads userinfo with its token
+	LastUsedAt *time.Time `json:"last_used_at"`
+}
+
+// OAuthTokenUsage summarize the tokens a client holds for a user
+type OAuthTokenUsage struct {
+	ClientID      string
+	Scope         string
+	FirstIssuedAt time.Time
+	LastIssuedAt  time.Time
+}
+
+// ConsentDecision is what the user chose on the consent screen,
+// saved in redis hash `CONSENT:<uid>:<client_id>` for the retried authorization request.
+type ConsentDecision struct {
+	Scope    string `redis:"scope"`
+	Approved bool   `redis:"approved"`
+}
+
+// OAuthGrantOf return nil if uid has not consented clientID
+func OAuthGrantOf(uid, clientID string) (*OAuthGrant, error) {
+	var grant OAuthGrant
+	err := Db.Table("oauth2_grant").Where("user_id = ? AND client_id = ?", uid, clientID).First(&grant).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &grant, nil
+}
+
+// SaveOAuthGrant replace the scope consented by uid for clientID
+func SaveOAuthGrant(uid, clientID, scope string) error {
+	now := time.Now()
+	return Db.Table("oauth2_grant").Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}, {Name: "client_id"}},
+		DoUpdates: clause.Assignments(map[string]interface{}{"scope": scope, "updated_at": now}),
+	}).Create(&OAuthGrant{
+		UserID:    uid,
+		ClientID:  clientID,
+		Scope:     scope,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}).Error
+}
+
+// OAuthGrantsOf list clients uid has consented
+func OAuthGrantsOf(uid string) ([]OAuthGrant, error) {
+	var grants []OAuthGrant
+	err := Db.Table("oauth2_grant").Where("user_id = ?", uid).Order("created_at").Find(&grants).Error
+	return grants, err
+}
+
+// TouchOAuthGrant record clientID used the authorization of uid
+func TouchOAuthGrant(uid, clientID string) error {
+	return Db.Table("oauth2_grant").
+		Where("user_id = ? AND client_id = ?", uid, clientID).
+		Update("last_used_at", time.Now()).Error
+}
+
+// OAuthTokenUsages group the access tokens of uid by client, scopes of the tokens
+// are joined by space, see go-oauth2-pg TokenStore for the oauth2_tokens table.
+func OAuthTokenUsages(uid string) ([]OAuthTokenUsage, error) {
+	var usages []OAuthTokenUsage
+	err := Db.Raw(`
+		SELECT data->>'ClientID' AS client_id,
+		       string_agg(DISTINCT data->>'Scope', ' ') AS scope,
+		       min(created_at) AS first_issued_at,
+		       max(created_at) AS last_issued_at
+		FROM oauth2_tokens
+		WHERE data->>'UserID' = ? AND access <> ''
+		GROUP BY data->>'ClientID'
+	`, uid).Scan(&usages).Error
+	return usages, err
+}
+
+// RevokeOAuthClient delete the tokens and the grant uid gave clientID
+func RevokeOAuthClient(uid, clientID string) error {
+	return Db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec("DELETE FROM oauth2_tokens WHERE data->>'UserID' = ? AND data->>'ClientID' = ?", uid, clientID).Error; err != nil {
+			return err
+		}
+		return tx.Table("oauth2_grant").Where("user_id = ? AND client_id = ?", uid, clientID).Delete(&OAuthGrant{}).Error
+	})
+}
+
+// RevokeOAuthTokens delete the tokens uid gave clientID and keep the grant
+func RevokeOAuthTokens(uid, clientID string) error {
+	return Db.Exec("DELETE FROM oauth2_tokens WHERE data->>'UserID' = ? AND data->>'ClientID' = ?", uid, clientID).Error
+}
+
+func DeleteConsentDecision(ctx context.Context, uid, clientID string) error {
+	return Rdb.Del(ctx, ConsentDecisionKey(uid, clientID)).Err()
+}
+
+func SaveConsentDecision(ctx context.Context, uid, clientID string, decision *ConsentDecision, exp time.Duration) error {
+	pipe := Rdb.TxPipeline()
+	pipe.Del(ctx, ConsentDecisionKey(uid, clientID))
+	pipe.HSet(ctx, ConsentDecisionKey(uid, clientID), decision)
+	pipe.Expire(ctx, ConsentDecisionKey(uid, clientID), exp)
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// TakeConsentDecision return and forget the consent decision,
+// nil if the user has not decided recently.
+func TakeConsentDecision(ctx context.Context, uid, clientID string) (*ConsentDecision, error) {
+	pipe := Rdb.TxPipeline()
+	cmd := pipe.HGetAll(ctx, ConsentDecisionKey(uid, clientID))
+	pipe.Del(ctx, ConsentDecisionKey(uid, clientID))
+	if _, err := pipe.Exec(ctx); err != nil {
+		return nil, err
+	}
+	if len(cmd.Val()) == 0 {
+		return nil, nil
+	}
+	decision := &ConsentDecision{}
+	if err := cmd.Scan(decision); err != nil {
+		return nil, err
+	}
+	return decision, nil
+}
@@ -0,0 +1,47 @@
+package model
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// UsedOAuthRefresh is the grant chain of a rotated OAuth refresh token,
+// saved in redis `OAUTH_USED_REFRESH:<hash>` to detect the token presented again.
+type UsedOAuthRefresh struct {
+	ClientID string `json:"client_id"`
+	UserID   string `json:"user_id"`
+}
+
+// ClaimOAuthRefresh mark the refresh token of hash used until exp,
+// return false if it has been used.
+func ClaimOAuthRefresh(ctx context.Context, hash string, used *UsedOAuthRefresh, exp time.Duration) (bool, error) {
+	b, err := json.Marshal(used)
+	if err != nil {
+		return false, err
+	}
+	return Rdb.SetNX(ctx, UsedOAuthRefreshKey(hash), b, exp).Result()
+}
+
+// UsedOAuthRefreshByHash return nil if the refresh token of hash has not been used
+func UsedOAuthRefreshByHash(ctx context.Context, hash string) (*UsedOAuthRefresh, error) {
+	b, err := Rdb.Get(ctx, UsedOAuthRefreshKey(hash)).Bytes()
+	if err != nil {
+		if err == redis.Nil {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var used UsedOAuthRefresh
+	if err := json.Unmarshal(b, &used); err != nil {
+		return nil, err
+	}
+	return &used, nil
+}
+
+// ReleaseOAuthRefresh forget the claim of a refresh token which failed to rotate
+func ReleaseOAuthRefresh(ctx context.Context, hash string) error {
+	return Rdb.Del(ctx, UsedOAuthRefreshKey(hash)).Err()
+}
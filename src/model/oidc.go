@@ -0,0 +1,43 @@
+package model
+
+import (
+	"context"
+	"time"
+)
+
+// OIDCAuthorization is the authentication context of an authorization code,
+// saved in redis hash `OIDC_CODE:<code>` until the code is exchanged.
+type OIDCAuthorization struct {
+	Nonce    string `redis:"nonce"`
+	AuthTime int64  `redis:"auth_time"`
+	// Sid is the login session, its clients are notified when it logs out
+	Sid string `redis:"sid"`
+}
+
+func SaveOIDCAuthorization(ctx context.Context, code string, auth *OIDCAuthorization, exp time.Duration) error {
+	pipe := Rdb.TxPipeline()
+	pipe.HSet(ctx, OIDCCodeKey(code), auth)
+	pipe.Expire(ctx, OIDCCodeKey(code), exp)
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// OIDCAuthorizationByCode return nil if code is not issued for OpenID Connect
+func OIDCAuthorizationByCode(ctx context.Context, code string) (*OIDCAuthorization, error) {
+	cmd := Rdb.HGetAll(ctx, OIDCCodeKey(code))
+	if err := cmd.Err(); err != nil {
+		return nil, err
+	}
+	if len(cmd.Val()) == 0 {
+		return nil, nil
+	}
+	auth := &OIDCAuthorization{}
+	if err := cmd.Scan(auth); err != nil {
+		return nil, err
+	}
+	return auth, nil
+}
+
+func DeleteOIDCAuthorization(ctx context.Context, code string) error {
+	return Rdb.Del(ctx, OIDCCodeKey(code)).Err()
+}
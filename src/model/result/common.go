@@ -1,6 +1,10 @@
 package result
 
-import "fmt"
+import (
+	"fmt"
+	"math"
+	"time"
+)
 
 type LocalError struct {
 	ErrCode int
@@ -13,6 +17,21 @@ func (e LocalError) Error() string {
 	return fmt.Sprintf("ErrCode: %d, ErrMsg: %s, Err: %v", e.ErrCode, e.ErrMsg, e.Err)
 }
 
+// LockedError means too many failed attempts,
+// the client should retry after RetryAfter.
+type LockedError struct {
+	RetryAfter time.Duration
+}
+
+func (e LockedError) Error() string {
+	return fmt.Sprintf("ErrCode: %d, ErrMsg: %s, RetryAfter: %v", TooManyAttempts.ErrCode, TooManyAttempts.ErrMsg, e.RetryAfter)
+}
+
+// RetryAfterSeconds round RetryAfter up to seconds for `Retry-After` header
+func (e LockedError) RetryAfterSeconds() int64 {
+	return int64(math.Ceil(e.RetryAfter.Seconds()))
+}
+
 // create common error
 var (
 	RequestParamError  = LocalError{ErrCode: 10001, ErrMsg: "请求参数错误"}
@@ -30,6 +49,16 @@ var (
 	DeleteUserFail     = LocalError{ErrCode: 10014, ErrMsg: "删除用户失败"}
 	GetUserinfoFail    = LocalError{ErrCode: 10015, ErrMsg: "获取用户信息失败"}
 	UserIsExist        = LocalError{ErrCode: 10016, ErrMsg: "用户已存在"}
+	TwoFactorRequired  = LocalError{ErrCode: 10017, ErrMsg: "需要二次验证"}
+	TwoFactorCodeError = LocalError{ErrCode: 10018, ErrMsg: "二次验证码错误"}
+	TwoFactorNotEnable = LocalError{ErrCode: 10019, ErrMsg: "未启用二次验证"}
+	TwoFactorEnabled   = LocalError{ErrCode: 10020, ErrMsg: "已启用二次验证"}
+	WebAuthnError      = LocalError{ErrCode: 10021, ErrMsg: "通行密钥验证失败"}
+	WebAuthnNotFound   = LocalError{ErrCode: 10022, ErrMsg: "通行密钥不存在"}
+	SessionNotExist    = LocalError{ErrCode: 10023, ErrMsg: "会话不存在"}
+	LoginRefreshError  = LocalError{ErrCode: 10024, ErrMsg: "刷新令牌无效"}
+	DeletionNotPending = LocalError{ErrCode: 10025, ErrMsg: "账户未申请注销"}
+	PermissionDenied   = LocalError{ErrCode: 10026, ErrMsg: "没有权限"}
 
 	AuthCheckTokenTimeout = LocalError{ErrCode: 20002, ErrMsg: "Token已超时"}
 	GenerateToken         = LocalError{ErrCode: 20003, ErrMsg: "Token生成失败"}
@@ -38,17 +67,31 @@ var (
 	TicketNotCorrect      = LocalError{ErrCode: 20007, ErrMsg: "Ticket不正确"}
 	CheckTicketNotfound   = LocalError{ErrCode: 20008, ErrMsg: "Ticket不存在"}
 	InvalidAccToken       = LocalError{ErrCode: 20009, ErrMsg: "无效的token"}
+	ReauthRequired        = LocalError{ErrCode: 20010, ErrMsg: "需要重新登录"}
 
 	SendEmailError      = LocalError{ErrCode: 30001, ErrMsg: "发送邮件失败"}
 	CaptchaError        = LocalError{ErrCode: 30002, ErrMsg: "验证码错误"}
 	UserEmailError      = LocalError{ErrCode: 30003, ErrMsg: "邮箱格式错误"}
+	CaptchaExpired      = LocalError{ErrCode: 30004, ErrMsg: "验证码已失效，请重新获取"}
+	EmailIsUsed         = LocalError{ErrCode: 30005, ErrMsg: "邮箱已被使用"}
+	EmailNotAllowed     = LocalError{ErrCode: 30006, ErrMsg: "该邮箱无法使用SAST-Link"}
+	InviteRequired      = LocalError{ErrCode: 30007, ErrMsg: "该邮箱需要邀请码注册"}
+	InvitationInvalid   = LocalError{ErrCode: 30008, ErrMsg: "邀请码无效或已过期"}
 	VerifyAccountError  = LocalError{ErrCode: 40001, ErrMsg: "验证账户失败"}
 	VerifyPasswordError = LocalError{ErrCode: 40002, ErrMsg: "验证账户密码失败"}
+	TooManyAttempts     = LocalError{ErrCode: 40003, ErrMsg: "尝试次数过多，请稍后再试"}
 	// this is default error
 	InternalErr           = LocalError{ErrCode: 50000, ErrMsg: "未知错误"}
 	ClientErr             = LocalError{ErrCode: 60001, ErrMsg: "客户端错误"}
 	AccessTokenErr        = LocalError{ErrCode: 60002, ErrMsg: "access_token错误"}
 	RefreshTokenErr       = LocalError{ErrCode: 60003, ErrMsg: "refresh_token错误"}
+	InvalidScope          = LocalError{ErrCode: 60004, ErrMsg: "scope不合法"}
+	ConsentRequired       = LocalError{ErrCode: 60005, ErrMsg: "需要用户同意授权"}
+	ClientNotExist        = LocalError{ErrCode: 60006, ErrMsg: "客户端不存在"}
+	UserCodeInvalid       = LocalError{ErrCode: 60007, ErrMsg: "设备码无效或已过期"}
+	ServiceAccountExist   = LocalError{ErrCode: 60008, ErrMsg: "服务账号已存在"}
+	InvalidRedirectURI    = LocalError{ErrCode: 60009, ErrMsg: "redirect_uri未注册"}
+	InvalidIDTokenHint    = LocalError{ErrCode: 60010, ErrMsg: "id_token_hint无效"}
 	RegisterPhaseError    = LocalError{ErrCode: 70003, ErrMsg: "注册失败 （！！！！hack？？？？）"}
 	ResetPasswordEror     = LocalError{ErrCode: 70004, ErrMsg: "重置密码失败 （！！！！hack？？？？）"}
 	AlreadySetPasswordErr = LocalError{ErrCode: 70004, ErrMsg: "重复设置密码"}
@@ -75,6 +118,16 @@ var errorMap = map[int]LocalError{
 	10014: DeleteUserFail,
 	10015: GetUserinfoFail,
 	10016: UserIsExist,
+	10017: TwoFactorRequired,
+	10018: TwoFactorCodeError,
+	10019: TwoFactorNotEnable,
+	10020: TwoFactorEnabled,
+	10021: WebAuthnError,
+	10022: WebAuthnNotFound,
+	10023: SessionNotExist,
+	10024: LoginRefreshError,
+	10025: DeletionNotPending,
+	10026: PermissionDenied,
 	20002: AuthCheckTokenTimeout,
 	20003: GenerateToken,
 	20004: TokenError,
@@ -82,14 +135,28 @@ var errorMap = map[int]LocalError{
 	20007: TicketNotCorrect,
 	20008: CheckTicketNotfound,
 	20009: InvalidAccToken,
+	20010: ReauthRequired,
 	30001: SendEmailError,
 	30002: CaptchaError,
 	30003: UserEmailError,
+	30004: CaptchaExpired,
+	30005: EmailIsUsed,
+	30006: EmailNotAllowed,
+	30007: InviteRequired,
+	30008: InvitationInvalid,
 	40001: VerifyAccountError,
 	40002: VerifyPasswordError,
+	40003: TooManyAttempts,
 	60001: ClientErr,
 	60002: AccessTokenErr,
 	60003: RefreshTokenErr,
+	60004: InvalidScope,
+	60005: ConsentRequired,
+	60006: ClientNotExist,
+	60007: UserCodeInvalid,
+	60008: ServiceAccountExist,
+	60009: InvalidRedirectURI,
+	60010: InvalidIDTokenHint,
 	50000: InternalErr,
 	80000: ProfileNotExist,
 	80001: OrgIdError,
@@ -34,3 +34,25 @@ func Failed(e LocalError) Response {
 		Data:    e.Error(),
 	}
 }
+
+// FailedWithData is the failed response carrying what the client needs to continue
+func FailedWithData(e LocalError, data any) Response {
+	return Response{
+		Success: false,
+		ErrCode: e.ErrCode,
+		ErrMsg:  e.ErrMsg,
+		Data:    data,
+	}
+}
+
+// Locked is the failed response of LockedError
+func Locked(e LockedError) Response {
+	return Response{
+		Success: false,
+		ErrCode: TooManyAttempts.ErrCode,
+		ErrMsg:  TooManyAttempts.ErrMsg,
+		Data: map[string]int64{
+			"retryAfter": e.RetryAfterSeconds(),
+		},
+	}
+}
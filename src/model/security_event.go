@@ -0,0 +1,35 @@
+package model
+
+import "time"
+
+// EVENT_REFRESH_TOKEN_REUSED is recorded when a rotated refresh token is presented again
+const EVENT_REFRESH_TOKEN_REUSED = "refresh_token_reused"
+
+// SecurityEvent record a suspicious use of the OAuth tokens of a client for admins
+type SecurityEvent struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	Event     string    `json:"event" gorm:"not null"`
+	ClientID  string    `json:"client_id" gorm:"not null"`
+	UserID    string    `json:"user_id" gorm:"not null"`
+	IP        string    `json:"ip" gorm:"column:ip;not null"`
+	CreatedAt time.Time `json:"created_at" gorm:"not null"`
+}
+
+func CreateSecurityEvent(event *SecurityEvent) error {
+	return Db.Table("oauth2_security_event").Create(event).Error
+}
+
+// SecurityEvents list at most limit events, the latest first,
+// filtered by clientID and userID if they are not empty.
+func SecurityEvents(clientID, userID string, limit int) ([]SecurityEvent, error) {
+	var events []SecurityEvent
+	tx := Db.Table("oauth2_security_event")
+	if clientID != "" {
+		tx = tx.Where("client_id = ?", clientID)
+	}
+	if userID != "" {
+		tx = tx.Where("user_id = ?", userID)
+	}
+	err := tx.Order("id desc").Limit(limit).Find(&events).Error
+	return events, err
+}
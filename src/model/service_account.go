@@ -0,0 +1,63 @@
+package model
+
+import (
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ServiceAccount let a client use the client_credentials grant without a user,
+// Owner is the member responsible for it.
+type ServiceAccount struct {
+	ID         uint       `json:"id" gorm:"primaryKey"`
+	ClientID   string     `json:"client_id" gorm:"not null"`
+	Owner      string     `json:"owner" gorm:"not null"`
+	Purpose    string     `json:"purpose" gorm:"not null"`
+	Scope      string     `json:"scope" gorm:"not null"`
+	CreatedBy  string     `json:"created_by" gorm:"not null"`
+	CreatedAt  time.Time  `json:"created_at" gorm:"not null"`
+	DisabledAt *time.Time `json:"disabled_at"`
+}
+
+func CreateServiceAccount(account *ServiceAccount) error {
+	return Db.Table("service_account").Create(account).Error
+}
+
+// ServiceAccountByClient return nil if the client is not a service account
+func ServiceAccountByClient(clientID string) (*ServiceAccount, error) {
+	var account ServiceAccount
+	err := Db.Table("service_account").Where("client_id = ?", clientID).First(&account).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &account, nil
+}
+
+// ServiceAccounts list all service accounts, the latest first
+func ServiceAccounts() ([]ServiceAccount, error) {
+	var accounts []ServiceAccount
+	err := Db.Table("service_account").Order("id desc").Find(&accounts).Error
+	return accounts, err
+}
+
+// DisableServiceAccount stop the service account from getting tokens,
+// the tokens it holds are deleted.
+func DisableServiceAccount(id uint) (disabled bool, err error) {
+	err = Db.Transaction(func(tx *gorm.DB) error {
+		var account ServiceAccount
+		res := tx.Table("service_account").Where("id = ? AND disabled_at IS NULL", id).Update("disabled_at", time.Now())
+		if res.Error != nil || res.RowsAffected == 0 {
+			return res.Error
+		}
+		if err := tx.Table("service_account").Where("id = ?", id).First(&account).Error; err != nil {
+			return err
+		}
+		disabled = true
+		return tx.Exec("DELETE FROM oauth2_tokens WHERE data->>'ClientID' = ? AND COALESCE(data->>'UserID', '') = ''", account.ClientID).Error
+	})
+	return disabled, err
+}
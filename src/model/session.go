@@ -0,0 +1,134 @@
+package model
+
+import (
+	"context"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Session is a logged-in device of user, saved in redis hash `SESSION:<id>`,
+// ids of user are indexed by the set `USER_SESSIONS:<uid>`.
+type Session struct {
+	ID        string `json:"id" redis:"-"`
+	Uid       string `json:"-" redis:"uid"`
+	Device    string `json:"device" redis:"device"`
+	UserAgent string `json:"user_agent" redis:"user_agent"`
+	IP        string `json:"ip" redis:"ip"`
+	CreatedAt int64  `json:"created_at" redis:"created_at"`
+	LastSeen  int64  `json:"last_seen" redis:"last_seen"`
+	// sha256 of the current refresh token
+	RefreshHash string `json:"-" redis:"refresh_hash"`
+}
+
+// Result of RotateRefreshToken
+const (
+	REFRESH_INVALID = iota
+	REFRESH_ROTATED
+	REFRESH_REUSED
+)
+
+// touch last_seen without recreating an expired session
+var touchSessionScript = redis.NewScript(`
+if redis.call("EXISTS", KEYS[1]) == 1 then
+	return redis.call("HSET", KEYS[1], "last_seen", ARGV[1])
+end
+return 0
+`)
+
+// replace the refresh token hash if it is the current one,
+// remember the old hash to detect reuse.
+var rotateRefreshScript = redis.NewScript(`
+local current = redis.call("HGET", KEYS[1], "refresh_hash")
+if not current then
+	return 0
+end
+if current == ARGV[1] then
+	redis.call("HSET", KEYS[1], "refresh_hash", ARGV[2], "last_seen", ARGV[3])
+	redis.call("SADD", KEYS[2], ARGV[1])
+	redis.call("EXPIRE", KEYS[1], ARGV[4])
+	redis.call("EXPIRE", KEYS[2], ARGV[4])
+	redis.call("EXPIRE", KEYS[3], ARGV[5])
+	return 1
+end
+if redis.call("SISMEMBER", KEYS[2], ARGV[1]) == 1 then
+	return 2
+end
+return 0
+`)
+
+func CreateSession(ctx context.Context, session *Session, exp time.Duration) error {
+	pipe := Rdb.TxPipeline()
+	pipe.HSet(ctx, SessionKey(session.ID), session)
+	pipe.Expire(ctx, SessionKey(session.ID), exp)
+	pipe.SAdd(ctx, UserSessionsKey(session.Uid), session.ID)
+	// the index lives as long as the latest session
+	pipe.Expire(ctx, UserSessionsKey(session.Uid), exp)
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// RotateRefreshToken replace refresh token of session and extend the session to exp,
+// return REFRESH_REUSED if oldHash has been rotated before.
+func RotateRefreshToken(ctx context.Context, session *Session, oldHash, newHash string, exp, indexExp time.Duration) (int, error) {
+	keys := []string{SessionKey(session.ID), UsedRefreshTokenKey(session.ID), UserSessionsKey(session.Uid)}
+	return rotateRefreshScript.Run(ctx, Rdb, keys,
+		oldHash, newHash, time.Now().Unix(), int64(exp.Seconds()), int64(indexExp.Seconds())).Int()
+}
+
+// SessionByID return nil if the session is expired or revoked
+func SessionByID(ctx context.Context, sid string) (*Session, error) {
+	cmd := Rdb.HGetAll(ctx, SessionKey(sid))
+	if err := cmd.Err(); err != nil {
+		return nil, err
+	}
+	if len(cmd.Val()) == 0 {
+		return nil, nil
+	}
+	session := &Session{ID: sid}
+	if err := cmd.Scan(session); err != nil {
+		return nil, err
+	}
+	return session, nil
+}
+
+func TouchSession(ctx context.Context, sid string, lastSeen time.Time) error {
+	return touchSessionScript.Run(ctx, Rdb, []string{SessionKey(sid)}, lastSeen.Unix()).Err()
+}
+
+func SessionIDsByUid(ctx context.Context, uid string) ([]string, error) {
+	return Rdb.SMembers(ctx, UserSessionsKey(uid)).Result()
+}
+
+// DeleteSessions revoke sessions of user
+func DeleteSessions(ctx context.Context, uid string, sids ...string) error {
+	if len(sids) == 0 {
+		return nil
+	}
+	keys := make([]string, 0, 2*len(sids))
+	members := make([]interface{}, len(sids))
+	for i, sid := range sids {
+		keys = append(keys, SessionKey(sid), UsedRefreshTokenKey(sid), SessionClientsKey(sid))
+		members[i] = sid
+	}
+	pipe := Rdb.TxPipeline()
+	pipe.Del(ctx, keys...)
+	pipe.SRem(ctx, UserSessionsKey(uid), members...)
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// AddSessionClient remember clientID got an ID token in session sid,
+// the index lives no longer than a session.
+func AddSessionClient(ctx context.Context, sid, clientID string) error {
+	pipe := Rdb.TxPipeline()
+	pipe.SAdd(ctx, SessionClientsKey(sid), clientID)
+	pipe.Expire(ctx, SessionClientsKey(sid), SESSION_MAX_AGE)
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// SessionClients return the clients logged in by session sid
+func SessionClients(ctx context.Context, sid string) ([]string, error) {
+	return Rdb.SMembers(ctx, SessionClientsKey(sid)).Result()
+}
@@ -0,0 +1,93 @@
+package model
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/redis/go-redis/v9"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// TwoFactor is the TOTP authenticator of user,
+// it is pending until the user confirms it with a valid code.
+type TwoFactor struct {
+	ID            uint           `json:"id" gorm:"primaryKey"`
+	Uid           string         `json:"uid" gorm:"not null"`
+	Secret        string         `json:"-" gorm:"not null"`
+	Enabled       bool           `json:"enabled" gorm:"not null"`
+	RecoveryCodes pq.StringArray `json:"-" gorm:"type:varchar[]"`
+	CreatedAt     time.Time      `json:"created_at" gorm:"not null"`
+	EnabledAt     *time.Time     `json:"enabled_at"`
+}
+
+// TwoFactorByUid return nil if user has no authenticator
+func TwoFactorByUid(uid string) (*TwoFactor, error) {
+	var twoFactor TwoFactor
+	err := Db.Table("two_factor").Where("uid = ?", uid).First(&twoFactor).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		userLogger.Errorln("select two_factor by uid err", err)
+		return nil, err
+	}
+	return &twoFactor, nil
+}
+
+// UpsertPendingTwoFactor replace the pending authenticator secret of user
+func UpsertPendingTwoFactor(uid, secret string) error {
+	twoFactor := TwoFactor{
+		Uid:       uid,
+		Secret:    secret,
+		CreatedAt: time.Now(),
+	}
+	return Db.Table("two_factor").Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "uid"}},
+		DoUpdates: clause.AssignmentColumns([]string{"secret", "enabled", "recovery_codes", "created_at", "enabled_at"}),
+	}).Create(&twoFactor).Error
+}
+
+// EnableTwoFactor mark the authenticator as confirmed and save hashed recovery codes
+func EnableTwoFactor(uid string, recoveryCodes []string) error {
+	return Db.Table("two_factor").Where("uid = ?", uid).Updates(map[string]interface{}{
+		"enabled":        true,
+		"enabled_at":     time.Now(),
+		"recovery_codes": pq.StringArray(recoveryCodes),
+	}).Error
+}
+
+// UseRecoveryCode remove the hashed recovery code,
+// return false if the code has been used or not exist.
+func UseRecoveryCode(uid, hashedCode string) (bool, error) {
+	res := Db.Table("two_factor").
+		Where("uid = ? AND enabled = ? AND ? = ANY(recovery_codes)", uid, true, hashedCode).
+		Update("recovery_codes", gorm.Expr("array_remove(recovery_codes, ?)", hashedCode))
+	if res.Error != nil {
+		return false, res.Error
+	}
+	return res.RowsAffected == 1, nil
+}
+
+func DeleteTwoFactor(uid string) error {
+	return Db.Table("two_factor").Where("uid = ?", uid).Delete(&TwoFactor{}).Error
+}
+
+// useTOTPStepScript save the time step ARGV[1] of a used TOTP code
+// if it is later than the saved one, concurrent logins can not both use it.
+var useTOTPStepScript = redis.NewScript(`
+local last = tonumber(redis.call("GET", KEYS[1]))
+if last and tonumber(ARGV[1]) <= last then
+	return 0
+end
+redis.call("SET", KEYS[1], ARGV[1], "EX", ARGV[2])
+return 1
+`)
+
+// UseTOTPStep mark the time step of a TOTP code used by uid until exp,
+// return false if the step or a later one has been used.
+func UseTOTPStep(ctx context.Context, uid string, step int64, exp time.Duration) (bool, error) {
+	return useTOTPStepScript.Run(ctx, Rdb, []string{TOTPUsedStepKey(uid)}, step, int64(exp.Seconds())).Bool()
+}
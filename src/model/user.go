@@ -25,15 +25,43 @@ type User struct {
 	WechatId  *string   `json:"wechat_id,omitempty"`
 	CreatedAt time.Time `json:"created_at,omitempty" gorm:"not null"`
 	IsDeleted bool      `json:"is_deleted,omitempty" gorm:"not null"`
+	// data is purged after this time, nil if deletion is not requested
+	DeletionScheduledAt *time.Time `json:"deletion_scheduled_at,omitempty"`
 }
 
-func CreateUserAndProfile(user *User, profile *Profile) error {
+// CreateUserAndProfile create user, the invitation is redeemed in the same
+// transaction if it is not empty, and its preset org is applied to profile.
+func CreateUserAndProfile(user *User, profile *Profile, invitationCode string) error {
 	err := Db.Transaction(func(tx *gorm.DB) error {
+		var invitation *Invitation
+		if invitationCode != "" {
+			var err error
+			if invitation, err = redeemInvitation(tx, invitationCode); err != nil {
+				return err
+			}
+			if invitation == nil {
+				return result.InvitationInvalid
+			}
+			if invitation.OrgId != nil {
+				profile.OrgId = *invitation.OrgId
+			}
+		}
 		//create user and get user_id
 		if err := tx.Create(user).Error; err != nil {
 			return err
 		}
 		profile.UserID = &user.ID
+		if invitation != nil {
+			redemption := &InvitationRedemption{
+				InvitationID: invitation.ID,
+				Uid:          *user.Uid,
+				Email:        *user.Email,
+				RedeemedAt:   time.Now(),
+			}
+			if err := tx.Table("invitation_redemption").Create(redemption).Error; err != nil {
+				return err
+			}
+		}
 
 		tx = tx.Table("profile")
 		if err := tx.Create(profile).Error; err != nil {
@@ -48,16 +76,17 @@ func CreateUserAndProfile(user *User, profile *Profile) error {
 	}
 }
 
-func CheckPassword(username string, password string) (string, error) {
+// CheckPassword verify password of user with email/uid,
+// `rehash` is true when the stored hash uses an outdated algorithm or parameters.
+func CheckPassword(username string, password string) (uid string, rehash bool, err error) {
 	//get uid from username by regexp
 	var user User
 	matched, regErr := regexp.MatchString("@", username)
 	if regErr != nil {
 		userLogger.Errorf("regexp matchiong error")
-		return "", regErr
+		return "", false, regErr
 	}
 
-	var err error = nil
 	// Get user by email/uid
 	// If matched, get user by email
 	if matched {
@@ -68,27 +97,129 @@ func CheckPassword(username string, password string) (string, error) {
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			userLogger.Errorf("User [%s] Not Exist\n", username)
-			return "", result.UserNotExist
+			return "", false, result.UserNotExist
 		}
+		return "", false, result.InternalErr
 	}
 
-	//encrypt and verify password
-	pwdEncrypted := util.ShaHashing(password)
-	if *user.Password != pwdEncrypted {
-		return "", result.PasswordError
+	//verify password
+	ok, rehash, err := util.VerifyPassword(password, *user.Password)
+	if err != nil {
+		userLogger.Errorf("User [%s] verify password: %s\n", username, err.Error())
+		return "", false, result.InternalErr
+	}
+	if !ok {
+		return "", false, result.PasswordError
 	}
-	return *user.Uid, err
+	return *user.Uid, rehash, nil
 }
 
 func ChangePassword(uid string, password string) error {
-	pwdEncrypted := util.ShaHashing(password)
-	err := Db.Debug().Model(&User{}).Where("uid = ?", uid).Where("is_deleted = ?", false).Update("password", pwdEncrypted).Error
+	pwdEncrypted, err := util.HashPassword(password)
+	if err != nil {
+		return err
+	}
+	err = Db.Model(&User{}).Where("uid = ?", uid).Where("is_deleted = ?", false).Update("password", pwdEncrypted).Error
 	if err != nil {
 		return err
 	}
 	return nil
 }
 
+// ChangeEmail update email of user and profile together
+func ChangeEmail(uid, email string) error {
+	return Db.Transaction(func(tx *gorm.DB) error {
+		var user User
+		if err := tx.Where("uid = ?", uid).Where("is_deleted = ?", false).First(&user).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&user).Update("email", email).Error; err != nil {
+			return err
+		}
+		return tx.Table("profile").Where("user_id = ?", user.ID).Update("email", email).Error
+	})
+}
+
+// ScheduleDeletion mark user to be purged at `at`
+func ScheduleDeletion(uid string, at time.Time) error {
+	return Db.Model(&User{}).Where("uid = ?", uid).Where("is_deleted = ?", false).
+		Update("deletion_scheduled_at", at).Error
+}
+
+// CancelDeletion return false if deletion is not scheduled
+func CancelDeletion(uid string) (bool, error) {
+	res := Db.Model(&User{}).Where("uid = ?", uid).Where("is_deleted = ?", false).
+		Where("deletion_scheduled_at IS NOT NULL").
+		Update("deletion_scheduled_at", nil)
+	return res.RowsAffected > 0, res.Error
+}
+
+// UidsToPurge return users whose deletion grace period is over
+func UidsToPurge(now time.Time) ([]string, error) {
+	var uids []string
+	err := Db.Model(&User{}).Where("is_deleted = ?", false).
+		Where("deletion_scheduled_at <= ?", now).
+		Pluck("uid", &uids).Error
+	return uids, err
+}
+
+// PurgeUser remove personal data of user and soft-delete the user row,
+// the row is kept so the uid is not reused, see UidTaken.
+// The email is replaced by DeletedEmail of the row, here and in invitation redemptions.
+func PurgeUser(uid string) error {
+	return Db.Transaction(func(tx *gorm.DB) error {
+		var user User
+		err := tx.Where("uid = ?", uid).Where("is_deleted = ?", false).
+			Where("deletion_scheduled_at IS NOT NULL").First(&user).Error
+		if err != nil {
+			// canceled or purged by another instance
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil
+			}
+			return err
+		}
+		if err := tx.Table("profile").Where("user_id = ?", user.ID).Delete(&Profile{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Table("oauth2_info").Where("user_id = ?", uid).Delete(&OAuth2Info{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Table("two_factor").Where("uid = ?", uid).Delete(&TwoFactor{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Table("webauthn_credential").Where("uid = ?", uid).Delete(&WebAuthnCredential{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Table("oauth2_grant").Where("user_id = ?", uid).Delete(&OAuthGrant{}).Error; err != nil {
+			return err
+		}
+		// tokens issued to oauth clients, see go-oauth2-pg TokenStore
+		if err := tx.Exec("DELETE FROM oauth2_tokens WHERE data->>'UserID' = ?", uid).Error; err != nil {
+			return err
+		}
+		email := DeletedEmail(user.ID)
+		if err := tx.Table("invitation_redemption").Where("uid = ?", uid).Update("email", email).Error; err != nil {
+			return err
+		}
+		return tx.Model(&user).Updates(map[string]interface{}{
+			"is_deleted":            true,
+			"deletion_scheduled_at": nil,
+			"email":                 email,
+			"password":              "",
+			"qq_id":                 nil,
+			"lark_id":               nil,
+			"github_id":             nil,
+			"wechat_id":             nil,
+		}).Error
+	})
+}
+
+// DeletedEmail is the tombstone of the email of a purged user,
+// `.invalid` never resolves so no mail is sent to it.
+func DeletedEmail(id uint) string {
+	return fmt.Sprintf("deleted-%d@invalid", id)
+}
+
 // UserByField find user by specific database table field name
 func UserByField(field, value string) (*User, error) {
 	var user User
@@ -103,6 +234,14 @@ func UserByField(field, value string) (*User, error) {
 	return &user, nil
 }
 
+// UidTaken report whether uid belongs to a user, deleted ones included,
+// the uid of a purged user is not given to a new user.
+func UidTaken(uid string) (bool, error) {
+	var count int64
+	err := Db.Model(&User{}).Where("uid = ?", uid).Count(&count).Error
+	return count > 0, err
+}
+
 func UserInfo(username string) (*User, error) {
 	var user = User{Uid: &username}
 	matched, err2 := regexp.MatchString("@", username)
@@ -136,3 +275,10 @@ func SendEmail(recipient, content, title string) error {
 	secret := emailInfo.GetString("secret")
 	return util.SendEmail(sender, secret, recipient, content, title)
 }
+
+// IsAdmin report whether uid is in the admin table
+func IsAdmin(uid string) (bool, error) {
+	var count int64
+	err := Db.Table("admin").Where("user_id = ?", uid).Count(&count).Error
+	return count > 0, err
+}
@@ -3,6 +3,7 @@ package model
 import (
 	"fmt"
 	"testing"
+	"time"
 )
 
 func TestGenerateCode(t *testing.T) {
@@ -23,3 +24,39 @@ func TestVerifyCode(t *testing.T) {
 	str := InsertCode(data)
 	fmt.Println(str)
 }
+
+func TestPurgeUser(t *testing.T) {
+	uid, email, password := "test-purge-user", "test-purge-user@example.org", "hash"
+	user := &User{Uid: &uid, Email: &email, Password: &password}
+	if err := CreateUserAndProfile(user, &Profile{Nickname: &uid, Email: &email, OrgId: -1}, ""); err != nil {
+		t.Fatalf("CreateUserAndProfile failed: %s", err)
+	}
+	defer Db.Where("id = ?", user.ID).Delete(&User{})
+	redemption := &InvitationRedemption{Uid: uid, Email: email, RedeemedAt: time.Now()}
+	if err := Db.Table("invitation_redemption").Create(redemption).Error; err != nil {
+		t.Fatalf("create invitation redemption failed: %s", err)
+	}
+	defer Db.Table("invitation_redemption").Where("id = ?", redemption.ID).Delete(&InvitationRedemption{})
+
+	if err := ScheduleDeletion(uid, time.Now()); err != nil {
+		t.Fatalf("ScheduleDeletion failed: %s", err)
+	}
+	if err := PurgeUser(uid); err != nil {
+		t.Fatalf("PurgeUser failed: %s", err)
+	}
+
+	var purged User
+	if err := Db.Where("id = ?", user.ID).First(&purged).Error; err != nil {
+		t.Fatalf("find purged user failed: %s", err)
+	}
+	if !purged.IsDeleted || *purged.Email != DeletedEmail(user.ID) {
+		t.Errorf("purged user has email [%s], deleted %v", *purged.Email, purged.IsDeleted)
+	}
+	var redeemed InvitationRedemption
+	if err := Db.Table("invitation_redemption").Where("id = ?", redemption.ID).First(&redeemed).Error; err != nil {
+		t.Fatalf("find invitation redemption failed: %s", err)
+	}
+	if redeemed.Email != DeletedEmail(user.ID) {
+		t.Errorf("invitation redemption keeps email [%s]", redeemed.Email)
+	}
+}
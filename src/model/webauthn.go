@@ -0,0 +1,71 @@
+package model
+
+import (
+	"errors"
+	"time"
+
+	"github.com/lib/pq"
+	"gorm.io/gorm"
+)
+
+// WebAuthnCredential is a passkey registered by user
+type WebAuthnCredential struct {
+	ID           uint           `json:"id" gorm:"primaryKey"`
+	Uid          string         `json:"-" gorm:"not null"`
+	CredentialID string         `json:"credential_id" gorm:"not null"`
+	PublicKey    []byte         `json:"-" gorm:"not null"`
+	SignCount    uint32         `json:"-" gorm:"not null"`
+	AAGUID       string         `json:"aaguid" gorm:"column:aaguid"`
+	Transports   pq.StringArray `json:"transports" gorm:"type:varchar[]"`
+	Name         string         `json:"name" gorm:"not null"`
+	CreatedAt    time.Time      `json:"created_at" gorm:"not null"`
+	LastUsedAt   *time.Time     `json:"last_used_at"`
+}
+
+func CreateWebAuthnCredential(credential *WebAuthnCredential) error {
+	return Db.Table("webauthn_credential").Create(credential).Error
+}
+
+func WebAuthnCredentialsByUid(uid string) ([]WebAuthnCredential, error) {
+	var credentials []WebAuthnCredential
+	err := Db.Table("webauthn_credential").Where("uid = ?", uid).Order("id").Find(&credentials).Error
+	if err != nil {
+		userLogger.Errorln("select webauthn_credential by uid err", err)
+		return nil, err
+	}
+	return credentials, nil
+}
+
+// WebAuthnCredentialByID return nil if the credential is not registered
+func WebAuthnCredentialByID(credentialID string) (*WebAuthnCredential, error) {
+	var credential WebAuthnCredential
+	err := Db.Table("webauthn_credential").Where("credential_id = ?", credentialID).First(&credential).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		userLogger.Errorln("select webauthn_credential by credential_id err", err)
+		return nil, err
+	}
+	return &credential, nil
+}
+
+// UpdateWebAuthnSignCount save the sign counter after a successful login
+func UpdateWebAuthnSignCount(id uint, signCount uint32) error {
+	return Db.Table("webauthn_credential").Where("id = ?", id).Updates(map[string]interface{}{
+		"sign_count":   signCount,
+		"last_used_at": time.Now(),
+	}).Error
+}
+
+// RenameWebAuthnCredential return false if user has no such credential
+func RenameWebAuthnCredential(uid string, id uint, name string) (bool, error) {
+	res := Db.Table("webauthn_credential").Where("id = ? AND uid = ?", id, uid).Update("name", name)
+	return res.RowsAffected == 1, res.Error
+}
+
+// DeleteWebAuthnCredential return false if user has no such credential
+func DeleteWebAuthnCredential(uid string, id uint) (bool, error) {
+	res := Db.Table("webauthn_credential").Where("id = ? AND uid = ?", id, uid).Delete(&WebAuthnCredential{})
+	return res.RowsAffected == 1, res.Error
+}
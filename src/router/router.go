@@ -4,6 +4,7 @@ import (
 	"net/http"
 
 	v1 "github.com/NJUPT-SAST/sast-link-backend/api/v1"
+	"github.com/NJUPT-SAST/sast-link-backend/middleware"
 	"github.com/gin-gonic/gin"
 )
 
@@ -18,16 +19,35 @@ func InitRouter() *gin.Engine {
 		println("router:" + c.FullPath())
 	})
 
+	r.GET("/.well-known/openid-configuration", v1.OpenIDConfiguration)
+
 	apiV1 := r.Group("/api/v1")
 
 	usergroup := apiV1.Group("/user")
 	{
-		usergroup.GET("/info", v1.UserInfo)
+		usergroup.GET("/info", middleware.JWT, v1.UserInfo)
 		usergroup.POST("/register", v1.Register)
 		usergroup.POST("/login", v1.Login)
-		usergroup.POST("/logout", v1.Logout)
-		usergroup.POST("/changePassword", v1.ChangePassword)
+		usergroup.POST("/refresh", v1.Refresh)
+		usergroup.POST("/logout", middleware.JWT, v1.Logout)
+		usergroup.POST("/changePassword", middleware.JWT, v1.ChangePassword)
 		usergroup.POST("/resetPassword", v1.ResetPassword)
+		usergroup.POST("/login/totp", v1.LoginTOTP)
+		usergroup.POST("/totp/enroll", middleware.JWT, v1.EnrollTOTP)
+		usergroup.POST("/totp/confirm", middleware.JWT, v1.ConfirmTOTP)
+		usergroup.POST("/totp/disable", middleware.JWT, v1.DisableTOTP)
+		usergroup.POST("/webauthn/register/begin", middleware.JWT, v1.BeginWebAuthnRegister)
+		usergroup.POST("/webauthn/register/finish", middleware.JWT, v1.FinishWebAuthnRegister)
+		usergroup.POST("/webauthn/login/begin", v1.BeginWebAuthnLogin)
+		usergroup.POST("/webauthn/login/finish", v1.FinishWebAuthnLogin)
+		usergroup.GET("/webauthn/credentials", middleware.JWT, v1.WebAuthnCredentials)
+		usergroup.POST("/webauthn/credentials/rename", middleware.JWT, v1.RenameWebAuthnCredential)
+		usergroup.POST("/webauthn/credentials/delete", middleware.JWT, v1.DeleteWebAuthnCredential)
+		usergroup.GET("/sessions", middleware.JWT, v1.Sessions)
+		usergroup.POST("/revokeSession", middleware.JWT, v1.RevokeSession)
+		usergroup.POST("/revokeOtherSessions", middleware.JWT, v1.RevokeOtherSessions)
+		usergroup.POST("/delete", middleware.JWT, v1.DeleteAccount)
+		usergroup.POST("/cancelDelete", middleware.JWT, v1.CancelDeleteAccount)
 	}
 	verify := apiV1.Group("/verify")
 	{
@@ -37,9 +57,25 @@ func InitRouter() *gin.Engine {
 	// Limit 3 requests per minute
 	// apiV1.GET("/sendEmail", middleware.RequestRateLimiter(3, time.Minute), v1.SendEmail)
 	apiV1.GET("/sendEmail", v1.SendEmail)
-	//S-LYPL7 admingroup := apiV1.Group("/admin")
-	// {
-	// }
+	admingroup := apiV1.Group("/admin", middleware.JWT, middleware.Admin)
+	{
+		admingroup.GET("/invitations", v1.Invitations)
+		admingroup.POST("/invitations", v1.CreateInvitation)
+		admingroup.POST("/invitations/expire", v1.ExpireInvitation)
+		admingroup.GET("/serviceAccounts", v1.ServiceAccounts)
+		admingroup.POST("/serviceAccounts", v1.CreateServiceAccount)
+		admingroup.POST("/serviceAccounts/disable", v1.DisableServiceAccount)
+		admingroup.GET("/initialAccessTokens", v1.InitialAccessTokens)
+		admingroup.POST("/initialAccessTokens", v1.CreateInitialAccessToken)
+		admingroup.POST("/initialAccessTokens/expire", v1.ExpireInitialAccessToken)
+		admingroup.GET("/clients", v1.RegisteredClients)
+		admingroup.POST("/clients/review", v1.ReviewClient)
+		admingroup.POST("/clients/policy", v1.SetClientPolicy)
+		admingroup.GET("/securityEvents", v1.SecurityEvents)
+	}
+
+	// member lookup for service accounts
+	apiV1.GET("/directory/users", v1.DirectoryUsers)
 
 	// oauth
 	oauth := apiV1.Group("/oauth2")
@@ -50,8 +86,28 @@ func InitRouter() *gin.Engine {
 		// oauth.GET("/auth", v1.UserAuth)
 		oauth.POST("/token", v1.AccessToken)
 		oauth.POST("/refresh", v1.RefreshToken)
-		oauth.POST("/create-client", v1.CreateClient)
+		oauth.POST("/introspect", v1.IntrospectToken)
+		oauth.POST("/revoke", v1.RevokeToken)
+		oauth.POST("/device/code", v1.DeviceCode)
+		oauth.GET("/device", middleware.JWT, v1.DeviceConsent)
+		oauth.POST("/device", middleware.JWT, v1.DecideDevice)
+		oauth.POST("/create-client", middleware.JWT, v1.CreateClient)
+		oauth.GET("/clients", middleware.JWT, v1.Clients)
+		oauth.POST("/update-client", middleware.JWT, v1.UpdateClient)
+		oauth.POST("/rotate-secret", middleware.JWT, v1.RotateClientSecret)
+		oauth.POST("/delete-client", middleware.JWT, v1.DeleteClient)
+		// dynamic client registration, RFC 7591 and RFC 7592
+		oauth.POST("/register", v1.RegisterClient)
+		oauth.GET("/register/:client_id", v1.RegisteredClient)
+		oauth.PUT("/register/:client_id", v1.UpdateRegisteredClient)
+		oauth.DELETE("/register/:client_id", v1.DeleteRegisteredClient)
+		oauth.POST("/consent", middleware.JWT, v1.DecideConsent)
 		oauth.GET("/userinfo", v1.OauthUserInfo)
+		oauth.GET("/oidc/userinfo", v1.OIDCUserInfo)
+		oauth.POST("/oidc/userinfo", v1.OIDCUserInfo)
+		oauth.GET("/jwks", v1.JWKS)
+		oauth.GET("/logout", v1.EndSession)
+		oauth.POST("/logout", v1.EndSession)
 	}
 
 	// third party login
@@ -66,11 +122,14 @@ func InitRouter() *gin.Engine {
 
 	profile := apiV1.Group("/profile")
 	{
-		profile.GET("/getProfile", v1.GetProfile)
-		profile.GET("/bindStatus", v1.BindStatus)
-		profile.POST("/changeProfile", v1.ChangeProfile)
-		profile.POST("/uploadAvatar", v1.UploadAvatar)
-		profile.POST("/changeEmail", v1.ChangeEmail)
+		profile.GET("/getProfile", middleware.JWT, v1.GetProfile)
+		profile.GET("/bindStatus", middleware.JWT, v1.BindStatus)
+		profile.GET("/connectedApps", middleware.JWT, v1.ConnectedApps)
+		profile.POST("/revokeApp", middleware.JWT, v1.RevokeApp)
+		profile.POST("/changeProfile", middleware.JWT, v1.ChangeProfile)
+		profile.POST("/uploadAvatar", middleware.JWT, v1.UploadAvatar)
+		profile.POST("/changeEmail", middleware.JWT, v1.ChangeEmail)
+		profile.POST("/confirmEmail", middleware.JWT, v1.ConfirmEmail)
 		profile.POST("/dealCensorRes", v1.DealCensorRes)
 	}
 
@@ -0,0 +1,168 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/NJUPT-SAST/sast-link-backend/config"
+	"github.com/NJUPT-SAST/sast-link-backend/util"
+	"github.com/go-oauth2/oauth2/v4"
+	"github.com/go-oauth2/oauth2/v4/generates"
+	"github.com/go-oauth2/oauth2/v4/manage"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+var (
+	accessTokenKeys     *util.KeySet
+	accessTokenKeysErr  error
+	accessTokenKeysOnce sync.Once
+)
+
+// accessTokenKeyConfig is an item of `oauth.server.access_token.keys`
+type accessTokenKeyConfig struct {
+	// File is the path of the PEM private key, or PEM is the key itself
+	File string `mapstructure:"file"`
+	PEM  string `mapstructure:"pem"`
+	// Kid defaults to the RFC 7638 thumbprint of the key
+	Kid string `mapstructure:"kid"`
+	// NotBefore is a RFC 3339 time the key starts to sign, empty for a key in use
+	NotBefore string `mapstructure:"not_before"`
+}
+
+// accessTokenClaims is the payload of RFC 9068 JWT access tokens
+type accessTokenClaims struct {
+	jwt.RegisteredClaims
+	ClientID string `json:"client_id"`
+	Scope    string `json:"scope,omitempty"`
+}
+
+// JWTAccessToken report whether the OAuth server issues JWT access tokens
+// instead of opaque ones.
+func JWTAccessToken() bool {
+	return config.Config.GetBool("oauth.server.access_token.jwt")
+}
+
+// accessTokenMaxAge is the longest lifetime of access tokens issued by the grants
+// or set by clients, a replaced key is published until then.
+func accessTokenMaxAge() time.Duration {
+	age := MaxAccessTokenTTL
+	for _, cfg := range []*manage.Config{
+		manage.DefaultAuthorizeCodeTokenCfg,
+		manage.DefaultPasswordTokenCfg,
+		manage.DefaultClientTokenCfg,
+	} {
+		if cfg.AccessTokenExp > age {
+			age = cfg.AccessTokenExp
+		}
+	}
+	return age
+}
+
+// accessTokenKeySet load the keys of `oauth.server.access_token.keys`,
+// the ID token key is used if no key is configured.
+func accessTokenKeySet() (*util.KeySet, error) {
+	accessTokenKeysOnce.Do(func() {
+		var configs []accessTokenKeyConfig
+		if err := config.Config.UnmarshalKey("oauth.server.access_token.keys", &configs); err != nil {
+			accessTokenKeysErr = err
+			return
+		}
+		var keys []util.RotatingKey
+		for i, c := range configs {
+			key, err := loadAccessTokenKey(c)
+			if err != nil {
+				accessTokenKeysErr = fmt.Errorf("access token key %d: %w", i, err)
+				return
+			}
+			keys = append(keys, key)
+		}
+		if len(keys) == 0 {
+			key, err := signingKey()
+			if err != nil {
+				accessTokenKeysErr = err
+				return
+			}
+			keys = append(keys, util.RotatingKey{SigningKey: key})
+		}
+		accessTokenKeys = util.NewKeySet(keys, accessTokenMaxAge())
+	})
+	return accessTokenKeys, accessTokenKeysErr
+}
+
+func loadAccessTokenKey(c accessTokenKeyConfig) (util.RotatingKey, error) {
+	pemBytes := []byte(c.PEM)
+	if c.File != "" {
+		b, err := os.ReadFile(c.File)
+		if err != nil {
+			return util.RotatingKey{}, err
+		}
+		pemBytes = b
+	}
+	key, err := util.ParseSigningKey(pemBytes)
+	if err != nil {
+		return util.RotatingKey{}, err
+	}
+	if c.Kid != "" {
+		key.ID = c.Kid
+	}
+	var notBefore time.Time
+	if c.NotBefore != "" {
+		if notBefore, err = time.Parse(time.RFC3339, c.NotBefore); err != nil {
+			return util.RotatingKey{}, err
+		}
+	}
+	return util.RotatingKey{SigningKey: key, NotBefore: notBefore}, nil
+}
+
+// accessTokenAudience is the `aud` of JWT access tokens, the resource servers trusting them
+func accessTokenAudience() string {
+	if aud := config.Config.GetString("oauth.server.access_token.audience"); aud != "" {
+		return aud
+	}
+	return OIDCIssuer()
+}
+
+// JWTAccessGenerate issue RFC 9068 JWT access tokens signed by the active key,
+// refresh tokens stay opaque.
+type JWTAccessGenerate struct{}
+
+func (g *JWTAccessGenerate) Token(ctx context.Context, data *oauth2.GenerateBasic, isGenRefresh bool) (access, refresh string, err error) {
+	keys, err := accessTokenKeySet()
+	if err != nil {
+		return "", "", err
+	}
+	key := keys.Active(time.Now())
+	if key == nil {
+		return "", "", errors.New("no access token key is in use")
+	}
+	// the subject of client_credentials tokens is the client itself
+	sub := data.UserID
+	if sub == "" {
+		sub = data.Client.GetID()
+	}
+	claims := accessTokenClaims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    OIDCIssuer(),
+			Subject:   sub,
+			Audience:  jwt.ClaimStrings{accessTokenAudience()},
+			ExpiresAt: jwt.NewNumericDate(data.CreateAt.Add(data.TokenInfo.GetAccessExpiresIn())),
+			IssuedAt:  jwt.NewNumericDate(data.CreateAt),
+			ID:        util.GenerateUUID(),
+		},
+		ClientID: data.Client.GetID(),
+		Scope:    data.TokenInfo.GetScope(),
+	}
+	if access, err = key.SignWithType("at+jwt", claims); err != nil {
+		return "", "", err
+	}
+	if isGenRefresh {
+		if _, refresh, err = generates.NewAccessGenerate().Token(ctx, data, true); err != nil {
+			return "", "", err
+		}
+	}
+	return access, refresh, nil
+}
@@ -0,0 +1,86 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"github.com/NJUPT-SAST/sast-link-backend/config"
+	"github.com/NJUPT-SAST/sast-link-backend/model"
+	"github.com/NJUPT-SAST/sast-link-backend/model/result"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	defaultDeletionGracePeriod = 14 * 24 * time.Hour
+	defaultPurgeInterval       = time.Hour
+)
+
+// RequestDeletion schedule the account to be purged after the grace period,
+// return the time of purge. The user can still log in and cancel before that.
+func RequestDeletion(ctx *gin.Context, uid, password, twoFactorCode string) (time.Time, error) {
+	if err := reauthenticate(ctx, uid, password, twoFactorCode); err != nil {
+		return time.Time{}, err
+	}
+	grace := config.Config.GetDuration("account.deletion_grace_period")
+	if grace <= 0 {
+		grace = defaultDeletionGracePeriod
+	}
+	at := time.Now().Add(grace)
+	if err := model.ScheduleDeletion(uid, at); err != nil {
+		return time.Time{}, err
+	}
+	serviceLogger.Infof("User [%s] requested deletion, purge at %v\n", uid, at)
+	return at, nil
+}
+
+// CancelDeletion keep the account if its grace period is not over
+func CancelDeletion(uid string) error {
+	canceled, err := model.CancelDeletion(uid)
+	if err != nil {
+		return err
+	}
+	if !canceled {
+		return result.DeletionNotPending
+	}
+	serviceLogger.Infof("User [%s] canceled deletion\n", uid)
+	return nil
+}
+
+// StartAccountPurger purge accounts whose grace period is over periodically,
+// it runs until ctx is done.
+func StartAccountPurger(ctx context.Context) {
+	interval := config.Config.GetDuration("account.purge_interval")
+	if interval <= 0 {
+		interval = defaultPurgeInterval
+	}
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			purgeAccounts(ctx)
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+func purgeAccounts(ctx context.Context) {
+	uids, err := model.UidsToPurge(time.Now())
+	if err != nil {
+		serviceLogger.Errorln("UidsToPurge Err,ErrMsg:", err)
+		return
+	}
+	for _, uid := range uids {
+		if err := model.PurgeUser(uid); err != nil {
+			serviceLogger.Errorf("purge user [%s] Err,ErrMsg: %v\n", uid, err)
+			continue
+		}
+		if err := logoutUser(ctx, uid); err != nil {
+			serviceLogger.Errorf("delete sessions of user [%s] Err,ErrMsg: %v\n", uid, err)
+		}
+		serviceLogger.Infof("User [%s] purged\n", uid)
+	}
+}
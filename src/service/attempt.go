@@ -0,0 +1,120 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"github.com/NJUPT-SAST/sast-link-backend/model"
+	"github.com/NJUPT-SAST/sast-link-backend/model/result"
+)
+
+// attemptLimit allow Free failures in Window, after that every failure
+// locks the key for Base, doubled for each further failure up to Max.
+type attemptLimit struct {
+	Name   string
+	Free   int64
+	Base   time.Duration
+	Max    time.Duration
+	Window time.Duration
+}
+
+var (
+	passwordAccountLimit  = attemptLimit{Name: "password:account", Free: 5, Base: 30 * time.Second, Max: time.Hour, Window: 24 * time.Hour}
+	passwordIPLimit       = attemptLimit{Name: "password:ip", Free: 30, Base: time.Minute, Max: time.Hour, Window: time.Hour}
+	twoFactorAccountLimit = attemptLimit{Name: "2fa:account", Free: 5, Base: 30 * time.Second, Max: time.Hour, Window: 24 * time.Hour}
+	twoFactorIPLimit      = attemptLimit{Name: "2fa:ip", Free: 30, Base: time.Minute, Max: time.Hour, Window: time.Hour}
+	captchaIPLimit        = attemptLimit{Name: "captcha:ip", Free: 20, Base: time.Minute, Max: time.Hour, Window: time.Hour}
+	userCodeLimit         = attemptLimit{Name: "device:account", Free: 10, Base: time.Minute, Max: time.Hour, Window: time.Hour}
+	// never locks, the code is burned after captchaMaxFailures instead
+	captchaCodeLimit = attemptLimit{Name: "captcha:code", Window: model.VERIFY_CODE_EXP}
+)
+
+// captchaMaxFailures burn the verification code after that many wrong guesses
+const captchaMaxFailures = 5
+
+// attempt is a key guarded by a limit, like the account or ip of a login
+type attempt struct {
+	limit attemptLimit
+	id    string
+}
+
+func (a attempt) key() string {
+	return a.limit.Name + ":" + a.id
+}
+
+// lockoutOf return the lockout after the n-th failure
+func (l attemptLimit) lockoutOf(n int64) time.Duration {
+	if l.Base == 0 || n < l.Free {
+		return 0
+	}
+	d := l.Base
+	for i := l.Free; i < n && d < l.Max; i++ {
+		d *= 2
+	}
+	if d > l.Max {
+		d = l.Max
+	}
+	return d
+}
+
+// checkLockout return result.LockedError with the longest lockout of attempts
+func checkLockout(ctx context.Context, attempts ...attempt) error {
+	var longest time.Duration
+	for _, a := range attempts {
+		remaining, err := model.LockoutRemaining(ctx, a.key())
+		if err != nil {
+			return err
+		}
+		if remaining > longest {
+			longest = remaining
+		}
+	}
+	if longest > 0 {
+		return result.LockedError{RetryAfter: longest}
+	}
+	return nil
+}
+
+// recordFailure count a failure of attempts and lock those over their limits
+func recordFailure(ctx context.Context, attempts ...attempt) {
+	for _, a := range attempts {
+		n, err := model.RecordFailedAttempt(ctx, a.key(), a.limit.Window)
+		if err != nil {
+			serviceLogger.Errorln("RecordFailedAttempt Err,ErrMsg:", err)
+			continue
+		}
+		if d := a.limit.lockoutOf(n); d > 0 {
+			serviceLogger.Warnf("[%s] failed %d times, locked for %v\n", a.key(), n, d)
+			if err := model.Lockout(ctx, a.key(), d); err != nil {
+				serviceLogger.Errorln("Lockout Err,ErrMsg:", err)
+			}
+		}
+	}
+}
+
+// resetFailures clear counters after a success,
+// lockouts are not lifted, they expire by themselves.
+func resetFailures(ctx context.Context, attempts ...attempt) {
+	for _, a := range attempts {
+		if err := model.ResetFailedAttempts(ctx, a.key()); err != nil {
+			serviceLogger.Errorln("ResetFailedAttempts Err,ErrMsg:", err)
+		}
+	}
+}
+
+// checkPassword is model.CheckPassword guarded by lockout of account and ip
+func checkPassword(ctx context.Context, ip, username, password string) (string, bool, error) {
+	account := attempt{passwordAccountLimit, username}
+	if err := checkLockout(ctx, account, attempt{passwordIPLimit, ip}); err != nil {
+		return "", false, err
+	}
+	uid, rehash, err := model.CheckPassword(username, password)
+	if err != nil {
+		if err == result.PasswordError || err == result.UserNotExist {
+			recordFailure(ctx, account, attempt{passwordIPLimit, ip})
+		}
+		return "", false, err
+	}
+	resetFailures(ctx, account)
+	return uid, rehash, nil
+}
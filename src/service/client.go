@@ -0,0 +1,324 @@
+package service
+
+import (
+	"context"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/NJUPT-SAST/sast-link-backend/model"
+	"github.com/NJUPT-SAST/sast-link-backend/model/result"
+	"github.com/NJUPT-SAST/sast-link-backend/util"
+	"github.com/go-oauth2/oauth2/v4"
+	"github.com/go-oauth2/oauth2/v4/errors"
+)
+
+// MaxSecretOverlap limit how long the previous secret is accepted after rotation
+const MaxSecretOverlap = time.Hour * 24 * 30
+
+// ClientGrantTypes are the grant types developers can choose for their clients
+// client_credentials is enabled by admins with a service account instead.
+var ClientGrantTypes = []string{"authorization_code", "refresh_token", DeviceGrantType}
+
+// new clients use the code flow if grant types are not specified
+var defaultClientGrantTypes = []string{"authorization_code", "refresh_token"}
+
+// ClientMetadata is what developers can set on their clients
+type ClientMetadata struct {
+	Name         string
+	Description  string
+	Logo         string
+	Homepage     string
+	RedirectURIs []string
+	GrantTypes   []string
+	Scopes       []string
+	RequirePKCE  bool
+	// LoopbackRedirect let native apps redirect to any port of registered loopback URIs
+	LoopbackRedirect bool
+	// PostLogoutRedirectURIs and BackchannelLogoutURI are for OpenID Connect logout
+	PostLogoutRedirectURIs []string
+	BackchannelLogoutURI   string
+	// Public clients like SPAs and mobile apps have no secret,
+	// it can only be set on creation.
+	Public bool
+}
+
+// CreateClient register a client owned by uid
+func CreateClient(uid string, metadata *ClientMetadata) (*model.OAuthClient, error) {
+	client, err := newClient(uid, metadata)
+	if err != nil {
+		return nil, err
+	}
+	if err := model.CreateOAuthClient(client); err != nil {
+		serviceLogger.Errorln("CreateOAuthClient Err,ErrMsg:", err)
+		return nil, err
+	}
+	return client, nil
+}
+
+// newClient check metadata and generate the ID and secret of a client owned by uid
+func newClient(uid string, metadata *ClientMetadata) (*model.OAuthClient, error) {
+	if err := checkClientMetadata(metadata); err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	client := &model.OAuthClient{
+		ID:        util.GenerateUUID(),
+		Public:    metadata.Public,
+		UserID:    uid,
+		CreatedAt: &now,
+		UpdatedAt: &now,
+	}
+	if !client.Public {
+		secret, err := util.GenerateRandomString(32)
+		if err != nil {
+			return nil, err
+		}
+		client.Secret = secret
+	}
+	applyClientMetadata(client, metadata)
+	return client, nil
+}
+
+// Clients list clients owned by uid
+func Clients(uid string) ([]model.OAuthClient, error) {
+	clients, err := model.OAuthClientsOf(uid)
+	if err != nil {
+		serviceLogger.Errorln("OAuthClientsOf Err,ErrMsg:", err)
+		return nil, err
+	}
+	return clients, nil
+}
+
+// ClientOf return the client if it is owned by uid
+func ClientOf(ctx context.Context, uid, clientID string) (*model.OAuthClient, error) {
+	client, err := model.OAuthClientByID(ctx, clientID)
+	if err != nil {
+		serviceLogger.Errorln("OAuthClientByID Err,ErrMsg:", err)
+		return nil, err
+	}
+	// clients of others are reported as not existing
+	if client == nil || client.UserID != uid {
+		return nil, result.ClientNotExist
+	}
+	return client, nil
+}
+
+// UpdateClient replace the metadata of the client owned by uid
+func UpdateClient(ctx context.Context, uid, clientID string, metadata *ClientMetadata) (*model.OAuthClient, error) {
+	client, err := ClientOf(ctx, uid, clientID)
+	if err != nil {
+		return nil, err
+	}
+	metadata.Public = client.Public
+	if err := checkClientMetadata(metadata); err != nil {
+		return nil, err
+	}
+	applyClientMetadata(client, metadata)
+	now := time.Now()
+	client.UpdatedAt = &now
+	if err := model.UpdateOAuthClient(client); err != nil {
+		serviceLogger.Errorln("UpdateOAuthClient Err,ErrMsg:", err)
+		return nil, err
+	}
+	return client, nil
+}
+
+// RotateClientSecret generate a new secret for the client owned by uid,
+// the current secret is still accepted within overlap.
+func RotateClientSecret(ctx context.Context, uid, clientID string, overlap time.Duration) (*model.OAuthClient, error) {
+	if overlap < 0 || overlap > MaxSecretOverlap {
+		return nil, result.RequestParamError
+	}
+	client, err := ClientOf(ctx, uid, clientID)
+	if err != nil {
+		return nil, err
+	}
+	if client.Public {
+		return nil, result.RequestParamError
+	}
+	secret, err := util.GenerateRandomString(32)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	client.PreviousSecret, client.PreviousSecretExpiresAt = "", nil
+	if overlap > 0 {
+		expiresAt := now.Add(overlap)
+		client.PreviousSecret, client.PreviousSecretExpiresAt = client.Secret, &expiresAt
+	}
+	client.Secret = secret
+	client.UpdatedAt = &now
+	if err := model.UpdateOAuthClient(client); err != nil {
+		serviceLogger.Errorln("UpdateOAuthClient Err,ErrMsg:", err)
+		return nil, err
+	}
+	return client, nil
+}
+
+// DeleteClient delete the client owned by uid, tokens issued to it are revoked
+func DeleteClient(ctx context.Context, uid, clientID string) error {
+	if _, err := ClientOf(ctx, uid, clientID); err != nil {
+		return err
+	}
+	if err := model.DeleteOAuthClient(clientID); err != nil {
+		serviceLogger.Errorln("DeleteOAuthClient Err,ErrMsg:", err)
+		return err
+	}
+	return nil
+}
+
+// ClientRedirectURIs return the URIs the client can redirect to
+func ClientRedirectURIs(client *model.OAuthClient) []string {
+	if len(client.RedirectURIs) == 0 && client.Domain != "" {
+		return []string{client.Domain}
+	}
+	return client.RedirectURIs
+}
+
+// ValidRedirectURI report whether uri is exactly one of the redirect URIs of client,
+// the port of loopback URIs is ignored if the client opts in, RFC 8252 section 7.3.
+func ValidRedirectURI(client *model.OAuthClient, uri string) bool {
+	for _, registered := range ClientRedirectURIs(client) {
+		if uri == registered || client.LoopbackRedirect && sameLoopbackURI(registered, uri) {
+			return true
+		}
+	}
+	return false
+}
+
+// ResolveRedirectURI return where the authorization response of client goes,
+// uri can only be omitted if the client has one redirect URI.
+func ResolveRedirectURI(client *model.OAuthClient, uri string) (string, error) {
+	if uri == "" {
+		if uris := ClientRedirectURIs(client); len(uris) == 1 {
+			return uris[0], nil
+		}
+		return "", result.InvalidRedirectURI
+	}
+	if !ValidRedirectURI(client, uri) {
+		return "", result.InvalidRedirectURI
+	}
+	return uri, nil
+}
+
+// sameLoopbackURI report whether uri is the loopback URI registered on another port
+func sameLoopbackURI(registered, uri string) bool {
+	r, err := url.Parse(registered)
+	if err != nil || r.Scheme != "http" || !loopbackHost(r.Hostname()) {
+		return false
+	}
+	u, err := url.Parse(uri)
+	if err != nil {
+		return false
+	}
+	return u.Scheme == r.Scheme && u.Hostname() == r.Hostname() &&
+		u.Path == r.Path && u.RawQuery == r.RawQuery && u.Fragment == ""
+}
+
+// loopbackHost only accepts IP literals, `localhost` may resolve elsewhere
+func loopbackHost(host string) bool {
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// ClientGrantAllowed report whether clientID can use the grant type,
+// clients without grant types are not restricted except for client_credentials.
+func ClientGrantAllowed(ctx context.Context, clientID, grantType string) (bool, error) {
+	client, err := model.OAuthClientByID(ctx, clientID)
+	if err != nil {
+		return false, err
+	}
+	if client == nil || !ClientApproved(client) {
+		return false, result.ClientErr
+	}
+	if grantType == ClientCredentialsGrantType {
+		account, err := activeServiceAccount(clientID)
+		return account != nil && !client.Public, err
+	}
+	return len(client.GrantTypes) == 0 || hasString(client.GrantTypes, grantType), nil
+}
+
+func checkClientMetadata(metadata *ClientMetadata) error {
+	// clients without the code flow, like devices, redirect nowhere
+	codeFlow := len(metadata.GrantTypes) == 0 || hasString(metadata.GrantTypes, "authorization_code")
+	if codeFlow && len(metadata.RedirectURIs) == 0 {
+		return result.RequestParamError
+	}
+	for _, uri := range metadata.RedirectURIs {
+		if !absoluteURL(uri) || strings.Contains(uri, "#") {
+			return result.InvalidRedirectURI
+		}
+	}
+	for _, uri := range metadata.PostLogoutRedirectURIs {
+		if !absoluteURL(uri) || strings.Contains(uri, "#") {
+			return result.InvalidRedirectURI
+		}
+	}
+	// the server POSTs to it from inside the network, it must be a public https URL
+	if uri := metadata.BackchannelLogoutURI; uri != "" && !util.PublicHTTPSURL(uri) {
+		return result.RequestParamError
+	}
+	for _, uri := range []string{metadata.Logo, metadata.Homepage} {
+		if uri != "" && !absoluteURL(uri) {
+			return result.RequestParamError
+		}
+	}
+	for _, grantType := range metadata.GrantTypes {
+		if !hasString(ClientGrantTypes, grantType) {
+			return result.RequestParamError
+		}
+	}
+	if !ValidScopes(metadata.Scopes) {
+		return result.InvalidScope
+	}
+	return nil
+}
+
+func applyClientMetadata(client *model.OAuthClient, metadata *ClientMetadata) {
+	client.Name = metadata.Name
+	client.Description = metadata.Description
+	client.Logo = metadata.Logo
+	client.Homepage = metadata.Homepage
+	client.RedirectURIs = metadata.RedirectURIs
+	client.Domain = ""
+	if len(client.RedirectURIs) > 0 {
+		client.Domain = client.RedirectURIs[0]
+	}
+	client.GrantTypes = metadata.GrantTypes
+	if len(client.GrantTypes) == 0 {
+		client.GrantTypes = defaultClientGrantTypes
+	}
+	client.Scopes = metadata.Scopes
+	client.RequirePKCE = metadata.RequirePKCE
+	client.LoopbackRedirect = metadata.LoopbackRedirect
+	client.PostLogoutRedirectURIs = metadata.PostLogoutRedirectURIs
+	client.BackchannelLogoutURI = metadata.BackchannelLogoutURI
+}
+
+// PKCERequired report whether authorization requests of client must have a code challenge
+func PKCERequired(client *model.OAuthClient) bool {
+	return client.Public || client.RequirePKCE
+}
+
+// CheckCodeChallenge check PKCE of an authorization request of client, it is optional
+// for confidential clients but the plain method is never accepted, it does not
+// protect the code if the request is leaked.
+func CheckCodeChallenge(client *model.OAuthClient, challenge string, method oauth2.CodeChallengeMethod) error {
+	if challenge == "" {
+		if PKCERequired(client) {
+			return errors.ErrCodeChallengeRquired
+		}
+		return nil
+	}
+	if method != oauth2.CodeChallengeS256 {
+		return errors.ErrUnsupportedCodeChallengeMethod
+	}
+	return nil
+}
+
+func absoluteURL(uri string) bool {
+	u, err := url.Parse(uri)
+	return err == nil && u.Scheme != "" && u.Host != ""
+}
@@ -0,0 +1,87 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"github.com/NJUPT-SAST/sast-link-backend/config"
+	"github.com/NJUPT-SAST/sast-link-backend/model"
+	"github.com/NJUPT-SAST/sast-link-backend/model/result"
+)
+
+// MaxAccessTokenTTL limit the access token lifetime of a client policy
+const MaxAccessTokenTTL = time.Hour * 24
+
+// the rate limit of clients is counted in this window
+const clientRateWindow = time.Minute
+
+// ClientPolicy is what admins set on a client, the owner can not change it
+type ClientPolicy struct {
+	// AccessTokenTTL and RefreshTokenTTL are the lifetime of tokens, zero for the default
+	AccessTokenTTL  time.Duration
+	RefreshTokenTTL time.Duration
+	// RateLimit is the requests per minute to the token and userinfo endpoints,
+	// zero for `oauth.server.rate_limit`
+	RateLimit int64
+}
+
+// SetClientPolicy set the policy of any client by admin uid
+func SetClientPolicy(ctx context.Context, uid, clientID string, policy *ClientPolicy) (*model.OAuthClient, error) {
+	if policy.AccessTokenTTL < 0 || policy.AccessTokenTTL > MaxAccessTokenTTL ||
+		policy.RefreshTokenTTL < 0 || policy.RefreshTokenTTL > MaxRefreshTokenTTL ||
+		policy.RateLimit < 0 {
+		return nil, result.RequestParamError
+	}
+	client, err := model.OAuthClientByID(ctx, clientID)
+	if err != nil {
+		serviceLogger.Errorln("OAuthClientByID Err,ErrMsg:", err)
+		return nil, err
+	}
+	if client == nil {
+		return nil, result.ClientNotExist
+	}
+	client.AccessTokenTTL = int64(policy.AccessTokenTTL / time.Second)
+	client.RefreshTokenTTL = int64(policy.RefreshTokenTTL / time.Second)
+	client.RateLimit = policy.RateLimit
+	now := time.Now()
+	client.UpdatedAt = &now
+	if err := model.UpdateOAuthClient(client); err != nil {
+		serviceLogger.Errorln("UpdateOAuthClient Err,ErrMsg:", err)
+		return nil, err
+	}
+	serviceLogger.Infof("Admin [%s] set policy of client [%s]: %+v\n", uid, clientID, *policy)
+	return client, nil
+}
+
+// clientRateLimit is the rate limit of client, the server default if it is not set
+func clientRateLimit(client *model.OAuthClient) int64 {
+	if client.RateLimit > 0 {
+		return client.RateLimit
+	}
+	return config.Config.GetInt64("oauth.server.rate_limit")
+}
+
+// ClientAccessTokenTTL return the access token lifetime set for client,
+// zero for the default of the grant.
+func ClientAccessTokenTTL(client *model.OAuthClient) time.Duration {
+	return time.Duration(client.AccessTokenTTL) * time.Second
+}
+
+// CheckClientRate count a request of client to the token or userinfo endpoint,
+// return result.LockedError if it is over the rate limit of the client.
+func CheckClientRate(ctx context.Context, client *model.OAuthClient) error {
+	limit := clientRateLimit(client)
+	if limit <= 0 {
+		return nil
+	}
+	n, left, err := model.CountRequest(ctx, "client:"+client.ID, clientRateWindow)
+	if err != nil {
+		// the limit protects the server, it does not stop requests when redis fails
+		serviceLogger.Errorln("CountRequest Err,ErrMsg:", err)
+		return nil
+	}
+	if n > limit {
+		return result.LockedError{RetryAfter: left}
+	}
+	return nil
+}
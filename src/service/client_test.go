@@ -0,0 +1,41 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/NJUPT-SAST/sast-link-backend/model"
+	"github.com/NJUPT-SAST/sast-link-backend/util"
+	"github.com/go-oauth2/oauth2/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// createTestClient save client with a random ID,
+// it is deleted with its tokens and grants after the test.
+func createTestClient(t *testing.T, client *model.OAuthClient) *model.OAuthClient {
+	t.Helper()
+	id, err := util.GenerateRandomString(16)
+	require.NoError(t, err)
+	client.ID = "test-" + id
+	require.NoError(t, model.CreateOAuthClient(client))
+	t.Cleanup(func() {
+		_ = model.DeleteOAuthClient(client.ID)
+	})
+	return client
+}
+
+func TestCheckCodeChallenge(t *testing.T) {
+	confidential := &model.OAuthClient{ID: "confidential", Secret: "secret"}
+	strict := &model.OAuthClient{ID: "strict", Secret: "secret", RequirePKCE: true}
+	public := &model.OAuthClient{ID: "public", Public: true}
+	challenge := "E9Melhoa2OwvFrEMTJguCHaoeK1t8URWbuGJSstw-cM"
+
+	// PKCE is optional for confidential clients
+	assert.NoError(t, CheckCodeChallenge(confidential, "", oauth2.CodeChallengePlain))
+	assert.Error(t, CheckCodeChallenge(strict, "", ""))
+	assert.Error(t, CheckCodeChallenge(public, "", oauth2.CodeChallengePlain))
+	for _, client := range []*model.OAuthClient{confidential, strict, public} {
+		assert.NoError(t, CheckCodeChallenge(client, challenge, oauth2.CodeChallengeS256))
+		assert.Error(t, CheckCodeChallenge(client, challenge, oauth2.CodeChallengePlain))
+	}
+}
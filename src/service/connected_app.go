@@ -0,0 +1,103 @@
+package service
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/NJUPT-SAST/sast-link-backend/model"
+)
+
+// ConnectedApp is an OAuth client the user has authorized
+type ConnectedApp struct {
+	ClientID     string    `json:"clientId"`
+	ClientName   string    `json:"clientName"`
+	ClientLogo   string    `json:"clientLogo"`
+	Scopes       []string  `json:"scopes"`
+	AuthorizedAt time.Time `json:"authorizedAt"`
+	LastUsedAt   time.Time `json:"lastUsedAt"`
+}
+
+// ConnectedApps list clients holding tokens or remembered consent of uid,
+// the latest used first.
+func ConnectedApps(ctx context.Context, uid string) ([]ConnectedApp, error) {
+	grants, err := model.OAuthGrantsOf(uid)
+	if err != nil {
+		serviceLogger.Errorln("OAuthGrantsOf Err,ErrMsg:", err)
+		return nil, err
+	}
+	usages, err := model.OAuthTokenUsages(uid)
+	if err != nil {
+		serviceLogger.Errorln("OAuthTokenUsages Err,ErrMsg:", err)
+		return nil, err
+	}
+
+	apps := map[string]*ConnectedApp{}
+	appOf := func(clientID string) *ConnectedApp {
+		if app, ok := apps[clientID]; ok {
+			return app
+		}
+		app := &ConnectedApp{ClientID: clientID, Scopes: []string{}}
+		apps[clientID] = app
+		return app
+	}
+	for _, grant := range grants {
+		app := appOf(grant.ClientID)
+		app.Scopes = mergeScopes(app.Scopes, grant.Scope)
+		app.AuthorizedAt = grant.CreatedAt
+		app.LastUsedAt = grant.UpdatedAt
+		if grant.LastUsedAt != nil && grant.LastUsedAt.After(app.LastUsedAt) {
+			app.LastUsedAt = *grant.LastUsedAt
+		}
+	}
+	for _, usage := range usages {
+		app := appOf(usage.ClientID)
+		app.Scopes = mergeScopes(app.Scopes, usage.Scope)
+		if app.AuthorizedAt.IsZero() || usage.FirstIssuedAt.Before(app.AuthorizedAt) {
+			app.AuthorizedAt = usage.FirstIssuedAt
+		}
+		if usage.LastIssuedAt.After(app.LastUsedAt) {
+			app.LastUsedAt = usage.LastIssuedAt
+		}
+	}
+
+	list := make([]ConnectedApp, 0, len(apps))
+	for _, app := range apps {
+		client, err := model.OAuthClientByID(ctx, app.ClientID)
+		if err != nil {
+			serviceLogger.Errorln("OAuthClientByID Err,ErrMsg:", err)
+			return nil, err
+		}
+		// the client may have been deleted, its tokens are still listed to revoke
+		if client != nil {
+			app.ClientName = client.Name
+			app.ClientLogo = client.Logo
+		}
+		list = append(list, *app)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].LastUsedAt.After(list[j].LastUsedAt)
+	})
+	return list, nil
+}
+
+// RevokeApp delete the tokens of clientID for uid and forget the consent,
+// the client has to ask the user again.
+func RevokeApp(ctx context.Context, uid, clientID string) error {
+	if err := model.RevokeOAuthClient(uid, clientID); err != nil {
+		serviceLogger.Errorln("RevokeOAuthClient Err,ErrMsg:", err)
+		return err
+	}
+	if err := model.DeleteConsentDecision(ctx, uid, clientID); err != nil {
+		serviceLogger.Errorln("DeleteConsentDecision Err,ErrMsg:", err)
+		return err
+	}
+	return nil
+}
+
+// TouchApp record clientID used the authorization of uid, errors are only logged
+func TouchApp(uid, clientID string) {
+	if err := model.TouchOAuthGrant(uid, clientID); err != nil {
+		serviceLogger.Errorln("TouchOAuthGrant Err,ErrMsg:", err)
+	}
+}
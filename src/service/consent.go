@@ -0,0 +1,124 @@
+package service
+
+import (
+	"context"
+	"strings"
+
+	"github.com/NJUPT-SAST/sast-link-backend/model"
+	"github.com/NJUPT-SAST/sast-link-backend/model/result"
+)
+
+// ConsentState is the result of CheckConsent
+type ConsentState int
+
+const (
+	// ConsentRequired the user should decide on the consent screen
+	ConsentRequired ConsentState = iota
+	// ConsentApproved the authorization can continue
+	ConsentApproved
+	// ConsentDenied the user refused the client
+	ConsentDenied
+)
+
+// Consent is shown to the user on the consent screen
+type Consent struct {
+	ClientID   string  `json:"clientId"`
+	ClientName string  `json:"clientName"`
+	ClientLogo string  `json:"clientLogo"`
+	Scope      string  `json:"scope"`
+	Scopes     []Scope `json:"scopes"`
+}
+
+// CheckConsent decide whether uid has consented clientID to access scope,
+// a decision made on the consent screen is used once by the retried request.
+// force is `prompt=consent`, the remembered grant is ignored.
+func CheckConsent(ctx context.Context, uid, clientID, scope string, force bool) (ConsentState, error) {
+	scope, err := GrantedScope(ctx, clientID, scope)
+	if err != nil {
+		return ConsentRequired, err
+	}
+	decision, err := model.TakeConsentDecision(ctx, uid, clientID)
+	if err != nil {
+		serviceLogger.Errorln("TakeConsentDecision Err,ErrMsg:", err)
+		return ConsentRequired, err
+	}
+	if decision != nil && decision.Scope == scope {
+		if decision.Approved {
+			return ConsentApproved, nil
+		}
+		return ConsentDenied, nil
+	}
+	if force {
+		return ConsentRequired, nil
+	}
+	grant, err := model.OAuthGrantOf(uid, clientID)
+	if err != nil {
+		serviceLogger.Errorln("OAuthGrantOf Err,ErrMsg:", err)
+		return ConsentRequired, err
+	}
+	if grant != nil && ScopeAllowed(scope, strings.Fields(grant.Scope)) {
+		return ConsentApproved, nil
+	}
+	return ConsentRequired, nil
+}
+
+// ConsentOf return the client and scopes to show on the consent screen
+func ConsentOf(ctx context.Context, clientID, scope string) (*Consent, error) {
+	scope, err := GrantedScope(ctx, clientID, scope)
+	if err != nil {
+		return nil, err
+	}
+	client, err := model.OAuthClientByID(ctx, clientID)
+	if err != nil {
+		return nil, err
+	}
+	if client == nil {
+		return nil, result.ClientErr
+	}
+	consent := &Consent{
+		ClientID:   client.ID,
+		ClientName: client.Name,
+		ClientLogo: client.Logo,
+		Scope:      scope,
+	}
+	for _, s := range strings.Fields(scope) {
+		consent.Scopes = append(consent.Scopes, *ScopeByName(s))
+	}
+	return consent, nil
+}
+
+// DecideConsent record the decision of uid on the consent screen,
+// approved scopes are added to the remembered grant.
+func DecideConsent(ctx context.Context, uid, clientID, scope string, approved bool) error {
+	scope, err := GrantedScope(ctx, clientID, scope)
+	if err != nil {
+		return err
+	}
+	if approved {
+		if err := rememberGrant(uid, clientID, scope); err != nil {
+			return err
+		}
+	}
+	return model.SaveConsentDecision(ctx, uid, clientID, &model.ConsentDecision{
+		Scope:    scope,
+		Approved: approved,
+	}, model.CONSENT_DECISION_EXP)
+}
+
+// rememberGrant add scope to the scopes uid has consented clientID
+func rememberGrant(uid, clientID, scope string) error {
+	grant, err := model.OAuthGrantOf(uid, clientID)
+	if err != nil {
+		serviceLogger.Errorln("OAuthGrantOf Err,ErrMsg:", err)
+		return err
+	}
+	scopes := strings.Fields(scope)
+	if grant != nil {
+		scopes = mergeScopes(scopes, grant.Scope)
+	}
+	if err := model.SaveOAuthGrant(uid, clientID, strings.Join(scopes, " ")); err != nil {
+		serviceLogger.Errorln("SaveOAuthGrant Err,ErrMsg:", err)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,50 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"github.com/NJUPT-SAST/sast-link-backend/model"
+	"github.com/NJUPT-SAST/sast-link-backend/model/result"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckConsent(t *testing.T) {
+	ctx := context.Background()
+	client := createTestClient(t, &model.OAuthClient{
+		Secret: "secret",
+		Domain: "https://example.com/callback",
+		Scopes: []string{"openid", "profile", "email"},
+	})
+	uid := "test-consent-user"
+
+	check := func(scope string, force bool) ConsentState {
+		t.Helper()
+		state, err := CheckConsent(ctx, uid, client.ID, scope, force)
+		require.NoError(t, err)
+		return state
+	}
+
+	assert.Equal(t, ConsentRequired, check("openid profile", false))
+
+	// the decision on the consent screen is used once by the retried request
+	require.NoError(t, DecideConsent(ctx, uid, client.ID, "openid profile", true))
+	assert.Equal(t, ConsentApproved, check("openid profile", true))
+	assert.Equal(t, ConsentRequired, check("openid profile", true))
+
+	// the remembered grant covers its scopes unless prompt=consent
+	assert.Equal(t, ConsentApproved, check("openid", false))
+	assert.Equal(t, ConsentApproved, check("profile openid profile", false))
+	assert.Equal(t, ConsentRequired, check("openid email", false))
+
+	// a denial is used once and not remembered
+	require.NoError(t, DecideConsent(ctx, uid, client.ID, "openid email", false))
+	assert.Equal(t, ConsentDenied, check("openid email", false))
+	assert.Equal(t, ConsentRequired, check("openid email", false))
+
+	_, err := CheckConsent(ctx, uid, client.ID, "badge", false)
+	assert.Equal(t, result.InvalidScope, err)
+	_, err = CheckConsent(ctx, uid, "test-unknown-client", "openid", false)
+	assert.Equal(t, result.ClientErr, err)
+}
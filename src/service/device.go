@@ -0,0 +1,227 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/NJUPT-SAST/sast-link-backend/config"
+	"github.com/NJUPT-SAST/sast-link-backend/model"
+	"github.com/NJUPT-SAST/sast-link-backend/model/result"
+	"github.com/NJUPT-SAST/sast-link-backend/util"
+)
+
+// DeviceGrantType is the grant type of RFC 8628 device authorization grant
+const DeviceGrantType = "urn:ietf:params:oauth:grant-type:device_code"
+
+// user codes avoid vowels and confusable characters, RFC 8628 section 6.1
+const (
+	userCodeCharset = "BCDFGHJKLMNPQRSTVWXZ"
+	userCodeLength  = 8
+)
+
+// errors of polling the token endpoint, the messages are the error codes of RFC 8628
+var (
+	ErrAuthorizationPending = errors.New("authorization_pending")
+	ErrSlowDown             = errors.New("slow_down")
+	ErrDeviceAccessDenied   = errors.New("access_denied")
+	ErrDeviceCodeExpired    = errors.New("expired_token")
+	ErrDeviceCodeMismatch   = errors.New("invalid_grant")
+)
+
+// DeviceVerificationURI is the frontend page to enter the user code
+func DeviceVerificationURI() string {
+	uri := config.Config.GetString("oauth.server.device_verification_uri")
+	if uri == "" {
+		uri = strings.TrimSuffix(config.Config.GetString("oauth.server.front_url"), "/") + "/device"
+	}
+	return uri
+}
+
+// StartDeviceAuthorization create a pending device authorization for clientID,
+// return the device code polled by the device and the user code entered by the user.
+func StartDeviceAuthorization(ctx context.Context, clientID, scope string) (deviceCode, userCode string, err error) {
+	allowed, err := ClientGrantAllowed(ctx, clientID, DeviceGrantType)
+	if err != nil {
+		return "", "", err
+	}
+	if !allowed {
+		return "", "", result.PermissionDenied
+	}
+	if scope, err = GrantedScope(ctx, clientID, scope); err != nil {
+		return "", "", err
+	}
+	if deviceCode, err = util.GenerateRandomString(32); err != nil {
+		return "", "", err
+	}
+	// retry on the rare collision of user codes
+	for i := 0; i < 3; i++ {
+		if userCode, err = generateUserCode(); err != nil {
+			return "", "", err
+		}
+		ok, err := model.SaveDeviceAuthorization(ctx, deviceCode, &model.DeviceAuthorization{
+			ClientID: clientID,
+			Scope:    scope,
+			UserCode: userCode,
+			Status:   model.DEVICE_PENDING,
+			Interval: int64(model.DEVICE_POLL_INTERVAL / time.Second),
+		}, model.DEVICE_CODE_EXP)
+		if err != nil {
+			serviceLogger.Errorln("SaveDeviceAuthorization Err,ErrMsg:", err)
+			return "", "", err
+		}
+		if ok {
+			return deviceCode, userCode, nil
+		}
+	}
+	return "", "", result.InternalErr
+}
+
+// DeviceConsent return the consent screen of the device waiting for userCode
+func DeviceConsent(ctx context.Context, uid, userCode string) (*Consent, error) {
+	_, auth, err := pendingDevice(ctx, uid, userCode)
+	if err != nil {
+		return nil, err
+	}
+	return ConsentOf(ctx, auth.ClientID, auth.Scope)
+}
+
+// DecideDevice record the decision of uid for the device waiting for userCode,
+// approved scopes are remembered like the consent screen.
+func DecideDevice(ctx context.Context, uid, userCode string, approved bool) error {
+	deviceCode, auth, err := pendingDevice(ctx, uid, userCode)
+	if err != nil {
+		return err
+	}
+	status := model.DEVICE_DENIED
+	if approved {
+		if err := rememberGrant(uid, auth.ClientID, auth.Scope); err != nil {
+			return err
+		}
+		status = model.DEVICE_APPROVED
+	}
+	ok, err := model.UpdateDeviceAuthorization(ctx, deviceCode, map[string]interface{}{
+		"status":  status,
+		"user_id": uid,
+	})
+	if err != nil {
+		serviceLogger.Errorln("UpdateDeviceAuthorization Err,ErrMsg:", err)
+		return err
+	}
+	if !ok {
+		return result.UserCodeInvalid
+	}
+	return nil
+}
+
+// PollDevice check the device authorization polled by clientID,
+// it is deleted and returned once the user approved it, to one poll only.
+func PollDevice(ctx context.Context, clientID, deviceCode string) (*model.DeviceAuthorization, error) {
+	auth, err := model.DeviceAuthorizationByCode(ctx, deviceCode)
+	if err != nil {
+		serviceLogger.Errorln("DeviceAuthorizationByCode Err,ErrMsg:", err)
+		return nil, err
+	}
+	if auth == nil {
+		return nil, ErrDeviceCodeExpired
+	}
+	if auth.ClientID != clientID {
+		return nil, ErrDeviceCodeMismatch
+	}
+	switch auth.Status {
+	case model.DEVICE_APPROVED:
+		ok, err := model.ClaimDeviceAuthorization(ctx, deviceCode, auth.UserCode, model.DEVICE_APPROVED)
+		if err != nil {
+			serviceLogger.Errorln("ClaimDeviceAuthorization Err,ErrMsg:", err)
+			return nil, err
+		}
+		if !ok {
+			// a concurrent poll got the token
+			return nil, ErrDeviceCodeExpired
+		}
+		return auth, nil
+	case model.DEVICE_DENIED:
+		if _, err := model.ClaimDeviceAuthorization(ctx, deviceCode, auth.UserCode, model.DEVICE_DENIED); err != nil {
+			serviceLogger.Errorln("ClaimDeviceAuthorization Err,ErrMsg:", err)
+		}
+		return nil, ErrDeviceAccessDenied
+	}
+
+	now := time.Now().Unix()
+	fields := map[string]interface{}{"last_poll": now}
+	pollErr := ErrAuthorizationPending
+	if now-auth.LastPoll < auth.Interval {
+		// RFC 8628 section 3.5, the interval is increased by 5 seconds
+		fields["interval"] = auth.Interval + 5
+		pollErr = ErrSlowDown
+	}
+	ok, err := model.UpdateDeviceAuthorization(ctx, deviceCode, fields)
+	if err != nil {
+		serviceLogger.Errorln("UpdateDeviceAuthorization Err,ErrMsg:", err)
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrDeviceCodeExpired
+	}
+	return nil, pollErr
+}
+
+// pendingDevice find the pending device authorization of userCode,
+// wrong user codes are limited per user against guessing.
+func pendingDevice(ctx context.Context, uid, userCode string) (string, *model.DeviceAuthorization, error) {
+	account := attempt{userCodeLimit, uid}
+	if err := checkLockout(ctx, account); err != nil {
+		return "", nil, err
+	}
+	deviceCode, err := model.DeviceCodeByUserCode(ctx, normalizeUserCode(userCode))
+	if err != nil {
+		serviceLogger.Errorln("DeviceCodeByUserCode Err,ErrMsg:", err)
+		return "", nil, err
+	}
+	var auth *model.DeviceAuthorization
+	if deviceCode != "" {
+		if auth, err = model.DeviceAuthorizationByCode(ctx, deviceCode); err != nil {
+			serviceLogger.Errorln("DeviceAuthorizationByCode Err,ErrMsg:", err)
+			return "", nil, err
+		}
+	}
+	if auth == nil || auth.Status != model.DEVICE_PENDING {
+		recordFailure(ctx, account)
+		return "", nil, result.UserCodeInvalid
+	}
+	return deviceCode, auth, nil
+}
+
+// generateUserCode return a random code like `BCDF-GHJK`
+func generateUserCode() (string, error) {
+	var b strings.Builder
+	size := big.NewInt(int64(len(userCodeCharset)))
+	for i := 0; i < userCodeLength; i++ {
+		if i == userCodeLength/2 {
+			b.WriteByte('-')
+		}
+		n, err := rand.Int(rand.Reader, size)
+		if err != nil {
+			return "", err
+		}
+		b.WriteByte(userCodeCharset[n.Int64()])
+	}
+	return b.String(), nil
+}
+
+// normalizeUserCode accept user codes typed in lower case or without the dash
+func normalizeUserCode(userCode string) string {
+	code := strings.Map(func(r rune) rune {
+		if r == '-' || r == ' ' {
+			return -1
+		}
+		return r
+	}, strings.ToUpper(userCode))
+	if len(code) != userCodeLength {
+		return code
+	}
+	return code[:userCodeLength/2] + "-" + code[userCodeLength/2:]
+}
@@ -0,0 +1,71 @@
+package service
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/NJUPT-SAST/sast-link-backend/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPollDevice(t *testing.T) {
+	ctx := context.Background()
+	client := createTestClient(t, &model.OAuthClient{
+		Secret:     "secret",
+		GrantTypes: []string{DeviceGrantType},
+	})
+	uid := "test-device-user"
+
+	deviceCode, userCode, err := StartDeviceAuthorization(ctx, client.ID, "openid")
+	require.NoError(t, err)
+
+	_, err = PollDevice(ctx, client.ID, deviceCode)
+	assert.Equal(t, ErrAuthorizationPending, err)
+	// polled again within the interval
+	_, err = PollDevice(ctx, client.ID, deviceCode)
+	assert.Equal(t, ErrSlowDown, err)
+	_, err = PollDevice(ctx, "test-another-client", deviceCode)
+	assert.Equal(t, ErrDeviceCodeMismatch, err)
+	_, err = PollDevice(ctx, client.ID, "unknown-device-code")
+	assert.Equal(t, ErrDeviceCodeExpired, err)
+
+	require.NoError(t, DecideDevice(ctx, uid, userCode, true))
+	// only one of the concurrent polls gets the approval
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		approved []*model.DeviceAuthorization
+	)
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			auth, err := PollDevice(ctx, client.ID, deviceCode)
+			if err != nil {
+				assert.Equal(t, ErrDeviceCodeExpired, err)
+				return
+			}
+			mu.Lock()
+			approved = append(approved, auth)
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+	require.Len(t, approved, 1)
+	assert.Equal(t, uid, approved[0].UserID)
+	assert.Equal(t, "openid", approved[0].Scope)
+	_, err = PollDevice(ctx, client.ID, deviceCode)
+	assert.Equal(t, ErrDeviceCodeExpired, err)
+	// the user code can not be used again
+	assert.Error(t, DecideDevice(ctx, uid, userCode, true))
+
+	deviceCode, userCode, err = StartDeviceAuthorization(ctx, client.ID, "openid")
+	require.NoError(t, err)
+	require.NoError(t, DecideDevice(ctx, uid, userCode, false))
+	_, err = PollDevice(ctx, client.ID, deviceCode)
+	assert.Equal(t, ErrDeviceAccessDenied, err)
+	_, err = PollDevice(ctx, client.ID, deviceCode)
+	assert.Equal(t, ErrDeviceCodeExpired, err)
+}
@@ -0,0 +1,81 @@
+package service
+
+import (
+	"strings"
+
+	"github.com/NJUPT-SAST/sast-link-backend/model"
+	"github.com/NJUPT-SAST/sast-link-backend/model/result"
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+)
+
+// RequestEmailChange re-authenticate user with password (and 2FA code if enabled),
+// then send a verification code to the new email address.
+func RequestEmailChange(ctx *gin.Context, uid, password, twoFactorCode, email string) error {
+	email = strings.ToLower(strings.TrimSpace(email))
+	if err := CheckEmailPolicy(email); err != nil {
+		return err
+	}
+
+	if err := reauthenticate(ctx, uid, password, twoFactorCode); err != nil {
+		return err
+	}
+
+	if err := checkEmailAvailable(uid, email); err != nil {
+		return err
+	}
+	model.Rdb.Set(ctx, model.ChangeEmailKey(uid), email, model.VERIFY_CODE_EXP)
+	return sendCaptcha(ctx, email, "确认修改SAST-Link账户邮箱（无需回复）")
+}
+
+// ConfirmEmailChange update email of user with the code sent by RequestEmailChange,
+// the old address is notified after the change.
+func ConfirmEmailChange(ctx *gin.Context, uid, code string) (string, error) {
+	email, err := model.Rdb.Get(ctx, model.ChangeEmailKey(uid)).Result()
+	if err != nil {
+		if err == redis.Nil {
+			return "", result.CaptchaError
+		}
+		return "", err
+	}
+	if err := checkCaptcha(ctx, email, code); err != nil {
+		return "", err
+	}
+	model.Rdb.Del(ctx, model.ChangeEmailKey(uid))
+
+	user, err := model.UserByField("uid", uid)
+	if err != nil {
+		return "", err
+	}
+	if user == nil {
+		return "", result.UserNotExist
+	}
+	// the address may be taken while waiting for the code
+	if err := checkEmailAvailable(uid, email); err != nil {
+		return "", err
+	}
+	if err := model.ChangeEmail(uid, email); err != nil {
+		serviceLogger.Errorln("ChangeEmail Err,ErrMsg:", err)
+		return "", err
+	}
+	serviceLogger.Infof("User [%s] changed email from [%s] to [%s]\n", uid, *user.Email, email)
+
+	if err := model.SendEmail(*user.Email, model.InsertEmailChanged(email), "SAST-Link账户邮箱已修改（无需回复）"); err != nil {
+		serviceLogger.Errorln("notify old email Err,ErrMsg:", err)
+	}
+	return email, nil
+}
+
+func checkEmailAvailable(uid, email string) error {
+	user, err := model.UserByField("email", email)
+	if err != nil {
+		return err
+	}
+	if user != nil {
+		if *user.Uid == uid {
+			return result.RequestParamError
+		}
+		return result.EmailIsUsed
+	}
+	return nil
+}
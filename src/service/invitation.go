@@ -0,0 +1,83 @@
+package service
+
+import (
+	"strings"
+	"time"
+
+	"github.com/NJUPT-SAST/sast-link-backend/model"
+	"github.com/NJUPT-SAST/sast-link-backend/model/result"
+	"github.com/NJUPT-SAST/sast-link-backend/util"
+)
+
+const (
+	invitationCodeLength = 12
+	// an invitation can not live longer than this
+	invitationMaxTTL = 90 * 24 * time.Hour
+)
+
+// CreateInvitation generate an invitation code by admin uid,
+// orgId is preset to profile of the invited users if not nil.
+func CreateInvitation(uid string, maxUses int, ttl time.Duration, orgId *int, note string) (*model.Invitation, error) {
+	if maxUses <= 0 || ttl <= 0 || ttl > invitationMaxTTL {
+		return nil, result.RequestParamError
+	}
+	if orgId != nil {
+		if dep, _, err := model.GetDepAndOrgByOrgId(*orgId); err != nil || dep == "" {
+			return nil, result.OrgIdError
+		}
+	}
+	code, err := util.GenerateRandomString(invitationCodeLength)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	invitation := &model.Invitation{
+		Code:      strings.ToUpper(code),
+		CreatedBy: uid,
+		Note:      note,
+		OrgId:     orgId,
+		MaxUses:   maxUses,
+		ExpiresAt: now.Add(ttl),
+		CreatedAt: now,
+	}
+	if err := model.CreateInvitation(invitation); err != nil {
+		return nil, err
+	}
+	serviceLogger.Infof("Admin [%s] created invitation [%d] for %d uses\n", uid, invitation.ID, maxUses)
+	return invitation, nil
+}
+
+// Invitations list all invitations with their redemptions
+func Invitations() ([]model.Invitation, error) {
+	return model.Invitations()
+}
+
+// ExpireInvitation stop an invitation before it expires
+func ExpireInvitation(id uint) error {
+	expired, err := model.ExpireInvitation(id)
+	if err != nil {
+		return err
+	}
+	if !expired {
+		return result.InvitationInvalid
+	}
+	return nil
+}
+
+// checkInvitation return InvitationInvalid if code can not be used now,
+// it is redeemed only when the user is created.
+func checkInvitation(code string) error {
+	invitation, err := model.InvitationByCode(code)
+	if err != nil {
+		return err
+	}
+	if invitation == nil || invitation.UsedCount >= invitation.MaxUses || !time.Now().Before(invitation.ExpiresAt) {
+		return result.InvitationInvalid
+	}
+	return nil
+}
+
+// IsAdmin report whether uid can manage invitations
+func IsAdmin(uid string) (bool, error) {
+	return model.IsAdmin(uid)
+}
@@ -0,0 +1,247 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/NJUPT-SAST/sast-link-backend/config"
+	"github.com/NJUPT-SAST/sast-link-backend/model"
+	"github.com/NJUPT-SAST/sast-link-backend/model/result"
+	"github.com/NJUPT-SAST/sast-link-backend/util"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	backchannelLogoutEvent = "http://schemas.openid.net/event/backchannel-logout"
+	// logout tokens are signed on every attempt, they only need to live through one
+	logoutTokenExp           = 2 * time.Minute
+	defaultLogoutInterval    = 5 * time.Second
+	backchannelLogoutBatch   = 100
+	backchannelLogoutRetries = 5
+	// the first retry waits this long, it doubles on every attempt
+	backchannelLogoutBackoff = 30 * time.Second
+)
+
+// backchannelClient only connects to public addresses and does not follow redirects,
+// registering a back-channel logout URI must not reach into the network.
+var backchannelClient = &http.Client{
+	Timeout: 5 * time.Second,
+	Transport: &http.Transport{
+		DialContext: (&net.Dialer{
+			Timeout: 5 * time.Second,
+			Control: util.PublicDialControl,
+		}).DialContext,
+		TLSHandshakeTimeout: 5 * time.Second,
+	},
+	CheckRedirect: func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	},
+}
+
+// logoutTokenClaims is the logout token of OpenID Connect Back-Channel Logout section 2.4
+type logoutTokenClaims struct {
+	jwt.RegisteredClaims
+	Sid    string              `json:"sid"`
+	Events map[string]struct{} `json:"events"`
+}
+
+// LogoutRequest is a checked RP-initiated logout request
+type LogoutRequest struct {
+	ClientID string
+	// UserID is the subject of id_token_hint, empty without hint
+	UserID string
+	// RedirectURI is the registered post_logout_redirect_uri, empty to stay
+	RedirectURI string
+}
+
+// CheckLogoutRequest check the parameters of OpenID Connect RP-Initiated Logout,
+// post_logout_redirect_uri must be registered by the client of id_token_hint or client_id.
+func CheckLogoutRequest(ctx context.Context, idTokenHint, clientID, redirectURI string) (*LogoutRequest, error) {
+	req := &LogoutRequest{ClientID: clientID}
+	if idTokenHint != "" {
+		key, err := signingKey()
+		if err != nil {
+			return nil, err
+		}
+		// the hint is still accepted after it expires
+		claims := jwt.RegisteredClaims{}
+		if err := key.Verify(idTokenHint, &claims); err != nil ||
+			claims.Issuer != OIDCIssuer() || len(claims.Audience) == 0 {
+			return nil, result.InvalidIDTokenHint
+		}
+		if clientID != "" && !hasString(claims.Audience, clientID) {
+			return nil, result.InvalidIDTokenHint
+		}
+		req.ClientID = claims.Audience[0]
+		req.UserID = claims.Subject
+	}
+	if redirectURI == "" {
+		return req, nil
+	}
+	if req.ClientID == "" {
+		return nil, result.InvalidRedirectURI
+	}
+	client, err := model.OAuthClientByID(ctx, req.ClientID)
+	if err != nil {
+		serviceLogger.Errorln("OAuthClientByID Err,ErrMsg:", err)
+		return nil, err
+	}
+	if client == nil || !hasString(client.PostLogoutRedirectURIs, redirectURI) {
+		return nil, result.InvalidRedirectURI
+	}
+	req.RedirectURI = redirectURI
+	return req, nil
+}
+
+// AddSessionClient remember clientID got an ID token in login session sid,
+// it is notified by back-channel when the session logs out.
+func AddSessionClient(ctx context.Context, sid, clientID string) {
+	if err := model.AddSessionClient(ctx, sid, clientID); err != nil {
+		serviceLogger.Errorln("AddSessionClient Err,ErrMsg:", err)
+	}
+}
+
+// oidcSessionID is the `sid` claim of login session sid, which is the `jti`
+// of login tokens and not shown to clients as is.
+func oidcSessionID(sid string) string {
+	return hashToken(sid)
+}
+
+// logoutSessions revoke sessions of uid and queue logout tokens to their clients
+func logoutSessions(ctx context.Context, uid string, sids ...string) error {
+	var logouts []*model.BackchannelLogout
+	for _, sid := range sids {
+		clientIDs, err := model.SessionClients(ctx, sid)
+		if err != nil {
+			return err
+		}
+		for _, clientID := range clientIDs {
+			logouts = append(logouts, &model.BackchannelLogout{
+				ID:       util.GenerateUUID(),
+				ClientID: clientID,
+				UserID:   uid,
+				Sid:      sid,
+			})
+		}
+	}
+	if err := model.DeleteSessions(ctx, uid, sids...); err != nil {
+		return err
+	}
+	// the session is gone anyway, clients not notified time out by themselves
+	if err := model.EnqueueBackchannelLogouts(ctx, time.Now(), logouts...); err != nil {
+		serviceLogger.Errorln("EnqueueBackchannelLogouts Err,ErrMsg:", err)
+	}
+	return nil
+}
+
+// logoutUser revoke all sessions of uid, like logoutSessions
+func logoutUser(ctx context.Context, uid string) error {
+	sids, err := model.SessionIDsByUid(ctx, uid)
+	if err != nil {
+		return err
+	}
+	return logoutSessions(ctx, uid, sids...)
+}
+
+// StartBackchannelLogout deliver queued logout tokens to clients periodically,
+// failed deliveries are retried with backoff. It runs until ctx is done.
+func StartBackchannelLogout(ctx context.Context) {
+	interval := config.Config.GetDuration("oidc.backchannel_logout_interval")
+	if interval <= 0 {
+		interval = defaultLogoutInterval
+	}
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			deliverBackchannelLogouts(ctx)
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+func deliverBackchannelLogouts(ctx context.Context) {
+	logouts, err := model.PopBackchannelLogouts(ctx, time.Now(), backchannelLogoutBatch)
+	if err != nil {
+		serviceLogger.Errorln("PopBackchannelLogouts Err,ErrMsg:", err)
+		return
+	}
+	for i := range logouts {
+		logout := &logouts[i]
+		err := sendBackchannelLogout(ctx, logout)
+		if err == nil {
+			continue
+		}
+		logout.Attempts++
+		if logout.Attempts > backchannelLogoutRetries {
+			serviceLogger.Warnf("give up back-channel logout of user [%s] to client [%s]: %v\n", logout.UserID, logout.ClientID, err)
+			continue
+		}
+		next := time.Now().Add(backchannelLogoutBackoff << (logout.Attempts - 1))
+		if err := model.EnqueueBackchannelLogouts(ctx, next, logout); err != nil {
+			serviceLogger.Errorln("EnqueueBackchannelLogouts Err,ErrMsg:", err)
+		}
+	}
+}
+
+// sendBackchannelLogout POST the logout token to the client, RFC 8417 style `events`
+// tell it apart from ID tokens.
+func sendBackchannelLogout(ctx context.Context, logout *model.BackchannelLogout) error {
+	client, err := model.OAuthClientByID(ctx, logout.ClientID)
+	if err != nil {
+		return err
+	}
+	// deleted clients and clients without back-channel logout are skipped
+	if client == nil || client.BackchannelLogoutURI == "" {
+		return nil
+	}
+	// URIs registered before they were checked are not retried
+	if !util.PublicHTTPSURL(client.BackchannelLogoutURI) {
+		serviceLogger.Warnf("skip back-channel logout to [%s] of client [%s]\n", client.BackchannelLogoutURI, client.ID)
+		return nil
+	}
+	key, err := signingKey()
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	token, err := key.SignWithType("logout+jwt", logoutTokenClaims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    OIDCIssuer(),
+			Subject:   logout.UserID,
+			Audience:  jwt.ClaimStrings{logout.ClientID},
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(logoutTokenExp)),
+			ID:        util.GenerateUUID(),
+		},
+		Sid:    oidcSessionID(logout.Sid),
+		Events: map[string]struct{}{backchannelLogoutEvent: {}},
+	})
+	if err != nil {
+		return err
+	}
+	body := url.Values{"logout_token": {token}}.Encode()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, client.BackchannelLogoutURI, strings.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	res, err := backchannelClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode/100 != 2 {
+		return fmt.Errorf("back-channel logout responds %s", res.Status)
+	}
+	return nil
+}
@@ -64,3 +64,32 @@ func UserByLarkID(username, unionID string) (*model.User, error) {
 func OauthUserInfo(userID string) (*model.User, error) {
 	return model.UserInfo(userID)
 }
+
+// OauthUserClaims return the userinfo of uid released by the granted scope
+func OauthUserClaims(uid, scope string) (map[string]interface{}, error) {
+	user, err := OauthUserInfo(uid)
+	if err != nil {
+		return nil, err
+	}
+	profile, err := GetProfileInfo(*user.Uid)
+	if err != nil {
+		return nil, err
+	}
+	dep, org, err := GetProfileOrg(profile.OrgId)
+	if err != nil {
+		return nil, err
+	}
+	claims := ReleasedClaims(map[string]interface{}{
+		"nickname": profile.Nickname,
+		"dep":      dep,
+		"org":      org,
+		"email":    profile.Email,
+		"avatar":   profile.Avatar,
+		"bio":      profile.Bio,
+		"link":     profile.Link,
+		"badge":    profile.Badge,
+		"hide":     profile.Hide,
+	}, scope)
+	claims["userId"] = user.Uid
+	return claims, nil
+}
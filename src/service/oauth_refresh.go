@@ -0,0 +1,95 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/NJUPT-SAST/sast-link-backend/model"
+	"github.com/go-oauth2/oauth2/v4"
+)
+
+// MaxRefreshTokenTTL limit the refresh token lifetime of a client policy
+const MaxRefreshTokenTTL = time.Hour * 24 * 90
+
+// maxSecurityEvents limit the security events listed at once
+const maxSecurityEvents = 500
+
+// ErrRefreshTokenReused is returned when a rotated refresh token is presented again
+var ErrRefreshTokenReused = errors.New("refresh token reused")
+
+// ClientRefreshTokenTTL return the refresh token lifetime set for client,
+// zero for the default of the grant.
+func ClientRefreshTokenTTL(client *model.OAuthClient) time.Duration {
+	return time.Duration(client.RefreshTokenTTL) * time.Second
+}
+
+// CheckRefreshTokenReuse return ErrRefreshTokenReused if refresh has been rotated,
+// the tokens of its grant chain are revoked since one of the holders may be an attacker.
+// ip is the address the token is presented from, recorded in the security event.
+func CheckRefreshTokenReuse(ctx context.Context, ip, refresh string) error {
+	used, err := model.UsedOAuthRefreshByHash(ctx, hashRefreshToken(refresh))
+	if err != nil {
+		serviceLogger.Errorln("UsedOAuthRefreshByHash Err,ErrMsg:", err)
+		return err
+	}
+	if used == nil {
+		return nil
+	}
+	return revokeRefreshChain(ip, used)
+}
+
+// ClaimRefreshToken mark the refresh token of ti used before it is rotated,
+// a token claimed by a concurrent request is reused.
+func ClaimRefreshToken(ctx context.Context, ip string, ti oauth2.TokenInfo) error {
+	used := &model.UsedOAuthRefresh{ClientID: ti.GetClientID(), UserID: ti.GetUserID()}
+	// remember the token as long as it could have been used
+	exp := MaxRefreshTokenTTL
+	if expiresIn := ti.GetRefreshExpiresIn(); expiresIn > 0 {
+		exp = time.Until(ti.GetRefreshCreateAt().Add(expiresIn))
+	}
+	if exp < time.Second {
+		exp = time.Second
+	}
+	ok, err := model.ClaimOAuthRefresh(ctx, hashRefreshToken(ti.GetRefresh()), used, exp)
+	if err != nil {
+		serviceLogger.Errorln("ClaimOAuthRefresh Err,ErrMsg:", err)
+		return err
+	}
+	if !ok {
+		return revokeRefreshChain(ip, used)
+	}
+	return nil
+}
+
+// ReleaseRefreshToken forget the claim of a refresh token which was not rotated
+func ReleaseRefreshToken(ctx context.Context, refresh string) {
+	if err := model.ReleaseOAuthRefresh(ctx, hashRefreshToken(refresh)); err != nil {
+		serviceLogger.Errorln("ReleaseOAuthRefresh Err,ErrMsg:", err)
+	}
+}
+
+func revokeRefreshChain(ip string, used *model.UsedOAuthRefresh) error {
+	serviceLogger.Warnf("security event: refresh token of client [%s] for user [%s] reused from [%s], revoke the grant chain\n", used.ClientID, used.UserID, ip)
+	event := &model.SecurityEvent{
+		Event:    model.EVENT_REFRESH_TOKEN_REUSED,
+		ClientID: used.ClientID,
+		UserID:   used.UserID,
+		IP:       ip,
+	}
+	// the chain is revoked even if the event is lost
+	if err := model.CreateSecurityEvent(event); err != nil {
+		serviceLogger.Errorln("CreateSecurityEvent Err,ErrMsg:", err)
+	}
+	if err := model.RevokeOAuthTokens(used.UserID, used.ClientID); err != nil {
+		serviceLogger.Errorln("RevokeOAuthTokens Err,ErrMsg:", err)
+		return err
+	}
+	return ErrRefreshTokenReused
+}
+
+// SecurityEvents list the latest security events for admins,
+// filtered by clientID and userID if they are not empty.
+func SecurityEvents(clientID, userID string) ([]model.SecurityEvent, error) {
+	return model.SecurityEvents(clientID, userID, maxSecurityEvents)
+}
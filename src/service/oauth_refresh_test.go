@@ -0,0 +1,60 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/NJUPT-SAST/sast-link-backend/model"
+	"github.com/NJUPT-SAST/sast-link-backend/util"
+	"github.com/go-oauth2/oauth2/v4/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClaimRefreshToken(t *testing.T) {
+	ctx := context.Background()
+	client := createTestClient(t, &model.OAuthClient{Secret: "secret", Domain: "https://example.com/callback"})
+	uid, ip := "test-refresh-user", "203.0.113.7"
+	t.Cleanup(func() {
+		model.Db.Table("oauth2_security_event").Where("client_id = ?", client.ID).Delete(&model.SecurityEvent{})
+	})
+
+	newToken := func() *models.Token {
+		t.Helper()
+		refresh, err := util.GenerateRandomString(32)
+		require.NoError(t, err)
+		ti := models.NewToken()
+		ti.SetClientID(client.ID)
+		ti.SetUserID(uid)
+		ti.SetRefresh(refresh)
+		ti.SetRefreshCreateAt(time.Now())
+		ti.SetRefreshExpiresIn(time.Minute)
+		t.Cleanup(func() {
+			ReleaseRefreshToken(ctx, refresh)
+		})
+		return ti
+	}
+
+	ti := newToken()
+	require.NoError(t, CheckRefreshTokenReuse(ctx, ip, ti.GetRefresh()))
+	require.NoError(t, ClaimRefreshToken(ctx, ip, ti))
+	// claimed by a concurrent request, or presented after rotation
+	assert.Equal(t, ErrRefreshTokenReused, ClaimRefreshToken(ctx, ip, ti))
+	assert.Equal(t, ErrRefreshTokenReused, CheckRefreshTokenReuse(ctx, ip, ti.GetRefresh()))
+
+	events, err := SecurityEvents(client.ID, uid)
+	require.NoError(t, err)
+	require.Len(t, events, 2)
+	for _, event := range events {
+		assert.Equal(t, model.EVENT_REFRESH_TOKEN_REUSED, event.Event)
+		assert.Equal(t, ip, event.IP)
+	}
+
+	// a token which failed to rotate can be used again
+	ti = newToken()
+	require.NoError(t, ClaimRefreshToken(ctx, ip, ti))
+	ReleaseRefreshToken(ctx, ti.GetRefresh())
+	assert.NoError(t, CheckRefreshTokenReuse(ctx, ip, ti.GetRefresh()))
+	assert.NoError(t, ClaimRefreshToken(ctx, ip, ti))
+}
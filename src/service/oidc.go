@@ -0,0 +1,209 @@
+package service
+
+import (
+	"context"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/NJUPT-SAST/sast-link-backend/config"
+	"github.com/NJUPT-SAST/sast-link-backend/model"
+	"github.com/NJUPT-SAST/sast-link-backend/model/result"
+	"github.com/NJUPT-SAST/sast-link-backend/util"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+var (
+	idTokenKey     *util.SigningKey
+	idTokenKeyErr  error
+	idTokenKeyOnce sync.Once
+)
+
+// signingKey load the ID token key from `oidc.private_key_file`,
+// or generate one if it is not configured.
+func signingKey() (*util.SigningKey, error) {
+	idTokenKeyOnce.Do(func() {
+		file := config.Config.GetString("oidc.private_key_file")
+		if file == "" {
+			alg := config.Config.GetString("oidc.signing_alg")
+			if alg == "" {
+				alg = "RS256"
+			}
+			serviceLogger.Warnf("oidc.private_key_file is not set, generate a %s key, ID tokens will be invalid after restart\n", alg)
+			idTokenKey, idTokenKeyErr = util.GenerateSigningKey(alg)
+			return
+		}
+		pemBytes, err := os.ReadFile(file)
+		if err != nil {
+			idTokenKeyErr = err
+			return
+		}
+		idTokenKey, idTokenKeyErr = util.ParseSigningKey(pemBytes)
+	})
+	return idTokenKey, idTokenKeyErr
+}
+
+// OIDCIssuer is the `iss` of ID tokens
+func OIDCIssuer() string {
+	return strings.TrimSuffix(config.Config.GetString("oidc.issuer"), "/")
+}
+
+// OIDCSigningAlg is the algorithm of ID tokens
+func OIDCSigningAlg() (string, error) {
+	key, err := signingKey()
+	if err != nil {
+		return "", err
+	}
+	return key.Alg, nil
+}
+
+// JWKS return the public keys to verify ID tokens and JWT access tokens
+func JWKS() ([]map[string]string, error) {
+	key, err := signingKey()
+	if err != nil {
+		return nil, err
+	}
+	keys := []*util.SigningKey{key}
+	if JWTAccessToken() {
+		accessKeys, err := accessTokenKeySet()
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, accessKeys.Published(time.Now())...)
+	}
+	jwks := make([]map[string]string, 0, len(keys))
+	published := map[string]bool{}
+	for _, k := range keys {
+		// the ID token key may also sign access tokens
+		if published[k.ID] {
+			continue
+		}
+		published[k.ID] = true
+		jwks = append(jwks, k.JWK())
+	}
+	return jwks, nil
+}
+
+// HasScope report whether the space separated scope contains s
+func HasScope(scope, s string) bool {
+	for _, item := range strings.Fields(scope) {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+// AuthTime return when the user of the login session authenticated,
+// refreshing the login token does not change it.
+func AuthTime(ctx context.Context, sid string) (time.Time, error) {
+	session, err := model.SessionByID(ctx, sid)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if session == nil {
+		return time.Time{}, result.TokenError
+	}
+	return time.Unix(session.CreatedAt, 0), nil
+}
+
+// SaveOIDCAuthorization remember nonce, auth_time and login session sid
+// of an authorization code for its ID token
+func SaveOIDCAuthorization(ctx context.Context, code, nonce, sid string, authTime time.Time) error {
+	return model.SaveOIDCAuthorization(ctx, code, &model.OIDCAuthorization{
+		Nonce:    nonce,
+		AuthTime: authTime.Unix(),
+		Sid:      sid,
+	}, model.OIDC_CODE_EXP)
+}
+
+// OIDCAuthorization return nil if code is not requested with `openid` scope
+func OIDCAuthorization(ctx context.Context, code string) (*model.OIDCAuthorization, error) {
+	return model.OIDCAuthorizationByCode(ctx, code)
+}
+
+// DeleteOIDCAuthorization forget the code after it is exchanged
+func DeleteOIDCAuthorization(ctx context.Context, code string) {
+	if err := model.DeleteOIDCAuthorization(ctx, code); err != nil {
+		serviceLogger.Errorln("DeleteOIDCAuthorization Err,ErrMsg:", err)
+	}
+}
+
+// IDToken sign the ID token for clientID issued with the access token
+func IDToken(clientID, uid, scope, accessToken string, auth *model.OIDCAuthorization) (string, error) {
+	key, err := signingKey()
+	if err != nil {
+		return "", err
+	}
+	claims, err := UserClaims(uid, scope)
+	if err != nil {
+		return "", err
+	}
+	now := time.Now()
+	claims["iss"] = OIDCIssuer()
+	claims["aud"] = clientID
+	claims["iat"] = now.Unix()
+	claims["exp"] = now.Add(model.ID_TOKEN_EXP).Unix()
+	claims["auth_time"] = auth.AuthTime
+	if auth.Nonce != "" {
+		claims["nonce"] = auth.Nonce
+	}
+	if auth.Sid != "" {
+		claims["sid"] = oidcSessionID(auth.Sid)
+	}
+	if accessToken != "" {
+		claims["at_hash"] = util.ATHash(accessToken)
+	}
+	return key.Sign(jwt.MapClaims(claims))
+}
+
+// UserClaims return the standard claims of uid allowed by scope,
+// `org` and `badge` scopes release the same claims as OauthUserClaims.
+func UserClaims(uid, scope string) (map[string]interface{}, error) {
+	user, err := model.UserByField("uid", uid)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, result.UserNotExist
+	}
+	claims := map[string]interface{}{
+		"sub": uid,
+	}
+	var profile *model.Profile
+	if HasScope(scope, "profile") || HasScope(scope, "org") || HasScope(scope, "badge") {
+		if profile, err = GetProfileInfo(uid); err != nil && err != result.ProfileNotExist {
+			return nil, err
+		}
+	}
+	if HasScope(scope, "profile") {
+		claims["preferred_username"] = uid
+		if profile != nil {
+			if profile.Nickname != nil {
+				claims["name"] = *profile.Nickname
+				claims["nickname"] = *profile.Nickname
+			}
+			if profile.Avatar != nil && *profile.Avatar != "" {
+				claims["picture"] = *profile.Avatar
+			}
+		}
+	}
+	if HasScope(scope, "email") {
+		claims["email"] = *user.Email
+		// the email is verified by captcha when registering or changing it
+		claims["email_verified"] = true
+	}
+	if HasScope(scope, "org") && profile != nil {
+		dep, org, err := GetProfileOrg(profile.OrgId)
+		if err != nil {
+			return nil, err
+		}
+		claims["dep"] = dep
+		claims["org"] = org
+	}
+	if HasScope(scope, "badge") && profile != nil && profile.Badge != nil {
+		claims["badge"] = profile.Badge
+	}
+	return claims, nil
+}
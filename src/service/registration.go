@@ -0,0 +1,228 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	"github.com/NJUPT-SAST/sast-link-backend/model"
+	"github.com/NJUPT-SAST/sast-link-backend/model/result"
+	"github.com/NJUPT-SAST/sast-link-backend/util"
+)
+
+const (
+	// an initial access token can not live longer than this
+	initialAccessTokenMaxTTL = 90 * 24 * time.Hour
+	registrationTokenLength  = 32
+)
+
+// ErrInvalidRegistrationToken is returned for a bad initial or registration access token
+var ErrInvalidRegistrationToken = errors.New("invalid_token")
+
+// CreateInitialAccessToken issue a token to register maxUses clients owned by uid,
+// it is only returned here.
+func CreateInitialAccessToken(uid string, maxUses int, ttl time.Duration, note string) (string, *model.InitialAccessToken, error) {
+	if maxUses <= 0 || ttl <= 0 || ttl > initialAccessTokenMaxTTL {
+		return "", nil, result.RequestParamError
+	}
+	token, err := util.GenerateRandomString(registrationTokenLength)
+	if err != nil {
+		return "", nil, err
+	}
+	now := time.Now()
+	initial := &model.InitialAccessToken{
+		TokenHash: hashToken(token),
+		CreatedBy: uid,
+		Note:      note,
+		MaxUses:   maxUses,
+		ExpiresAt: now.Add(ttl),
+		CreatedAt: now,
+	}
+	if err := model.CreateInitialAccessToken(initial); err != nil {
+		serviceLogger.Errorln("CreateInitialAccessToken Err,ErrMsg:", err)
+		return "", nil, err
+	}
+	serviceLogger.Infof("Admin [%s] created initial access token [%d] for %d clients\n", uid, initial.ID, maxUses)
+	return token, initial, nil
+}
+
+// InitialAccessTokens list all initial access tokens
+func InitialAccessTokens() ([]model.InitialAccessToken, error) {
+	return model.InitialAccessTokens()
+}
+
+// ExpireInitialAccessToken stop an initial access token before it expires,
+// the clients registered with it are kept.
+func ExpireInitialAccessToken(id uint) error {
+	expired, err := model.ExpireInitialAccessToken(id)
+	if err != nil {
+		return err
+	}
+	if !expired {
+		return result.RequestParamError
+	}
+	return nil
+}
+
+// RegisterClient register a client by RFC 7591 with an initial access token,
+// it is owned by the creator of the token and waits for admin approval.
+// The registration access token to manage the client is only returned here.
+func RegisterClient(initialToken string, metadata *ClientMetadata) (*model.OAuthClient, string, error) {
+	initial, err := model.InitialAccessTokenByHash(hashToken(initialToken))
+	if err != nil {
+		serviceLogger.Errorln("InitialAccessTokenByHash Err,ErrMsg:", err)
+		return nil, "", err
+	}
+	if initial == nil {
+		return nil, "", ErrInvalidRegistrationToken
+	}
+	client, err := newClient(initial.CreatedBy, metadata)
+	if err != nil {
+		return nil, "", err
+	}
+	registrationToken, err := util.GenerateRandomString(registrationTokenLength)
+	if err != nil {
+		return nil, "", err
+	}
+	client.Status = model.CLIENT_PENDING
+	client.InitialAccessTokenID = initial.ID
+	client.RegistrationTokenHash = hashToken(registrationToken)
+	// the token is only used up by valid registrations which are saved
+	created, err := model.CreateRegisteredOAuthClient(client)
+	if err != nil {
+		serviceLogger.Errorln("CreateRegisteredOAuthClient Err,ErrMsg:", err)
+		return nil, "", err
+	}
+	if !created {
+		return nil, "", ErrInvalidRegistrationToken
+	}
+	return client, registrationToken, nil
+}
+
+// RegisteredClient return the client managed by registrationToken, RFC 7592
+func RegisteredClient(ctx context.Context, clientID, registrationToken string) (*model.OAuthClient, error) {
+	client, err := model.OAuthClientByID(ctx, clientID)
+	if err != nil {
+		serviceLogger.Errorln("OAuthClientByID Err,ErrMsg:", err)
+		return nil, err
+	}
+	// the token of a deleted client and a wrong token look the same
+	if client == nil || client.RegistrationTokenHash == "" ||
+		subtle.ConstantTimeCompare([]byte(hashToken(registrationToken)), []byte(client.RegistrationTokenHash)) != 1 {
+		return nil, ErrInvalidRegistrationToken
+	}
+	return client, nil
+}
+
+// UpdateRegisteredClient replace the metadata of a registered client,
+// changing where and how it gets tokens needs approval again.
+func UpdateRegisteredClient(ctx context.Context, clientID, registrationToken string, metadata *ClientMetadata) (*model.OAuthClient, error) {
+	client, err := RegisteredClient(ctx, clientID, registrationToken)
+	if err != nil {
+		return nil, err
+	}
+	metadata.Public = client.Public
+	// RFC 7591 metadata can not express these, they are kept as the owner set
+	metadata.Description = client.Description
+	metadata.RequirePKCE = client.RequirePKCE
+	if err := checkClientMetadata(metadata); err != nil {
+		return nil, err
+	}
+	before := *client
+	applyClientMetadata(client, metadata)
+	if !sameStrings(before.RedirectURIs, client.RedirectURIs) ||
+		!sameStrings(before.GrantTypes, client.GrantTypes) ||
+		!sameStrings(before.Scopes, client.Scopes) ||
+		!sameStrings(before.PostLogoutRedirectURIs, client.PostLogoutRedirectURIs) ||
+		before.LoopbackRedirect != client.LoopbackRedirect ||
+		before.BackchannelLogoutURI != client.BackchannelLogoutURI {
+		client.Status = model.CLIENT_PENDING
+	}
+	now := time.Now()
+	client.UpdatedAt = &now
+	if err := model.UpdateOAuthClient(client); err != nil {
+		serviceLogger.Errorln("UpdateOAuthClient Err,ErrMsg:", err)
+		return nil, err
+	}
+	return client, nil
+}
+
+// DeleteRegisteredClient delete a registered client, tokens issued to it are revoked
+func DeleteRegisteredClient(ctx context.Context, clientID, registrationToken string) error {
+	if _, err := RegisteredClient(ctx, clientID, registrationToken); err != nil {
+		return err
+	}
+	if err := model.DeleteOAuthClient(clientID); err != nil {
+		serviceLogger.Errorln("DeleteOAuthClient Err,ErrMsg:", err)
+		return err
+	}
+	return nil
+}
+
+// RegisteredClients list registered clients in the review status
+func RegisteredClients(status string) ([]model.OAuthClient, error) {
+	switch status {
+	case model.CLIENT_PENDING, model.CLIENT_APPROVED, model.CLIENT_REJECTED:
+	default:
+		return nil, result.RequestParamError
+	}
+	return model.OAuthClientsByStatus(status)
+}
+
+// ReviewClient approve or reject a registered client by admin uid,
+// the tokens of a rejected client are revoked.
+func ReviewClient(ctx context.Context, uid, clientID string, approved bool) (*model.OAuthClient, error) {
+	client, err := model.OAuthClientByID(ctx, clientID)
+	if err != nil {
+		serviceLogger.Errorln("OAuthClientByID Err,ErrMsg:", err)
+		return nil, err
+	}
+	// clients created by members are not reviewed
+	if client == nil || client.Status == "" {
+		return nil, result.ClientNotExist
+	}
+	client.Status = model.CLIENT_REJECTED
+	if approved {
+		client.Status = model.CLIENT_APPROVED
+	}
+	now := time.Now()
+	client.UpdatedAt = &now
+	if err := model.UpdateOAuthClient(client); err != nil {
+		serviceLogger.Errorln("UpdateOAuthClient Err,ErrMsg:", err)
+		return nil, err
+	}
+	if !approved {
+		if err := model.RevokeClientTokens(clientID); err != nil {
+			serviceLogger.Errorln("RevokeClientTokens Err,ErrMsg:", err)
+			return nil, err
+		}
+	}
+	serviceLogger.Infof("Admin [%s] reviewed client [%s] as %s\n", uid, clientID, client.Status)
+	return client, nil
+}
+
+// ClientApproved report whether client can get tokens,
+// registered clients need admin approval.
+func ClientApproved(client *model.OAuthClient) bool {
+	return client.Status == "" || client.Status == model.CLIENT_APPROVED
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func sameStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
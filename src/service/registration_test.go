@@ -0,0 +1,57 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"github.com/NJUPT-SAST/sast-link-backend/model"
+	"github.com/NJUPT-SAST/sast-link-backend/model/result"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUpdateRegisteredClient(t *testing.T) {
+	ctx := context.Background()
+	uri := "https://example.com/callback"
+	token := "test-registration-token"
+	client := createTestClient(t, &model.OAuthClient{
+		Secret:                "secret",
+		Domain:                uri,
+		Name:                  "test",
+		Description:           "set by the owner",
+		RedirectURIs:          []string{uri},
+		GrantTypes:            defaultClientGrantTypes,
+		RequirePKCE:           true,
+		AccessTokenTTL:        600,
+		RefreshTokenTTL:       3600,
+		RateLimit:             60,
+		Status:                model.CLIENT_APPROVED,
+		RegistrationTokenHash: hashToken(token),
+	})
+
+	_, err := UpdateRegisteredClient(ctx, client.ID, "wrong-token", &ClientMetadata{RedirectURIs: []string{uri}})
+	assert.Equal(t, ErrInvalidRegistrationToken, err)
+
+	// metadata RFC 7591 can not express is kept, the same redirect URIs need no review
+	_, err = UpdateRegisteredClient(ctx, client.ID, token, &ClientMetadata{Name: "renamed", RedirectURIs: []string{uri}})
+	require.NoError(t, err)
+	updated, err := model.OAuthClientByID(ctx, client.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "renamed", updated.Name)
+	assert.Equal(t, client.Description, updated.Description)
+	assert.True(t, updated.RequirePKCE)
+	assert.Equal(t, client.AccessTokenTTL, updated.AccessTokenTTL)
+	assert.Equal(t, client.RefreshTokenTTL, updated.RefreshTokenTTL)
+	assert.Equal(t, client.RateLimit, updated.RateLimit)
+	assert.Equal(t, client.Secret, updated.Secret)
+	assert.Equal(t, model.CLIENT_APPROVED, updated.Status)
+
+	// redirecting elsewhere needs approval again
+	updated, err = UpdateRegisteredClient(ctx, client.ID, token, &ClientMetadata{Name: "renamed", RedirectURIs: []string{"https://example.org/callback"}})
+	require.NoError(t, err)
+	assert.Equal(t, model.CLIENT_PENDING, updated.Status)
+	assert.Equal(t, "https://example.org/callback", updated.Domain)
+
+	_, err = UpdateRegisteredClient(ctx, client.ID, token, &ClientMetadata{RedirectURIs: []string{"https://example.org/callback#fragment"}})
+	assert.Equal(t, result.InvalidRedirectURI, err)
+}
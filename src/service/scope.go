@@ -0,0 +1,145 @@
+package service
+
+import (
+	"context"
+	"strings"
+
+	"github.com/NJUPT-SAST/sast-link-backend/model"
+	"github.com/NJUPT-SAST/sast-link-backend/model/result"
+)
+
+// Scope is a permission OAuth clients can request,
+// Claims are the userinfo fields released by it.
+// Service scopes are only granted to service accounts by client_credentials.
+type Scope struct {
+	Name        string   `json:"name"`
+	Description string   `json:"description"`
+	Claims      []string `json:"claims"`
+	Service     bool     `json:"service,omitempty"`
+}
+
+// Scopes is the registry of scopes, requests with other scopes are rejected.
+// `userId` is always released as the subject of the token.
+var Scopes = []Scope{
+	{Name: "openid", Description: "使用 SAST Link 账号登录", Claims: []string{}},
+	{Name: "profile", Description: "昵称、头像和个人简介", Claims: []string{"nickname", "avatar", "bio", "link", "hide"}},
+	{Name: "email", Description: "邮箱地址", Claims: []string{"email"}},
+	{Name: "org", Description: "所在组织和部门", Claims: []string{"dep", "org"}},
+	{Name: "badge", Description: "获得的徽章", Claims: []string{"badge"}},
+	{Name: "directory", Description: "查询成员目录", Claims: []string{}, Service: true},
+}
+
+// ScopeByName return nil if the scope is not registered
+func ScopeByName(name string) *Scope {
+	for i := range Scopes {
+		if Scopes[i].Name == name {
+			return &Scopes[i]
+		}
+	}
+	return nil
+}
+
+// ScopeNames return the names of all registered scopes
+func ScopeNames() []string {
+	names := make([]string, 0, len(Scopes))
+	for _, scope := range Scopes {
+		names = append(names, scope.Name)
+	}
+	return names
+}
+
+// ValidScopes report whether every scope is registered for users to grant
+func ValidScopes(scopes []string) bool {
+	for _, s := range scopes {
+		if scope := ScopeByName(s); scope == nil || scope.Service {
+			return false
+		}
+	}
+	return true
+}
+
+// ClientScopes return the scopes client is allowed to request,
+// clients created before the registry are allowed all of them except service scopes.
+func ClientScopes(client *model.OAuthClient) []string {
+	if len(client.Scopes) == 0 {
+		return userScopeNames()
+	}
+	return client.Scopes
+}
+
+func userScopeNames() []string {
+	var names []string
+	for _, scope := range Scopes {
+		if !scope.Service {
+			names = append(names, scope.Name)
+		}
+	}
+	return names
+}
+
+// ScopeAllowed report whether the space separated scope only contains
+// registered scopes in allowed.
+func ScopeAllowed(scope string, allowed []string) bool {
+	for _, s := range strings.Fields(scope) {
+		if ScopeByName(s) == nil || !hasString(allowed, s) {
+			return false
+		}
+	}
+	return true
+}
+
+// ReleasedClaims filter claims to those released by the space separated scope
+func ReleasedClaims(claims map[string]interface{}, scope string) map[string]interface{} {
+	released := map[string]interface{}{}
+	for _, s := range strings.Fields(scope) {
+		registered := ScopeByName(s)
+		if registered == nil {
+			continue
+		}
+		for _, claim := range registered.Claims {
+			if v, ok := claims[claim]; ok {
+				released[claim] = v
+			}
+		}
+	}
+	return released
+}
+
+// GrantedScope validate the scope requested by clientID and remove duplicates,
+// a request without scope is granted all scopes the client is allowed.
+func GrantedScope(ctx context.Context, clientID, scope string) (string, error) {
+	client, err := model.OAuthClientByID(ctx, clientID)
+	if err != nil {
+		return "", err
+	}
+	if client == nil || !ClientApproved(client) {
+		return "", result.ClientErr
+	}
+	allowed := ClientScopes(client)
+	if strings.TrimSpace(scope) == "" {
+		return strings.Join(allowed, " "), nil
+	}
+	if !ScopeAllowed(scope, allowed) {
+		return "", result.InvalidScope
+	}
+	return strings.Join(mergeScopes(nil, scope), " "), nil
+}
+
+// mergeScopes add the space separated scope to scopes without duplicates
+func mergeScopes(scopes []string, scope string) []string {
+	for _, s := range strings.Fields(scope) {
+		if !hasString(scopes, s) {
+			scopes = append(scopes, s)
+		}
+	}
+	return scopes
+}
+
+func hasString(values []string, v string) bool {
+	for _, value := range values {
+		if value == v {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,152 @@
+package service
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/NJUPT-SAST/sast-link-backend/model"
+	"github.com/NJUPT-SAST/sast-link-backend/model/result"
+)
+
+// ClientCredentialsGrantType is only allowed for clients with an active service account
+const ClientCredentialsGrantType = "client_credentials"
+
+// directoryMaxUsers limit how many users are looked up by one request
+const directoryMaxUsers = 100
+
+// CreateServiceAccount let clientID use the client_credentials grant for scopes,
+// owner is the member responsible for the client.
+func CreateServiceAccount(ctx context.Context, uid, clientID, owner, purpose string, scopes []string) (*model.ServiceAccount, error) {
+	if purpose == "" || len(scopes) == 0 {
+		return nil, result.RequestParamError
+	}
+	for _, s := range scopes {
+		// openid authenticates a user, service accounts have none
+		if ScopeByName(s) == nil || s == "openid" {
+			return nil, result.InvalidScope
+		}
+	}
+	client, err := model.OAuthClientByID(ctx, clientID)
+	if err != nil {
+		serviceLogger.Errorln("OAuthClientByID Err,ErrMsg:", err)
+		return nil, err
+	}
+	// public clients can not keep the secret of client credentials
+	if client == nil || client.Public {
+		return nil, result.ClientNotExist
+	}
+	user, err := model.UserByField("uid", owner)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, result.UserNotExist
+	}
+	existing, err := model.ServiceAccountByClient(clientID)
+	if err != nil {
+		serviceLogger.Errorln("ServiceAccountByClient Err,ErrMsg:", err)
+		return nil, err
+	}
+	if existing != nil {
+		return nil, result.ServiceAccountExist
+	}
+	account := &model.ServiceAccount{
+		ClientID:  clientID,
+		Owner:     owner,
+		Purpose:   purpose,
+		Scope:     strings.Join(mergeScopes(nil, strings.Join(scopes, " ")), " "),
+		CreatedBy: uid,
+		CreatedAt: time.Now(),
+	}
+	if err := model.CreateServiceAccount(account); err != nil {
+		serviceLogger.Errorln("CreateServiceAccount Err,ErrMsg:", err)
+		return nil, err
+	}
+	serviceLogger.Infof("Admin [%s] created service account [%d] for client [%s]\n", uid, account.ID, clientID)
+	return account, nil
+}
+
+// ServiceAccounts list all service accounts
+func ServiceAccounts() ([]model.ServiceAccount, error) {
+	return model.ServiceAccounts()
+}
+
+// DisableServiceAccount stop a service account and revoke its tokens
+func DisableServiceAccount(id uint) error {
+	disabled, err := model.DisableServiceAccount(id)
+	if err != nil {
+		serviceLogger.Errorln("DisableServiceAccount Err,ErrMsg:", err)
+		return err
+	}
+	if !disabled {
+		return result.RequestParamError
+	}
+	return nil
+}
+
+// activeServiceAccount return nil if clientID has no service account or it is disabled
+func activeServiceAccount(clientID string) (*model.ServiceAccount, error) {
+	account, err := model.ServiceAccountByClient(clientID)
+	if err != nil {
+		serviceLogger.Errorln("ServiceAccountByClient Err,ErrMsg:", err)
+		return nil, err
+	}
+	if account == nil || account.DisabledAt != nil {
+		return nil, nil
+	}
+	return account, nil
+}
+
+// GrantedServiceScope validate the scope requested by the service account of clientID,
+// a request without scope is granted all scopes of the service account.
+func GrantedServiceScope(clientID, scope string) (string, error) {
+	account, err := activeServiceAccount(clientID)
+	if err != nil {
+		return "", err
+	}
+	if account == nil {
+		return "", result.ClientErr
+	}
+	if strings.TrimSpace(scope) == "" {
+		return account.Scope, nil
+	}
+	if !ScopeAllowed(scope, strings.Fields(account.Scope)) {
+		return "", result.InvalidScope
+	}
+	return strings.Join(mergeScopes(nil, scope), " "), nil
+}
+
+// DirectoryUsers look up members by uid for the `directory` scope,
+// the claims are released by the other scopes of the token.
+// Unknown uids are left out.
+func DirectoryUsers(uids []string, scope string) ([]map[string]interface{}, error) {
+	if !HasScope(scope, "directory") {
+		return nil, result.InvalidScope
+	}
+	if len(uids) == 0 || len(uids) > directoryMaxUsers {
+		return nil, result.RequestParamError
+	}
+	users := make([]map[string]interface{}, 0, len(uids))
+	seen := map[string]bool{}
+	for _, uid := range uids {
+		if seen[uid] {
+			continue
+		}
+		seen[uid] = true
+		user, err := model.UserByField("uid", uid)
+		if err != nil {
+			return nil, err
+		}
+		if user == nil {
+			continue
+		}
+		claims, err := OauthUserClaims(uid, scope)
+		if err != nil {
+			serviceLogger.Errorln("OauthUserClaims Err,ErrMsg:", err)
+			return nil, err
+		}
+		users = append(users, claims)
+	}
+	return users, nil
+}
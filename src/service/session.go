@@ -0,0 +1,219 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/NJUPT-SAST/sast-link-backend/model"
+	"github.com/NJUPT-SAST/sast-link-backend/model/result"
+	"github.com/NJUPT-SAST/sast-link-backend/util"
+	"github.com/gin-gonic/gin"
+)
+
+// GenerateLoginToken create a session for the requesting device,
+// return the short-lived login token whose `jti` is the session id,
+// and the refresh token to renew it.
+func GenerateLoginToken(ctx *gin.Context, uid string) (token, refreshToken string, err error) {
+	// every way of login ends here, check the account policy once for all
+	user, err := model.UserByField("uid", uid)
+	if err != nil {
+		return "", "", err
+	}
+	if user == nil {
+		return "", "", result.UserNotExist
+	}
+	if err := CheckEmailPolicy(*user.Email); err != nil {
+		return "", "", err
+	}
+	sid, err := util.GenerateRandomString(32)
+	if err != nil {
+		return "", "", err
+	}
+	refreshToken, err = newRefreshToken(sid)
+	if err != nil {
+		return "", "", err
+	}
+	now := time.Now().Unix()
+	userAgent := ctx.Request.UserAgent()
+	session := &model.Session{
+		ID:          sid,
+		Uid:         uid,
+		Device:      util.DeviceFromUserAgent(userAgent),
+		UserAgent:   userAgent,
+		IP:          ctx.ClientIP(),
+		CreatedAt:   now,
+		LastSeen:    now,
+		RefreshHash: hashRefreshToken(refreshToken),
+	}
+	if err := model.CreateSession(ctx, session, model.REFRESH_TOKEN_EXP); err != nil {
+		return "", "", err
+	}
+	token, err = util.GenerateTokenWithID(model.LoginJWTSubKey(uid), sid, model.LOGIN_TOKEN_EXP)
+	if err != nil {
+		return "", "", err
+	}
+	return token, refreshToken, nil
+}
+
+// RefreshLoginToken exchange a refresh token for a new pair,
+// every refresh token can only be used once,
+// the whole session is revoked if a used one shows up again.
+func RefreshLoginToken(ctx *gin.Context, refreshToken string) (token, next string, err error) {
+	sid, _, ok := strings.Cut(refreshToken, ".")
+	if !ok || sid == "" {
+		return "", "", result.LoginRefreshError
+	}
+	session, err := model.SessionByID(ctx, sid)
+	if err != nil {
+		return "", "", err
+	}
+	if session == nil {
+		return "", "", result.LoginRefreshError
+	}
+
+	// the session slides forward, but never lives longer than SESSION_MAX_AGE
+	exp := model.REFRESH_TOKEN_EXP
+	if left := time.Until(time.Unix(session.CreatedAt, 0).Add(model.SESSION_MAX_AGE)); left < exp {
+		exp = left
+	}
+	if exp <= 0 {
+		if err := model.DeleteSessions(ctx, session.Uid, sid); err != nil {
+			serviceLogger.Errorln("DeleteSessions Err,ErrMsg:", err)
+		}
+		return "", "", result.LoginRefreshError
+	}
+
+	next, err = newRefreshToken(sid)
+	if err != nil {
+		return "", "", err
+	}
+	res, err := model.RotateRefreshToken(ctx, session, hashRefreshToken(refreshToken), hashRefreshToken(next), exp, model.REFRESH_TOKEN_EXP)
+	if err != nil {
+		return "", "", err
+	}
+	switch res {
+	case model.REFRESH_ROTATED:
+	case model.REFRESH_REUSED:
+		serviceLogger.Warnf("refresh token of session [%s] of user [%s] reused, revoke the session\n", sid, session.Uid)
+		// the session may be stolen, its clients log out too
+		if err := logoutSessions(ctx, session.Uid, sid); err != nil {
+			return "", "", err
+		}
+		return "", "", result.LoginRefreshError
+	default:
+		return "", "", result.LoginRefreshError
+	}
+
+	token, err = util.GenerateTokenWithID(model.LoginJWTSubKey(session.Uid), sid, model.LOGIN_TOKEN_EXP)
+	if err != nil {
+		return "", "", err
+	}
+	return token, next, nil
+}
+
+// CheckLoginToken return uid and session id of a valid login token
+func CheckLoginToken(ctx context.Context, token string) (uid, sid string, err error) {
+	if token == "" {
+		return "", "", result.TokenError
+	}
+	uid, err = util.IdentityFromToken(token, model.LOGIN_TOKEN_SUB)
+	if err != nil || uid == "" {
+		return "", "", result.TokenError
+	}
+	sid, err = util.TokenID(token)
+	if err != nil || sid == "" {
+		return "", "", result.TokenError
+	}
+
+	session, err := model.SessionByID(ctx, sid)
+	if err != nil {
+		serviceLogger.Errorln("SessionByID Err,ErrMsg:", err)
+		return "", "", err
+	}
+	if session == nil || session.Uid != uid {
+		return "", "", result.TokenError
+	}
+
+	now := time.Now()
+	if now.Sub(time.Unix(session.LastSeen, 0)) > model.SESSION_TOUCH_INTERVAL {
+		if err := model.TouchSession(ctx, sid, now); err != nil {
+			serviceLogger.Errorln("TouchSession Err,ErrMsg:", err)
+		}
+	}
+	return uid, sid, nil
+}
+
+// Sessions list the alive sessions of user, the latest used first
+func Sessions(ctx context.Context, uid string) ([]model.Session, error) {
+	sids, err := model.SessionIDsByUid(ctx, uid)
+	if err != nil {
+		return nil, err
+	}
+	sessions := make([]model.Session, 0, len(sids))
+	var expired []string
+	for _, sid := range sids {
+		session, err := model.SessionByID(ctx, sid)
+		if err != nil {
+			return nil, err
+		}
+		if session == nil {
+			expired = append(expired, sid)
+			continue
+		}
+		sessions = append(sessions, *session)
+	}
+	// the index is not cleaned when sessions expire
+	if err := model.DeleteSessions(ctx, uid, expired...); err != nil {
+		serviceLogger.Errorln("DeleteSessions Err,ErrMsg:", err)
+	}
+
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].LastSeen > sessions[j].LastSeen
+	})
+	return sessions, nil
+}
+
+// RevokeSession log out a session of user, clients logged in by it are notified
+func RevokeSession(ctx context.Context, uid, sid string) error {
+	session, err := model.SessionByID(ctx, sid)
+	if err != nil {
+		return err
+	}
+	if session == nil || session.Uid != uid {
+		return result.SessionNotExist
+	}
+	return logoutSessions(ctx, uid, sid)
+}
+
+// RevokeOtherSessions log out all sessions of user except the current one
+func RevokeOtherSessions(ctx context.Context, uid, currentSid string) error {
+	sids, err := model.SessionIDsByUid(ctx, uid)
+	if err != nil {
+		return err
+	}
+	others := make([]string, 0, len(sids))
+	for _, sid := range sids {
+		if sid != currentSid {
+			others = append(others, sid)
+		}
+	}
+	return logoutSessions(ctx, uid, others...)
+}
+
+// newRefreshToken generate an opaque refresh token `<sid>.<random>`
+func newRefreshToken(sid string) (string, error) {
+	secret, err := util.GenerateRandomString(43)
+	if err != nil {
+		return "", err
+	}
+	return sid + "." + secret, nil
+}
+
+func hashRefreshToken(refreshToken string) string {
+	sum := sha256.Sum256([]byte(refreshToken))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,190 @@
+package service
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+	"time"
+
+	"github.com/NJUPT-SAST/sast-link-backend/config"
+	"github.com/NJUPT-SAST/sast-link-backend/model"
+	"github.com/NJUPT-SAST/sast-link-backend/model/result"
+	"github.com/NJUPT-SAST/sast-link-backend/util"
+	"github.com/gin-gonic/gin"
+)
+
+const recoveryCodeCount = 10
+
+// EnrollTOTP create a pending authenticator for user,
+// it takes effect after ConfirmTOTP.
+func EnrollTOTP(uid string) (secret, uri string, err error) {
+	twoFactor, err := model.TwoFactorByUid(uid)
+	if err != nil {
+		return "", "", err
+	}
+	if twoFactor != nil && twoFactor.Enabled {
+		return "", "", result.TwoFactorEnabled
+	}
+
+	secret, err = util.GenerateTOTPSecret()
+	if err != nil {
+		return "", "", err
+	}
+	if err := model.UpsertPendingTwoFactor(uid, secret); err != nil {
+		serviceLogger.Errorln("UpsertPendingTwoFactor Err,ErrMsg:", err)
+		return "", "", err
+	}
+	issuer := config.Config.GetString("totp.issuer")
+	if issuer == "" {
+		issuer = "SAST-Link"
+	}
+	return secret, util.TOTPProvisioningURI(issuer, uid, secret), nil
+}
+
+// ConfirmTOTP enable the pending authenticator with a valid code,
+// return the recovery codes which are only shown once.
+func ConfirmTOTP(ctx *gin.Context, uid, code string) ([]string, error) {
+	twoFactor, err := model.TwoFactorByUid(uid)
+	if err != nil {
+		return nil, err
+	}
+	if twoFactor == nil {
+		return nil, result.TwoFactorNotEnable
+	}
+	if twoFactor.Enabled {
+		return nil, result.TwoFactorEnabled
+	}
+	account := attempt{twoFactorAccountLimit, uid}
+	if err := checkLockout(ctx, account); err != nil {
+		return nil, err
+	}
+	if err := checkTOTP(ctx, uid, twoFactor.Secret, code); err != nil {
+		if err == result.TwoFactorCodeError {
+			recordFailure(ctx, account)
+		}
+		return nil, err
+	}
+	resetFailures(ctx, account)
+
+	codes, err := util.GenerateRecoveryCodes(recoveryCodeCount)
+	if err != nil {
+		return nil, err
+	}
+	hashed := make([]string, len(codes))
+	for i, c := range codes {
+		hashed[i] = hashRecoveryCode(c)
+	}
+	if err := model.EnableTwoFactor(uid, hashed); err != nil {
+		serviceLogger.Errorln("EnableTwoFactor Err,ErrMsg:", err)
+		return nil, err
+	}
+	return codes, nil
+}
+
+// TwoFactorEnabled report whether user must pass the second factor when login
+func TwoFactorEnabled(uid string) (bool, error) {
+	twoFactor, err := model.TwoFactorByUid(uid)
+	if err != nil {
+		return false, err
+	}
+	return twoFactor != nil && twoFactor.Enabled, nil
+}
+
+// VerifyTwoFactor accept a TOTP code or an unused recovery code
+func VerifyTwoFactor(ctx *gin.Context, uid, code string) error {
+	twoFactor, err := model.TwoFactorByUid(uid)
+	if err != nil {
+		return err
+	}
+	if twoFactor == nil || !twoFactor.Enabled {
+		return result.TwoFactorNotEnable
+	}
+
+	account, ip := attempt{twoFactorAccountLimit, uid}, attempt{twoFactorIPLimit, ctx.ClientIP()}
+	if err := checkLockout(ctx, account, ip); err != nil {
+		return err
+	}
+	if err := verifySecondFactor(ctx, twoFactor, code); err != nil {
+		if err == result.TwoFactorCodeError {
+			recordFailure(ctx, account, ip)
+		}
+		return err
+	}
+	resetFailures(ctx, account)
+	return nil
+}
+
+func verifySecondFactor(ctx *gin.Context, twoFactor *model.TwoFactor, code string) error {
+	code = strings.TrimSpace(code)
+	if len(code) == util.TOTPDigits {
+		return checkTOTP(ctx, twoFactor.Uid, twoFactor.Secret, code)
+	}
+	used, err := model.UseRecoveryCode(twoFactor.Uid, hashRecoveryCode(code))
+	if err != nil {
+		serviceLogger.Errorln("UseRecoveryCode Err,ErrMsg:", err)
+		return err
+	}
+	if !used {
+		return result.TwoFactorCodeError
+	}
+	serviceLogger.Infof("User [%s] used a recovery code\n", twoFactor.Uid)
+	return nil
+}
+
+// DisableTOTP remove the authenticator after re-authenticating with password and code
+func DisableTOTP(ctx *gin.Context, uid, password, code string) error {
+	if _, _, err := checkPassword(ctx, ctx.ClientIP(), uid, password); err != nil {
+		return err
+	}
+	if err := VerifyTwoFactor(ctx, uid, code); err != nil {
+		return err
+	}
+	return model.DeleteTwoFactor(uid)
+}
+
+// GenerateTwoFactorTicket issue the ticket which proves the password of uid is verified
+func GenerateTwoFactorTicket(ctx *gin.Context, uid string) (string, error) {
+	ticket, err := util.GenerateTokenWithExp(ctx, model.TwoFactorTicketJWTSubKey(uid), model.TWO_FACTOR_TICKET_EXP)
+	if err != nil {
+		return "", err
+	}
+	if err := model.Rdb.Set(ctx, model.TwoFactorTicketKey(uid), ticket, model.TWO_FACTOR_TICKET_EXP).Err(); err != nil {
+		return "", err
+	}
+	return ticket, nil
+}
+
+// CheckTwoFactorTicket return uid of the ticket, the ticket can be used only once
+func CheckTwoFactorTicket(ctx *gin.Context, ticket string) (string, error) {
+	uid, err := util.IdentityFromToken(ticket, model.TWO_FACTOR_TICKET_SUB)
+	if err != nil || uid == "" {
+		return "", result.TicketNotCorrect
+	}
+	if !CheckToken(ctx, model.TwoFactorTicketKey(uid), ticket) {
+		return "", result.CheckTicketNotfound
+	}
+	return uid, nil
+}
+
+// checkTOTP validate code and reject codes of already used time steps
+func checkTOTP(ctx *gin.Context, uid, secret, code string) error {
+	step, ok := util.ValidateTOTP(secret, code, time.Now())
+	if !ok {
+		return result.TwoFactorCodeError
+	}
+	exp := time.Duration(2*util.TOTPSkew+1) * util.TOTPPeriod * time.Second
+	used, err := model.UseTOTPStep(ctx, uid, step, exp)
+	if err != nil {
+		serviceLogger.Errorln("UseTOTPStep Err,ErrMsg:", err)
+		return err
+	}
+	if !used {
+		return result.TwoFactorCodeError
+	}
+	return nil
+}
+
+func hashRecoveryCode(code string) string {
+	sum := sha256.Sum256([]byte(strings.ToUpper(strings.TrimSpace(code))))
+	return hex.EncodeToString(sum[:])
+}
@@ -1,6 +1,8 @@
 package service
 
 import (
+	"crypto/rand"
+	"math/big"
 	"regexp"
 	"strings"
 
@@ -22,28 +24,70 @@ func CheckPasswordFormat(password string) bool {
 	return passReg.MatchString(password)
 }
 
-func CreateUserAndProfile(email string, password string) error {
-	// split email with @
-	split := regexp.MustCompile(`@`)
-	uid := split.Split(email, 2)[0]
-	uid = strings.ToLower(uid)
+// uids of invited users, whose addresses are outside the account policy
+const (
+	invitedUidPrefix  = "guest-"
+	invitedUidCharset = "abcdefghijkmnpqrstuvwxyz23456789"
+	invitedUidLength  = 8
+)
 
+// CreateUserAndProfile create user with the email of register ticket,
+// invitation is the code used to get the ticket, empty if not invited.
+func CreateUserAndProfile(email, password, invitation string) error {
 	if !CheckPasswordFormat(password) {
 		return result.PasswordIllegal
 	}
-	var err error
+	// the policy may have changed since the ticket, and the invitation
+	// is saved apart from it
+	decision := util.AccountEligibility.Register(email)
+	switch decision {
+	case util.PolicyAllowed:
+	case util.PolicyInviteRequired:
+		if invitation == "" {
+			return result.InviteRequired
+		}
+	default:
+		return emailPolicyError(email)
+	}
+	// retry on the rare collision of random uids
+	var uid string
+	for i := 0; i < 3 && uid == ""; i++ {
+		candidate, err := registerUid(email, decision)
+		if err != nil {
+			return err
+		}
+		// uid never changes with email, someone else may still own
+		// the uid after moving away from this email address,
+		// and clients know purged users by their uid
+		taken, err := model.UidTaken(candidate)
+		if err != nil {
+			return err
+		}
+		if !taken {
+			uid = candidate
+		} else if decision == util.PolicyAllowed {
+			return result.UserIsExist
+		}
+	}
+	if uid == "" {
+		return result.InternalErr
+	}
 	//encrypt password
-	pwdEncrypt := util.ShaHashing(password)
+	pwdEncrypt, err := util.HashPassword(password)
+	if err != nil {
+		return err
+	}
 
+	nickname := strings.ToLower(strings.SplitN(email, "@", 2)[0])
 	err = model.CreateUserAndProfile(&model.User{
 		Email:    &email,
 		Password: &pwdEncrypt,
 		Uid:      &uid,
 	}, &model.Profile{
-		Nickname: &uid,
+		Nickname: &nickname,
 		Email:    &email,
 		OrgId:    -1,
-	})
+	}, invitation)
 
 	if err != nil {
 		return err
@@ -52,15 +96,36 @@ func CreateUserAndProfile(email string, password string) error {
 	}
 }
 
+// registerUid return the uid of a new user with email, the local part of
+// addresses the policy allows, like the student ID. Invited addresses can be
+// of any domain, their local part may be the uid of someone else,
+// so they get a random uid like `guest-k3m9x2ab` instead.
+func registerUid(email string, decision util.PolicyDecision) (string, error) {
+	if decision == util.PolicyAllowed {
+		return strings.ToLower(strings.SplitN(email, "@", 2)[0]), nil
+	}
+	var b strings.Builder
+	b.WriteString(invitedUidPrefix)
+	size := big.NewInt(int64(len(invitedUidCharset)))
+	for i := 0; i < invitedUidLength; i++ {
+		n, err := rand.Int(rand.Reader, size)
+		if err != nil {
+			return "", err
+		}
+		b.WriteByte(invitedUidCharset[n.Int64()])
+	}
+	return b.String(), nil
+}
+
 // In VerifyAccountRegister and VerifyAccountResetPWD, the username must be email
 // In VerifyAccountLogin, the username can be email or uid
-func VerifyAccount(ctx *gin.Context, username, flag string) (string, error) {
+func VerifyAccount(ctx *gin.Context, username, flag, invitation string) (string, error) {
 	// 0 is register
 	// 1 is login
 	// 2 is resetPassword
 	if flag == "0" {
 		log.Log.Debugf("[%s] enter register verify\n", username)
-		return VerifyAccountRegister(ctx, username)
+		return VerifyAccountRegister(ctx, username, invitation)
 	} else if flag == "1" {
 		log.Log.Debugf("[%s] enter login verify\n", username)
 		return VerifyAccountLogin(ctx, username)
@@ -75,9 +140,8 @@ func VerifyAccount(ctx *gin.Context, username, flag string) (string, error) {
 // This username is email
 func VerifyAccountResetPWD(ctx *gin.Context, username string) (string, error) {
 	// verify if the user email correct
-	matched, _ := regexp.MatchString("^[BPFQbpfq](1[7-9]|2[0-9])([0-3])\\d{5}@njupt.edu.cn$", username)
-	if !matched {
-		return "", result.UserEmailError
+	if err := CheckEmailPolicy(username); err != nil {
+		return "", err
 	}
 	// check if the user is exist
 	user, err := model.UserByField("email", username)
@@ -100,12 +164,23 @@ func VerifyAccountResetPWD(ctx *gin.Context, username string) (string, error) {
 }
 
 // This function is used to verify the user's email is exist or not when register
-// This username is email
-func VerifyAccountRegister(ctx *gin.Context, username string) (string, error) {
-	// verify if the user email correct
-	matched, _ := regexp.MatchString("^[BPFQbpfq](1[7-9]|2[0-9])([0-3])\\d{5}@njupt.edu.cn$", username)
-	if !matched {
-		return "", result.UserEmailError
+// This username is email, invitation is optional for addresses of the policy,
+// and required for others.
+func VerifyAccountRegister(ctx *gin.Context, username, invitation string) (string, error) {
+	// verify if the user email can register
+	switch util.AccountEligibility.Register(username) {
+	case util.PolicyAllowed:
+	case util.PolicyInviteRequired:
+		if invitation == "" {
+			return "", result.InviteRequired
+		}
+	default:
+		return "", emailPolicyError(username)
+	}
+	if invitation != "" {
+		if err := checkInvitation(invitation); err != nil {
+			return "", err
+		}
 	}
 	// check if the user is exist
 	user, err := model.UserByField("email", username)
@@ -122,9 +197,16 @@ func VerifyAccountRegister(ctx *gin.Context, username string) (string, error) {
 		if err != nil {
 			return "", err
 		}
-		// set token to redis
-		model.Rdb.Set(ctx, ticket, model.VERIFY_STATUS["VERIFY_ACCOUNT"], model.REGISTER_TICKET_EXP)
-		return ticket, err
+		// set token to redis, with the invitation it is got by
+		pipe := model.Rdb.TxPipeline()
+		pipe.Set(ctx, ticket, model.VERIFY_STATUS["VERIFY_ACCOUNT"], model.REGISTER_TICKET_EXP)
+		if invitation != "" {
+			pipe.Set(ctx, model.RegisterInvitationKey(ticket), invitation, model.REGISTER_TICKET_EXP)
+		}
+		if _, err := pipe.Exec(ctx); err != nil {
+			return "", err
+		}
+		return ticket, nil
 	}
 }
 
@@ -133,7 +215,7 @@ func VerifyAccountRegister(ctx *gin.Context, username string) (string, error) {
 func VerifyAccountLogin(ctx *gin.Context, username string) (string, error) {
 	var user *model.User
 	var err error
-	if strings.Contains(username, "@njupt.edu.cn") {
+	if strings.Contains(username, "@") {
 		user, err = model.UserByField("email", username)
 	} else {
 		user, err = model.UserByField("uid", username)
@@ -145,6 +227,9 @@ func VerifyAccountLogin(ctx *gin.Context, username string) (string, error) {
 	if user == nil {
 		return "", result.UserNotExist
 	}
+	if err := CheckEmailPolicy(*user.Email); err != nil {
+		return "", err
+	}
 
 	ticket, err := util.GenerateTokenWithExp(ctx, model.LoginTicketJWTSubKey(*user.Uid), model.LOGIN_TICKET_EXP)
 	if err != nil || ticket == "" {
@@ -154,15 +239,25 @@ func VerifyAccountLogin(ctx *gin.Context, username string) (string, error) {
 	return ticket, err
 }
 
-func Login(username string, password string) (string, error) {
+func Login(ctx *gin.Context, username string, password string) (string, error) {
 	// Check password
-	uid, err := model.CheckPassword(username, password)
-	return uid, err
+	uid, rehash, err := checkPassword(ctx, ctx.ClientIP(), username, password)
+	if err != nil {
+		return "", err
+	}
+	// Upgrade the stored hash while we know the plain password,
+	// failing here must not break the login
+	if rehash {
+		if err := model.ChangePassword(uid, password); err != nil {
+			serviceLogger.Errorf("rehash password of [%s] fail: %s\n", uid, err.Error())
+		}
+	}
+	return uid, nil
 }
 
 func ModifyPassword(ctx *gin.Context, username, oldPassword, newPassword string) error {
 	// Check password
-	uid, err := model.CheckPassword(username, oldPassword)
+	uid, _, err := checkPassword(ctx, ctx.ClientIP(), username, oldPassword)
 	if err != nil {
 		return err
 	}
@@ -177,17 +272,60 @@ func ModifyPassword(ctx *gin.Context, username, oldPassword, newPassword string)
 	return nil
 }
 
+// CheckEmailPolicy return error if email is malformed or in the deny list
+// of the account policy, registration is decided by AccountEligibility.Register.
+func CheckEmailPolicy(email string) error {
+	if !util.ValidEmail(strings.ToLower(email)) {
+		return result.UserEmailError
+	}
+	if util.AccountEligibility.Denied(email) {
+		return result.EmailNotAllowed
+	}
+	return nil
+}
+
+func emailPolicyError(email string) error {
+	if err := CheckEmailPolicy(email); err != nil {
+		return err
+	}
+	return result.EmailNotAllowed
+}
+
+// reauthenticate confirm the logged-in user before a sensitive operation,
+// with password, and a TOTP or recovery code if 2FA is enabled.
+func reauthenticate(ctx *gin.Context, uid, password, twoFactorCode string) error {
+	if _, _, err := checkPassword(ctx, ctx.ClientIP(), uid, password); err != nil {
+		return err
+	}
+	enabled, err := TwoFactorEnabled(uid)
+	if err != nil {
+		return err
+	}
+	if !enabled {
+		return nil
+	}
+	if twoFactorCode == "" {
+		return result.TwoFactorCodeError
+	}
+	return VerifyTwoFactor(ctx, uid, twoFactorCode)
+}
+
 func ResetPassword(username, newPassword string) error {
 	// Check password form
 	if !CheckPasswordFormat(newPassword) {
 		return result.PasswordIllegal
 	}
 
-	split := regexp.MustCompile(`@`)
-	uid := split.Split(username, 2)[0]
-	uid = strings.ToLower(uid)
+	// the uid is not derived from email once the email is changed
+	user, err := model.UserByField("email", username)
+	if err != nil {
+		return err
+	}
+	if user == nil {
+		return result.UserNotExist
+	}
 
-	cErr := model.ChangePassword(uid, newPassword)
+	cErr := model.ChangePassword(*user.Uid, newPassword)
 	if cErr != nil {
 		return cErr
 	}
@@ -197,23 +335,10 @@ func ResetPassword(username, newPassword string) error {
 func UserInfo(ctx *gin.Context) (*model.User, error) {
 	token := ctx.GetHeader("TOKEN")
 	nilUser := &model.User{}
-	uid, err := util.IdentityFromToken(token, model.LOGIN_TOKEN_SUB)
+	uid, _, err := CheckLoginToken(ctx, token)
 	if err != nil {
 		return nilUser, err
 	}
-
-	rToken, err := model.Rdb.Get(ctx, model.LoginTokenKey(uid)).Result()
-	if err != nil {
-		if err == redis.Nil {
-			return nilUser, result.TokenError
-		}
-		return nilUser, err
-	}
-
-	if rToken == "" || rToken != token {
-		return nilUser, result.TokenError
-	}
-
 	return model.UserInfo(uid)
 }
 
@@ -231,14 +356,9 @@ func SendEmail(ctx *gin.Context, username, ticket, title string) error {
 	if val != model.VERIFY_STATUS["VERIFY_ACCOUNT"] {
 		return result.TicketNotCorrect
 	}
-	code := model.GenerateVerifyCode()
-	model.Rdb.Set(ctx, model.VerifyCodeKey(username), code, model.VERIFY_CODE_EXP)
-	content := model.InsertCode(code)
-	emailErr := model.SendEmail(username, content, title)
-	if emailErr != nil {
-		return emailErr
+	if err := sendCaptcha(ctx, username, title); err != nil {
+		return err
 	}
-	serviceLogger.Infof("Send Email to [%s] with code [%s]\n", username, code)
 	// Update the status of the ticket
 	model.Rdb.Set(ctx, ticket, model.VERIFY_STATUS["SEND_EMAIL"], model.REGISTER_TICKET_EXP)
 	return nil
@@ -260,20 +380,55 @@ func CheckVerifyCode(ctx *gin.Context, ticket, code, flag string) error {
 		return uErr
 	}
 
-	rCode, cErr := model.Rdb.Get(ctx, model.VerifyCodeKey(username)).Result()
-	if cErr != nil {
-		if cErr == redis.Nil {
+	if err := checkCaptcha(ctx, username, code); err != nil {
+		return err
+	}
+
+	// Update the status of the ticket
+	model.Rdb.Set(ctx, ticket, model.VERIFY_STATUS["VERIFY_CAPTCHA"], model.REGISTER_TICKET_EXP)
+	return nil
+}
+
+// sendCaptcha email a new verification code to username
+func sendCaptcha(ctx *gin.Context, username, title string) error {
+	code := model.GenerateVerifyCode()
+	model.Rdb.Set(ctx, model.VerifyCodeKey(username), code, model.VERIFY_CODE_EXP)
+	resetFailures(ctx, attempt{captchaCodeLimit, username})
+	content := model.InsertCode(code)
+	if err := model.SendEmail(username, content, title); err != nil {
+		return err
+	}
+	serviceLogger.Infof("Send Email to [%s] with code [%s]\n", username, code)
+	return nil
+}
+
+// checkCaptcha compare code with the one sent to username,
+// the code is burned after captchaMaxFailures wrong guesses.
+func checkCaptcha(ctx *gin.Context, username, code string) error {
+	ip := attempt{captchaIPLimit, ctx.ClientIP()}
+	if err := checkLockout(ctx, ip); err != nil {
+		return err
+	}
+	rCode, err := model.Rdb.Get(ctx, model.VerifyCodeKey(username)).Result()
+	if err != nil {
+		if err == redis.Nil {
 			return result.CaptchaError
 		}
-		return cErr
+		return err
 	}
 
 	if code != rCode {
+		recordFailure(ctx, ip)
+		// burn the code, otherwise it can be guessed in its lifetime
+		codeAttempt := attempt{captchaCodeLimit, username}
+		failures, err := model.RecordFailedAttempt(ctx, codeAttempt.key(), captchaCodeLimit.Window)
+		if err == nil && failures >= captchaMaxFailures {
+			model.Rdb.Del(ctx, model.VerifyCodeKey(username))
+			return result.CaptchaExpired
+		}
 		return result.CaptchaError
 	}
-
-	// Update the status of the ticket
-	model.Rdb.Set(ctx, ticket, model.VERIFY_STATUS["VERIFY_CAPTCHA"], model.REGISTER_TICKET_EXP)
+	model.Rdb.Del(ctx, model.VerifyCodeKey(username))
 	return nil
 }
 
@@ -0,0 +1,36 @@
+package service
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/NJUPT-SAST/sast-link-backend/util"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRegisterUid(t *testing.T) {
+	policy := &util.AccountPolicy{
+		Domains:  []string{"njupt.edu.cn"},
+		Patterns: []*regexp.Regexp{regexp.MustCompile(`^[BPFQbpfq](1[7-9]|2[0-9])([0-3])\d{5}$`)},
+		Invite:   true,
+	}
+	student, invitee := "B23010101@njupt.edu.cn", "b23010101@gmail.com"
+	require.Equal(t, util.PolicyAllowed, policy.Register(student))
+	require.Equal(t, util.PolicyInviteRequired, policy.Register(invitee))
+
+	uid, err := registerUid(student, policy.Register(student))
+	require.NoError(t, err)
+	assert.Equal(t, "b23010101", uid)
+
+	// the invitee must not take the uid of the student
+	uid, err = registerUid(invitee, policy.Register(invitee))
+	require.NoError(t, err)
+	assert.NotEqual(t, "b23010101", uid)
+	assert.True(t, strings.HasPrefix(uid, invitedUidPrefix))
+	assert.Len(t, uid, len(invitedUidPrefix)+invitedUidLength)
+	other, err := registerUid("b23010101@outlook.com", util.PolicyInviteRequired)
+	require.NoError(t, err)
+	assert.NotEqual(t, uid, other)
+}
@@ -0,0 +1,311 @@
+package service
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"time"
+
+	"github.com/NJUPT-SAST/sast-link-backend/config"
+	"github.com/NJUPT-SAST/sast-link-backend/model"
+	"github.com/NJUPT-SAST/sast-link-backend/model/result"
+	"github.com/NJUPT-SAST/sast-link-backend/util"
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	webAuthnCreate = "webauthn.create"
+	webAuthnGet    = "webauthn.get"
+)
+
+// webAuthnCeremony is saved in redis with the challenge,
+// Uid is empty when login with a discoverable credential.
+type webAuthnCeremony struct {
+	Type string `json:"type"`
+	Uid  string `json:"uid"`
+}
+
+var webAuthnAlgorithms = []int64{util.COSEAlgES256, util.COSEAlgEdDSA, util.COSEAlgRS256}
+
+// BeginWebAuthnRegistration return PublicKeyCredentialCreationOptions for navigator.credentials.create()
+func BeginWebAuthnRegistration(ctx *gin.Context, uid string) (gin.H, error) {
+	credentials, err := model.WebAuthnCredentialsByUid(uid)
+	if err != nil {
+		return nil, err
+	}
+	challenge, err := newWebAuthnChallenge(ctx, webAuthnCeremony{Type: webAuthnCreate, Uid: uid})
+	if err != nil {
+		return nil, err
+	}
+
+	params := make([]gin.H, 0, len(webAuthnAlgorithms))
+	for _, alg := range webAuthnAlgorithms {
+		params = append(params, gin.H{"type": "public-key", "alg": alg})
+	}
+	rpID, rpName := webAuthnRP()
+	return gin.H{
+		"challenge": challenge,
+		"rp":        gin.H{"id": rpID, "name": rpName},
+		"user": gin.H{
+			"id":          base64.RawURLEncoding.EncodeToString([]byte(uid)),
+			"name":        uid,
+			"displayName": uid,
+		},
+		"pubKeyCredParams":   params,
+		"timeout":            model.WEBAUTHN_CHALLENGE_EXP.Milliseconds(),
+		"attestation":        "none",
+		"excludeCredentials": credentialDescriptors(credentials),
+		"authenticatorSelection": gin.H{
+			"residentKey":      "preferred",
+			"userVerification": "preferred",
+		},
+	}, nil
+}
+
+// FinishWebAuthnRegistration verify the attestation response and save the credential
+func FinishWebAuthnRegistration(ctx *gin.Context, uid, name string, clientDataJSON, attestationObject []byte, transports []string) error {
+	if _, err := checkWebAuthnClientData(ctx, clientDataJSON, webAuthnCreate, uid); err != nil {
+		return err
+	}
+	_, rawAuthData, err := util.ParseAttestationObject(attestationObject)
+	if err != nil {
+		serviceLogger.Errorln("webauthn:", err)
+		return result.WebAuthnError
+	}
+	authData, err := util.ParseAuthenticatorData(rawAuthData)
+	if err != nil {
+		serviceLogger.Errorln("webauthn:", err)
+		return result.WebAuthnError
+	}
+	rpID, _ := webAuthnRP()
+	if !authData.RPIDHashMatch(rpID) ||
+		authData.Flags&util.WebAuthnFlagUserPresent == 0 ||
+		authData.Flags&util.WebAuthnFlagAttestedData == 0 {
+		return result.WebAuthnError
+	}
+	alg, err := util.COSEKeyAlgorithm(authData.PublicKey)
+	if err != nil || !supportedWebAuthnAlgorithm(alg) {
+		return result.WebAuthnError
+	}
+
+	credentialID := base64.RawURLEncoding.EncodeToString(authData.CredentialID)
+	exist, err := model.WebAuthnCredentialByID(credentialID)
+	if err != nil {
+		return err
+	}
+	if exist != nil {
+		return result.WebAuthnError
+	}
+	if name == "" {
+		name = "Passkey " + time.Now().Format("2006-01-02")
+	}
+	return model.CreateWebAuthnCredential(&model.WebAuthnCredential{
+		Uid:          uid,
+		CredentialID: credentialID,
+		PublicKey:    authData.PublicKey,
+		SignCount:    authData.SignCount,
+		AAGUID:       formatAAGUID(authData.AAGUID),
+		Transports:   transports,
+		Name:         name,
+		CreatedAt:    time.Now(),
+	})
+}
+
+// BeginWebAuthnLogin return PublicKeyCredentialRequestOptions for navigator.credentials.get(),
+// uid is optional, without it the authenticator choose a discoverable credential.
+func BeginWebAuthnLogin(ctx *gin.Context, uid string) (gin.H, error) {
+	var credentials []model.WebAuthnCredential
+	if uid != "" {
+		var err error
+		if credentials, err = model.WebAuthnCredentialsByUid(uid); err != nil {
+			return nil, err
+		}
+		if len(credentials) == 0 {
+			return nil, result.WebAuthnNotFound
+		}
+	}
+	challenge, err := newWebAuthnChallenge(ctx, webAuthnCeremony{Type: webAuthnGet, Uid: uid})
+	if err != nil {
+		return nil, err
+	}
+	rpID, _ := webAuthnRP()
+	return gin.H{
+		"challenge":        challenge,
+		"rpId":             rpID,
+		"timeout":          model.WEBAUTHN_CHALLENGE_EXP.Milliseconds(),
+		"allowCredentials": credentialDescriptors(credentials),
+		"userVerification": "preferred",
+	}, nil
+}
+
+// FinishWebAuthnLogin verify the assertion response,
+// return uid and whether the user is verified by the authenticator (PIN, biometrics).
+func FinishWebAuthnLogin(ctx *gin.Context, credentialID string, clientDataJSON, rawAuthData, signature, userHandle []byte) (string, bool, error) {
+	ceremony, err := checkWebAuthnClientData(ctx, clientDataJSON, webAuthnGet, "")
+	if err != nil {
+		return "", false, err
+	}
+	credential, err := model.WebAuthnCredentialByID(credentialID)
+	if err != nil {
+		return "", false, err
+	}
+	if credential == nil {
+		return "", false, result.WebAuthnNotFound
+	}
+	if ceremony.Uid != "" && ceremony.Uid != credential.Uid {
+		return "", false, result.WebAuthnError
+	}
+	if len(userHandle) != 0 && string(userHandle) != credential.Uid {
+		return "", false, result.WebAuthnError
+	}
+
+	authData, err := util.ParseAuthenticatorData(rawAuthData)
+	if err != nil {
+		serviceLogger.Errorln("webauthn:", err)
+		return "", false, result.WebAuthnError
+	}
+	rpID, _ := webAuthnRP()
+	if !authData.RPIDHashMatch(rpID) || authData.Flags&util.WebAuthnFlagUserPresent == 0 {
+		return "", false, result.WebAuthnError
+	}
+	clientDataHash := sha256.Sum256(clientDataJSON)
+	signed := append(append([]byte(nil), rawAuthData...), clientDataHash[:]...)
+	if err := util.VerifyCOSESignature(credential.PublicKey, signed, signature); err != nil {
+		serviceLogger.Errorln("webauthn:", err)
+		return "", false, result.WebAuthnError
+	}
+
+	// A counter not greater than the saved one means the authenticator may be cloned,
+	// authenticators without counter always return 0.
+	if (authData.SignCount != 0 || credential.SignCount != 0) && authData.SignCount <= credential.SignCount {
+		serviceLogger.Errorf("webauthn sign count of credential [%d] not increased: %d <= %d\n",
+			credential.ID, authData.SignCount, credential.SignCount)
+		return "", false, result.WebAuthnError
+	}
+	if err := model.UpdateWebAuthnSignCount(credential.ID, authData.SignCount); err != nil {
+		serviceLogger.Errorln("UpdateWebAuthnSignCount Err,ErrMsg:", err)
+		return "", false, err
+	}
+	return credential.Uid, authData.Flags&util.WebAuthnFlagUserVerified != 0, nil
+}
+
+func WebAuthnCredentials(uid string) ([]model.WebAuthnCredential, error) {
+	return model.WebAuthnCredentialsByUid(uid)
+}
+
+func RenameWebAuthnCredential(uid string, id uint, name string) error {
+	ok, err := model.RenameWebAuthnCredential(uid, id, name)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return result.WebAuthnNotFound
+	}
+	return nil
+}
+
+func DeleteWebAuthnCredential(uid string, id uint) error {
+	ok, err := model.DeleteWebAuthnCredential(uid, id)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return result.WebAuthnNotFound
+	}
+	return nil
+}
+
+// checkWebAuthnClientData check type, origin and consume the challenge of clientDataJSON
+func checkWebAuthnClientData(ctx *gin.Context, clientDataJSON []byte, ceremonyType, uid string) (*webAuthnCeremony, error) {
+	clientData, err := util.ParseClientData(clientDataJSON)
+	if err != nil {
+		serviceLogger.Errorln("webauthn:", err)
+		return nil, result.WebAuthnError
+	}
+	if clientData.Type != ceremonyType || !webAuthnOriginAllowed(clientData.Origin) {
+		return nil, result.WebAuthnError
+	}
+
+	// the challenge can only be used once
+	value, err := model.Rdb.GetDel(ctx, model.WebAuthnChallengeKey(clientData.Challenge)).Result()
+	if err == redis.Nil {
+		return nil, result.WebAuthnError
+	} else if err != nil {
+		return nil, err
+	}
+	var ceremony webAuthnCeremony
+	if err := json.Unmarshal([]byte(value), &ceremony); err != nil {
+		return nil, err
+	}
+	if ceremony.Type != ceremonyType || (uid != "" && ceremony.Uid != uid) {
+		return nil, result.WebAuthnError
+	}
+	return &ceremony, nil
+}
+
+func newWebAuthnChallenge(ctx *gin.Context, ceremony webAuthnCeremony) (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	challenge := base64.RawURLEncoding.EncodeToString(b)
+	value, err := json.Marshal(ceremony)
+	if err != nil {
+		return "", err
+	}
+	if err := model.Rdb.Set(ctx, model.WebAuthnChallengeKey(challenge), value, model.WEBAUTHN_CHALLENGE_EXP).Err(); err != nil {
+		return "", err
+	}
+	return challenge, nil
+}
+
+func webAuthnRP() (id, name string) {
+	id = config.Config.GetString("webauthn.rp_id")
+	name = config.Config.GetString("webauthn.rp_name")
+	if name == "" {
+		name = "SAST-Link"
+	}
+	return id, name
+}
+
+func webAuthnOriginAllowed(origin string) bool {
+	for _, allowed := range config.Config.GetStringSlice("webauthn.origins") {
+		if origin == allowed {
+			return true
+		}
+	}
+	return false
+}
+
+func supportedWebAuthnAlgorithm(alg int64) bool {
+	for _, supported := range webAuthnAlgorithms {
+		if alg == supported {
+			return true
+		}
+	}
+	return false
+}
+
+func credentialDescriptors(credentials []model.WebAuthnCredential) []gin.H {
+	descriptors := make([]gin.H, 0, len(credentials))
+	for _, credential := range credentials {
+		descriptor := gin.H{"type": "public-key", "id": credential.CredentialID}
+		if len(credential.Transports) != 0 {
+			descriptor["transports"] = credential.Transports
+		}
+		descriptors = append(descriptors, descriptor)
+	}
+	return descriptors
+}
+
+// formatAAGUID format the authenticator model id as UUID
+func formatAAGUID(aaguid []byte) string {
+	if len(aaguid) != 16 {
+		return ""
+	}
+	s := hex.EncodeToString(aaguid)
+	return s[:8] + "-" + s[8:12] + "-" + s[12:16] + "-" + s[16:20] + "-" + s[20:]
+}
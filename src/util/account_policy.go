@@ -0,0 +1,154 @@
+package util
+
+import (
+	"fmt"
+	"net/mail"
+	"regexp"
+	"strings"
+
+	"github.com/NJUPT-SAST/sast-link-backend/config"
+	"github.com/spf13/viper"
+)
+
+// the student mailbox of NJUPT, used when `account.policy` is not configured,
+// others can register with an invitation unless `invite = false`.
+const (
+	defaultPolicyDomain  = "njupt.edu.cn"
+	defaultPolicyPattern = `^[BPFQbpfq](1[7-9]|2[0-9])([0-3])\d{5}$`
+	defaultPolicyInvite  = true
+)
+
+// AccountEligibility decide who can register, reset password and log in,
+// configured by the `account.policy` section of the config file.
+var AccountEligibility = newAccountPolicy()
+
+// PolicyDecision is the result of AccountPolicy.Register
+type PolicyDecision int
+
+const (
+	// PolicyDenied the address can not be used
+	PolicyDenied PolicyDecision = iota
+	// PolicyInviteRequired the address can register with an invitation
+	PolicyInviteRequired
+	// PolicyAllowed the address can register by itself
+	PolicyAllowed
+)
+
+// AccountPolicy match email addresses against
+//
+//   - Deny, addresses never allowed, checked first
+//   - Allow, addresses always allowed to register
+//   - Domains and Patterns, the local part of an address in Domains
+//     must match one of Patterns, like the student ID of the school
+//
+// Entries of Allow and Deny are full addresses, or `@domain` for the whole domain.
+type AccountPolicy struct {
+	Domains  []string
+	Patterns []*regexp.Regexp
+	Allow    []string
+	Deny     []string
+	// addresses not allowed by the rules above can register with an invitation
+	Invite bool
+}
+
+func newAccountPolicy() *AccountPolicy {
+	return accountPolicyOf(config.Config.Sub("account.policy"))
+}
+
+// accountPolicyOf read the policy from the `account.policy` section conf, nil if missing
+func accountPolicyOf(conf *viper.Viper) *AccountPolicy {
+	if conf == nil {
+		return &AccountPolicy{
+			Domains:  []string{defaultPolicyDomain},
+			Patterns: []*regexp.Regexp{regexp.MustCompile(defaultPolicyPattern)},
+			Invite:   defaultPolicyInvite,
+		}
+	}
+	conf.SetDefault("invite", defaultPolicyInvite)
+	policy := &AccountPolicy{
+		Domains: lowerAll(conf.GetStringSlice("domains")),
+		Allow:   lowerAll(conf.GetStringSlice("allow")),
+		Deny:    lowerAll(conf.GetStringSlice("deny")),
+		Invite:  conf.GetBool("invite"),
+	}
+	for _, pattern := range conf.GetStringSlice("patterns") {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			panic(fmt.Sprintf("invalid account policy pattern [%s]: %s", pattern, err.Error()))
+		}
+		policy.Patterns = append(policy.Patterns, re)
+	}
+	return policy
+}
+
+// Register decide if email can be used to register
+func (p *AccountPolicy) Register(email string) PolicyDecision {
+	email = strings.ToLower(email)
+	local, domain, ok := splitEmail(email)
+	if !ok || p.Denied(email) {
+		return PolicyDenied
+	}
+	if matchEntries(p.Allow, email, domain) {
+		return PolicyAllowed
+	}
+	for _, d := range p.Domains {
+		if d != domain {
+			continue
+		}
+		if len(p.Patterns) == 0 {
+			return PolicyAllowed
+		}
+		for _, re := range p.Patterns {
+			if re.MatchString(local) {
+				return PolicyAllowed
+			}
+		}
+	}
+	if p.Invite {
+		return PolicyInviteRequired
+	}
+	return PolicyDenied
+}
+
+// Denied report whether email is in the deny list, such account
+// can not log in, reset password or be bound as a new email.
+func (p *AccountPolicy) Denied(email string) bool {
+	email = strings.ToLower(email)
+	_, domain, ok := splitEmail(email)
+	if !ok {
+		return true
+	}
+	return matchEntries(p.Deny, email, domain)
+}
+
+// ValidEmail report whether email is a bare address like `a@b.c`
+func ValidEmail(email string) bool {
+	_, _, ok := splitEmail(email)
+	return ok
+}
+
+// splitEmail return the local part and domain of a bare address like `a@b.c`
+func splitEmail(email string) (local, domain string, ok bool) {
+	addr, err := mail.ParseAddress(email)
+	if err != nil || addr.Address != email {
+		return "", "", false
+	}
+	i := strings.LastIndex(email, "@")
+	return email[:i], email[i+1:], true
+}
+
+func matchEntries(entries []string, email, domain string) bool {
+	for _, entry := range entries {
+		if entry == email || entry == "@"+domain {
+			return true
+		}
+	}
+	return false
+}
+
+func lowerAll(s []string) []string {
+	for i := range s {
+		s[i] = strings.ToLower(strings.TrimSpace(s[i]))
+	}
+	return s
+}
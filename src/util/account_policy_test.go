@@ -0,0 +1,50 @@
+package util
+
+import (
+	"regexp"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+	"github.com/spf13/viper"
+)
+
+func TestAccountPolicy(t *testing.T) {
+	Convey("Test account eligibility policy", t, func() {
+		policy := &AccountPolicy{
+			Domains:  []string{"njupt.edu.cn"},
+			Patterns: []*regexp.Regexp{regexp.MustCompile(defaultPolicyPattern)},
+			Allow:    []string{"teacher@njupt.edu.cn", "@sast.fun"},
+			Deny:     []string{"b21000000@njupt.edu.cn", "@spam.example"},
+		}
+		So(policy.Register("B21010101@njupt.edu.cn"), ShouldEqual, PolicyAllowed)
+		So(policy.Register("b16010101@njupt.edu.cn"), ShouldEqual, PolicyDenied)
+		So(policy.Register("teacher@njupt.edu.cn"), ShouldEqual, PolicyAllowed)
+		So(policy.Register("someone@sast.fun"), ShouldEqual, PolicyAllowed)
+		So(policy.Register("b21000000@njupt.edu.cn"), ShouldEqual, PolicyDenied)
+		So(policy.Register("alumni@example.com"), ShouldEqual, PolicyDenied)
+		So(policy.Register("not an email"), ShouldEqual, PolicyDenied)
+		So(policy.Register("Name <b21010101@njupt.edu.cn>"), ShouldEqual, PolicyDenied)
+
+		policy.Invite = true
+		So(policy.Register("alumni@example.com"), ShouldEqual, PolicyInviteRequired)
+		So(policy.Register("x@spam.example"), ShouldEqual, PolicyDenied)
+
+		So(policy.Denied("alumni@example.com"), ShouldBeFalse)
+		So(policy.Denied("X@SPAM.example"), ShouldBeTrue)
+	})
+}
+
+func TestAccountPolicyOf(t *testing.T) {
+	Convey("Test account policy defaults", t, func() {
+		// invitations are accepted like example.toml when not configured
+		policy := accountPolicyOf(nil)
+		So(policy.Register("B21010101@njupt.edu.cn"), ShouldEqual, PolicyAllowed)
+		So(policy.Register("alumni@example.com"), ShouldEqual, PolicyInviteRequired)
+
+		conf := viper.New()
+		conf.Set("domains", []string{"sast.fun"})
+		So(accountPolicyOf(conf).Invite, ShouldBeTrue)
+		conf.Set("invite", false)
+		So(accountPolicyOf(conf).Invite, ShouldBeFalse)
+	})
+}
@@ -0,0 +1,155 @@
+package util
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+)
+
+// A minimal CBOR (RFC 8949) decoder, only what WebAuthn needs:
+// attestation objects and COSE keys are small and use definite lengths.
+//
+// Decoded values are
+//
+//	unsigned/negative integer -> int64
+//	byte string -> []byte
+//	text string -> string
+//	array -> []interface{}
+//	map -> map[interface{}]interface{}
+//	false/true/null -> bool/nil
+//	float -> float64
+var ErrCBORMalformed = errors.New("malformed cbor")
+
+// cborMaxDepth limit nesting of arrays and maps
+const cborMaxDepth = 16
+
+// CBORDecode decode the first data item of b, return the remaining bytes
+func CBORDecode(b []byte) (value interface{}, rest []byte, err error) {
+	return cborDecode(b, 0)
+}
+
+func cborDecode(b []byte, depth int) (interface{}, []byte, error) {
+	if depth > cborMaxDepth {
+		return nil, nil, ErrCBORMalformed
+	}
+	if len(b) == 0 {
+		return nil, nil, ErrCBORMalformed
+	}
+	major, info := b[0]>>5, b[0]&0x1f
+	arg, b, err := cborArgument(info, b[1:])
+	if err != nil {
+		return nil, nil, err
+	}
+
+	switch major {
+	case 0:
+		if arg > math.MaxInt64 {
+			return nil, nil, ErrCBORMalformed
+		}
+		return int64(arg), b, nil
+	case 1:
+		if arg > math.MaxInt64 {
+			return nil, nil, ErrCBORMalformed
+		}
+		return -1 - int64(arg), b, nil
+	case 2, 3:
+		if arg > uint64(len(b)) {
+			return nil, nil, ErrCBORMalformed
+		}
+		data := b[:arg]
+		if major == 3 {
+			return string(data), b[arg:], nil
+		}
+		return append([]byte(nil), data...), b[arg:], nil
+	case 4:
+		if arg > uint64(len(b)) {
+			return nil, nil, ErrCBORMalformed
+		}
+		array := make([]interface{}, arg)
+		for i := range array {
+			if array[i], b, err = cborDecode(b, depth+1); err != nil {
+				return nil, nil, err
+			}
+		}
+		return array, b, nil
+	case 5:
+		if arg > uint64(len(b)) {
+			return nil, nil, ErrCBORMalformed
+		}
+		m := make(map[interface{}]interface{}, arg)
+		for i := uint64(0); i < arg; i++ {
+			var key, value interface{}
+			if key, b, err = cborDecode(b, depth+1); err != nil {
+				return nil, nil, err
+			}
+			switch key.(type) {
+			case int64, string:
+			default:
+				return nil, nil, ErrCBORMalformed
+			}
+			// duplicate keys make the map ambiguous, RFC 8949 section 5.6
+			if _, ok := m[key]; ok {
+				return nil, nil, ErrCBORMalformed
+			}
+			if value, b, err = cborDecode(b, depth+1); err != nil {
+				return nil, nil, err
+			}
+			m[key] = value
+		}
+		return m, b, nil
+	case 6:
+		// ignore tags, return the tagged item
+		return cborDecode(b, depth+1)
+	default:
+		switch info {
+		case 20:
+			return false, b, nil
+		case 21:
+			return true, b, nil
+		case 22, 23:
+			return nil, b, nil
+		case 25:
+			return float64(halfToFloat(uint16(arg))), b, nil
+		case 26:
+			return float64(math.Float32frombits(uint32(arg))), b, nil
+		case 27:
+			return math.Float64frombits(arg), b, nil
+		}
+		return nil, nil, ErrCBORMalformed
+	}
+}
+
+// cborArgument read the argument following the initial byte,
+// indefinite lengths are not supported.
+func cborArgument(info byte, b []byte) (uint64, []byte, error) {
+	switch {
+	case info < 24:
+		return uint64(info), b, nil
+	case info == 24 && len(b) >= 1:
+		return uint64(b[0]), b[1:], nil
+	case info == 25 && len(b) >= 2:
+		return uint64(binary.BigEndian.Uint16(b)), b[2:], nil
+	case info == 26 && len(b) >= 4:
+		return uint64(binary.BigEndian.Uint32(b)), b[4:], nil
+	case info == 27 && len(b) >= 8:
+		return binary.BigEndian.Uint64(b), b[8:], nil
+	}
+	return 0, nil, ErrCBORMalformed
+}
+
+func halfToFloat(h uint16) float32 {
+	sign := uint32(h>>15) << 31
+	exp := uint32(h>>10) & 0x1f
+	frac := uint32(h & 0x3ff)
+	switch exp {
+	case 0:
+		f := float32(frac) / (1 << 24)
+		if sign != 0 {
+			return -f
+		}
+		return f
+	case 0x1f:
+		return math.Float32frombits(sign | 0xff<<23 | frac<<13)
+	}
+	return math.Float32frombits(sign | (exp+112)<<23 | frac<<13)
+}
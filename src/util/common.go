@@ -190,6 +190,8 @@ func SendEmail(sender, secret, recipient, content, title string) error {
 }
 
 // ShaHashing use sha512 to hash input.
+//
+// Deprecated: only used to verify legacy password hashes, use HashPassword instead.
 func ShaHashing(in string) string {
 	sha512Hash := sha512.Sum512([]byte(in))
 	return hex.EncodeToString(sha512Hash[:])
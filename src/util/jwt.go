@@ -49,6 +49,22 @@ func GenerateTokenWithExp(ctx context.Context, identifier string, expireTime tim
 	return access, err
 }
 
+// GenerateTokenWithID generate token with expireTime and `jti` claim,
+// id points to the server side state of token, like the login session.
+func GenerateTokenWithID(identifier, id string, expireTime time.Duration) (string, error) {
+	claims := &JWTAccessClaims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(expireTime)),
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
+			Issuer:    "sast",
+			Audience:  jwt.ClaimStrings{identifier},
+			ID:        id,
+		},
+	}
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	return token.SignedString([]byte(jwtSigningKey))
+}
+
 func ParseToken(token string) (*JWTAccessClaims, error) {
 	tokenClaims, err := jwt.ParseWithClaims(token, &JWTAccessClaims{}, func(t *jwt.Token) (interface{}, error) {
 		if _, ok := t.Method.(*jwt.SigningMethodHMAC); !ok {
@@ -70,19 +86,6 @@ func ParseToken(token string) (*JWTAccessClaims, error) {
 	}
 }
 
-func RefreshToken(token string) (string, error) {
-	claims, err := ParseToken(token)
-	if err != nil {
-		return "", err
-	}
-
-	claims.ExpiresAt = jwt.NewNumericDate(time.Now().Add(time.Hour * 3))
-	tokenClaims := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	token, err = tokenClaims.SignedString(jwtSigningKey)
-
-	return token, err
-}
-
 // TokenAudience get `Audience` field(information about user/oauth...) from claims
 func TokenAudience(token string) (audience []string, err error) {
 	claims, err := ParseToken(token)
@@ -104,14 +107,30 @@ func IdentityFromToken(token, flag string) (string, error) {
 	if err != nil {
 		return "", err
 	}
-	identifiers := strings.Split(audience[0], "-")
-	identity, tokenType := identifiers[0], identifiers[1]
+	// split on the last "-", email addresses may contain "-"
+	i := strings.LastIndex(audience[0], "-")
+	if i < 0 {
+		return "", result.TicketNotCorrect
+	}
+	identity, tokenType := audience[0][:i], audience[0][i+1:]
 	if identity == "" || flag != tokenType {
 		return "", result.TicketNotCorrect
 	}
 	return strings.ToLower(identity), err
 }
 
+// TokenID get `jti` claim from token
+func TokenID(token string) (string, error) {
+	claims, err := ParseToken(token)
+	if err != nil {
+		return "", err
+	}
+	if err = claims.Valid(); err != nil {
+		return "", err
+	}
+	return claims.ID, nil
+}
+
 // GetUsername flag: verify token type
 func GetUsername(token, flag string) (string, error) {
 	claims, err := ParseToken(token)
@@ -23,3 +23,26 @@ func TestJWT(t *testing.T) {
 		So(username, ShouldEqual, "xunop@qq.com")
 	})
 }
+
+func TestTokenID(t *testing.T) {
+	Convey("Test JWT with jti", t, func() {
+		token, err := GenerateTokenWithID("xunop-loginToken", "session-id", time.Minute*3)
+		So(err, ShouldBeNil)
+		id, err := TokenID(token)
+		So(err, ShouldBeNil)
+		So(id, ShouldEqual, "session-id")
+		uid, err := IdentityFromToken(token, "loginToken")
+		So(err, ShouldBeNil)
+		So(uid, ShouldEqual, "xunop")
+	})
+}
+
+func TestIdentityWithHyphen(t *testing.T) {
+	Convey("Test identity containing '-'", t, func() {
+		token, err := GenerateTokenWithExp(nil, "john-doe@example.com-resetPwdTicket", time.Minute*3)
+		So(err, ShouldBeNil)
+		username, err := IdentityFromToken(token, "resetPwdTicket")
+		So(err, ShouldBeNil)
+		So(username, ShouldEqual, "john-doe@example.com")
+	})
+}
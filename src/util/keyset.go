@@ -0,0 +1,57 @@
+package util
+
+import (
+	"sort"
+	"time"
+)
+
+// RotatingKey is a signing key scheduled to sign new tokens from NotBefore
+type RotatingKey struct {
+	*SigningKey
+	NotBefore time.Time
+}
+
+// KeySet is the signing keys of a scheduled rotation, the latest key
+// whose NotBefore has passed signs new tokens. Keys are published before
+// they are used, and after they are replaced until the tokens they signed expire.
+type KeySet struct {
+	keys []RotatingKey
+	// maxTokenAge is the longest lifetime of the signed tokens
+	maxTokenAge time.Duration
+}
+
+// NewKeySet create a key set of keys in any order
+func NewKeySet(keys []RotatingKey, maxTokenAge time.Duration) *KeySet {
+	sorted := append([]RotatingKey(nil), keys...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].NotBefore.Before(sorted[j].NotBefore)
+	})
+	return &KeySet{keys: sorted, maxTokenAge: maxTokenAge}
+}
+
+// Active return the key signing new tokens at now,
+// nil if no key is in use yet.
+func (s *KeySet) Active(now time.Time) *SigningKey {
+	var active *SigningKey
+	for _, key := range s.keys {
+		if key.NotBefore.After(now) {
+			break
+		}
+		active = key.SigningKey
+	}
+	return active
+}
+
+// Published return the keys to verify tokens at now: the upcoming keys,
+// the active key and replaced keys whose tokens may not have expired.
+func (s *KeySet) Published(now time.Time) []*SigningKey {
+	var published []*SigningKey
+	for i, key := range s.keys {
+		// a key is replaced when the next key starts to sign
+		if i+1 < len(s.keys) && !s.keys[i+1].NotBefore.Add(s.maxTokenAge).After(now) {
+			continue
+		}
+		published = append(published, key.SigningKey)
+	}
+	return published
+}
@@ -0,0 +1,45 @@
+package util
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestKeySet(t *testing.T) {
+	Convey("Test scheduled rotation of signing keys", t, func() {
+		oldKey, err := GenerateSigningKey("RS256")
+		So(err, ShouldBeNil)
+		newKey, err := GenerateSigningKey("ES256")
+		So(err, ShouldBeNil)
+		rotateAt := time.Date(2024, 9, 1, 0, 0, 0, 0, time.UTC)
+		set := NewKeySet([]RotatingKey{
+			{SigningKey: newKey, NotBefore: rotateAt},
+			{SigningKey: oldKey},
+		}, 2*time.Hour)
+
+		// the new key is published before it is used
+		now := rotateAt.Add(-time.Hour)
+		So(set.Active(now), ShouldEqual, oldKey)
+		So(set.Published(now), ShouldResemble, []*SigningKey{oldKey, newKey})
+
+		// the old key is published until its tokens expire
+		now = rotateAt.Add(time.Hour)
+		So(set.Active(now), ShouldEqual, newKey)
+		So(set.Published(now), ShouldResemble, []*SigningKey{oldKey, newKey})
+
+		now = rotateAt.Add(2 * time.Hour)
+		So(set.Active(now), ShouldEqual, newKey)
+		So(set.Published(now), ShouldResemble, []*SigningKey{newKey})
+	})
+
+	Convey("Test key set without a key in use", t, func() {
+		key, err := GenerateSigningKey("RS256")
+		So(err, ShouldBeNil)
+		notBefore := time.Now().Add(time.Hour)
+		set := NewKeySet([]RotatingKey{{SigningKey: key, NotBefore: notBefore}}, time.Hour)
+		So(set.Active(time.Now()), ShouldBeNil)
+		So(set.Published(time.Now()), ShouldResemble, []*SigningKey{key})
+	})
+}
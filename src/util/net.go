@@ -3,7 +3,12 @@ package util
 import (
 	"bytes"
 	"encoding/json"
+	"fmt"
+	"net"
 	"net/http"
+	"net/url"
+	"strings"
+	"syscall"
 
 	"github.com/NJUPT-SAST/sast-link-backend/log"
 )
@@ -60,3 +65,37 @@ func GetWithHeader(url string, header map[string]string) (*http.Response, error)
 	log.LogRes(res)
 	return res, nil
 }
+
+// PublicIP report whether ip is reachable from the internet,
+// loopback, private and link-local addresses are inside the network.
+func PublicIP(ip net.IP) bool {
+	return !(ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() ||
+		ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsInterfaceLocalMulticast() || ip.IsMulticast())
+}
+
+// PublicHTTPSURL report whether the server may call uri on behalf of others:
+// https without fragment, and the host is not an IP literal inside the network.
+// Names resolving inside the network are left to the dialer.
+func PublicHTTPSURL(uri string) bool {
+	u, err := url.Parse(uri)
+	if err != nil || u.Scheme != "https" || u.Hostname() == "" || strings.Contains(uri, "#") {
+		return false
+	}
+	if ip := net.ParseIP(u.Hostname()); ip != nil {
+		return PublicIP(ip)
+	}
+	return !strings.EqualFold(u.Hostname(), "localhost")
+}
+
+// PublicDialControl is net.Dialer.Control refusing connections inside the network,
+// the resolved address is checked so that names can not point there.
+func PublicDialControl(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	if ip := net.ParseIP(host); ip == nil || !PublicIP(ip) {
+		return fmt.Errorf("connection to %s is not allowed", address)
+	}
+	return nil
+}
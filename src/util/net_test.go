@@ -0,0 +1,33 @@
+package util
+
+import (
+	"net"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestPublicHTTPSURL(t *testing.T) {
+	Convey("Test URLs the server may call", t, func() {
+		So(PublicHTTPSURL("https://app.example.org/logout"), ShouldBeTrue)
+		So(PublicHTTPSURL("https://203.0.113.7:8443/logout"), ShouldBeTrue)
+		So(PublicHTTPSURL("http://app.example.org/logout"), ShouldBeFalse)
+		So(PublicHTTPSURL("https://app.example.org/logout#frag"), ShouldBeFalse)
+		So(PublicHTTPSURL("https:///logout"), ShouldBeFalse)
+		So(PublicHTTPSURL("https://localhost/logout"), ShouldBeFalse)
+		So(PublicHTTPSURL("https://127.0.0.1/logout"), ShouldBeFalse)
+		So(PublicHTTPSURL("https://[::1]/logout"), ShouldBeFalse)
+		So(PublicHTTPSURL("https://10.0.0.8/logout"), ShouldBeFalse)
+		So(PublicHTTPSURL("https://192.168.1.1/logout"), ShouldBeFalse)
+		So(PublicHTTPSURL("https://169.254.169.254/latest/meta-data"), ShouldBeFalse)
+		So(PublicHTTPSURL("https://0.0.0.0/logout"), ShouldBeFalse)
+		So(PublicHTTPSURL("https://[fd00::1]/logout"), ShouldBeFalse)
+	})
+
+	Convey("Test dialing resolved addresses", t, func() {
+		So(PublicDialControl("tcp", "203.0.113.7:443", nil), ShouldBeNil)
+		So(PublicDialControl("tcp", "127.0.0.1:443", nil), ShouldNotBeNil)
+		So(PublicDialControl("tcp", "[::ffff:10.0.0.1]:443", nil), ShouldNotBeNil)
+		So(PublicIP(net.ParseIP("172.16.0.1")), ShouldBeFalse)
+	})
+}
@@ -0,0 +1,165 @@
+package util
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+var ErrUnsupportedSigningKey = errors.New("unsupported signing key, RSA or P-256 EC is required")
+
+// SigningKey sign ID tokens and JWT access tokens, its public part is published in JWKS
+type SigningKey struct {
+	// ID is the `kid`, the RFC 7638 thumbprint of the public key
+	ID     string
+	Alg    string
+	Signer crypto.Signer
+}
+
+// GenerateSigningKey generate a RS256 or ES256 key
+func GenerateSigningKey(alg string) (*SigningKey, error) {
+	var signer crypto.Signer
+	var err error
+	switch alg {
+	case "RS256":
+		signer, err = rsa.GenerateKey(rand.Reader, 2048)
+	case "ES256":
+		signer, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	default:
+		return nil, fmt.Errorf("unsupported signing algorithm [%s]", alg)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return newSigningKey(signer)
+}
+
+// ParseSigningKey parse a PEM encoded PKCS#8, PKCS#1 or SEC 1 private key,
+// the algorithm is RS256 for RSA keys and ES256 for P-256 keys.
+func ParseSigningKey(pemBytes []byte) (*SigningKey, error) {
+	block, _ := pem.Decode(pemBytes)
+	if block == nil {
+		return nil, errors.New("no PEM block found")
+	}
+	var key interface{}
+	var err error
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		key, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		key, err = x509.ParseECPrivateKey(block.Bytes)
+	default:
+		key, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	}
+	if err != nil {
+		return nil, err
+	}
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return nil, ErrUnsupportedSigningKey
+	}
+	return newSigningKey(signer)
+}
+
+func newSigningKey(signer crypto.Signer) (*SigningKey, error) {
+	k := &SigningKey{Signer: signer}
+	switch pub := signer.Public().(type) {
+	case *rsa.PublicKey:
+		k.Alg = "RS256"
+	case *ecdsa.PublicKey:
+		if pub.Curve != elliptic.P256() {
+			return nil, ErrUnsupportedSigningKey
+		}
+		k.Alg = "ES256"
+	default:
+		return nil, ErrUnsupportedSigningKey
+	}
+	thumbprint, err := jwkThumbprint(k.publicJWK())
+	if err != nil {
+		return nil, err
+	}
+	k.ID = thumbprint
+	return k, nil
+}
+
+// JWK return the public key in JWK format
+func (k *SigningKey) JWK() map[string]string {
+	jwk := k.publicJWK()
+	jwk["kid"] = k.ID
+	jwk["alg"] = k.Alg
+	jwk["use"] = "sig"
+	return jwk
+}
+
+// publicJWK return the required members of the public key
+func (k *SigningKey) publicJWK() map[string]string {
+	b64 := base64.RawURLEncoding.EncodeToString
+	switch pub := k.Signer.Public().(type) {
+	case *rsa.PublicKey:
+		return map[string]string{
+			"kty": "RSA",
+			"n":   b64(pub.N.Bytes()),
+			"e":   b64(big.NewInt(int64(pub.E)).Bytes()),
+		}
+	case *ecdsa.PublicKey:
+		return map[string]string{
+			"kty": "EC",
+			"crv": "P-256",
+			"x":   b64(pub.X.FillBytes(make([]byte, 32))),
+			"y":   b64(pub.Y.FillBytes(make([]byte, 32))),
+		}
+	}
+	return nil
+}
+
+// Sign sign claims as a JWT with `kid` in header
+func (k *SigningKey) Sign(claims jwt.Claims) (string, error) {
+	return k.SignWithType("JWT", claims)
+}
+
+// SignWithType sign claims with `typ` in header, like `at+jwt` of RFC 9068
+func (k *SigningKey) SignWithType(typ string, claims jwt.Claims) (string, error) {
+	token := jwt.NewWithClaims(jwt.GetSigningMethod(k.Alg), claims)
+	token.Header["kid"] = k.ID
+	token.Header["typ"] = typ
+	return token.SignedString(k.Signer)
+}
+
+// Verify parse a JWT signed by k into claims, only the signature is checked,
+// like `id_token_hint` which is accepted after it expires.
+func (k *SigningKey) Verify(token string, claims jwt.Claims) error {
+	_, err := jwt.ParseWithClaims(token, claims, func(t *jwt.Token) (interface{}, error) {
+		return k.Signer.Public(), nil
+	}, jwt.WithValidMethods([]string{k.Alg}), jwt.WithoutClaimsValidation())
+	return err
+}
+
+// jwkThumbprint compute RFC 7638 thumbprint, json.Marshal sorts the keys
+// and the required members have no characters to escape.
+func jwkThumbprint(jwk map[string]string) (string, error) {
+	b, err := json.Marshal(jwk)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(b)
+	return base64.RawURLEncoding.EncodeToString(sum[:]), nil
+}
+
+// ATHash compute `at_hash` claim of ID token for RS256 and ES256,
+// the left half of the SHA-256 of the access token.
+func ATHash(accessToken string) string {
+	sum := sha256.Sum256([]byte(accessToken))
+	return base64.RawURLEncoding.EncodeToString(sum[:len(sum)/2])
+}
@@ -0,0 +1,81 @@
+package util
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestSigningKey(t *testing.T) {
+	Convey("Test ID token signing keys", t, func() {
+		for _, alg := range []string{"RS256", "ES256"} {
+			key, err := GenerateSigningKey(alg)
+			So(err, ShouldBeNil)
+			So(key.Alg, ShouldEqual, alg)
+			So(key.ID, ShouldNotBeEmpty)
+			So(key.JWK()["kid"], ShouldEqual, key.ID)
+
+			token, err := key.Sign(jwt.RegisteredClaims{
+				Subject:   "b21010101",
+				ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Minute)),
+			})
+			So(err, ShouldBeNil)
+			parsed, err := jwt.Parse(token, func(t *jwt.Token) (interface{}, error) {
+				So(t.Header["kid"], ShouldEqual, key.ID)
+				return key.Signer.Public(), nil
+			}, jwt.WithValidMethods([]string{alg}))
+			So(err, ShouldBeNil)
+			sub, _ := parsed.Claims.GetSubject()
+			So(sub, ShouldEqual, "b21010101")
+
+			// the same key parsed from PEM has the same kid
+			der, err := x509.MarshalPKCS8PrivateKey(key.Signer)
+			So(err, ShouldBeNil)
+			parsedKey, err := ParseSigningKey(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}))
+			So(err, ShouldBeNil)
+			So(parsedKey.ID, ShouldEqual, key.ID)
+			So(parsedKey.Alg, ShouldEqual, alg)
+		}
+
+		_, err := GenerateSigningKey("HS256")
+		So(err, ShouldNotBeNil)
+	})
+
+	Convey("Test verifying tokens of the key", t, func() {
+		key, err := GenerateSigningKey("ES256")
+		So(err, ShouldBeNil)
+		other, err := GenerateSigningKey("ES256")
+		So(err, ShouldBeNil)
+		// expired tokens are verified, the caller checks the claims
+		token, err := key.Sign(jwt.RegisteredClaims{
+			Subject:   "b21010101",
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(-time.Hour)),
+		})
+		So(err, ShouldBeNil)
+		claims := jwt.RegisteredClaims{}
+		So(key.Verify(token, &claims), ShouldBeNil)
+		So(claims.Subject, ShouldEqual, "b21010101")
+		So(other.Verify(token, &jwt.RegisteredClaims{}), ShouldNotBeNil)
+		So(key.Verify(token+"x", &jwt.RegisteredClaims{}), ShouldNotBeNil)
+	})
+
+	Convey("Test JWK thumbprint of RFC 7638", t, func() {
+		// example of RFC 7638 section 3.1
+		thumbprint, err := jwkThumbprint(map[string]string{
+			"kty": "RSA",
+			"n":   "0vx7agoebGcQSuuPiLJXZptN9nndrQmbXEps2aiAFbWhM78LhWx4cbbfAAtVT86zwu1RK7aPFFxuhDR1L6tSoc_BJECPebWKRXjBZCiFV4n3oknjhMstn64tZ_2W-5JsGY4Hc5n9yBXArwl93lqt7_RN5w6Cf0h4QyQ5v-65YGjQR0_FDW2QvzqY368QQMicAtaSqzs8KJZgnYb9c7d0zgdAZHzu6qMQvRL5hajrn1n91CbOpbISD08qNLyrdkt-bFTWhAI4vMQFh6WeZu0fM4lFd2NcRwr3XPksINHaQ-G_xBniIqbw0Ls1jF44-csFCur-kEgU8awapJzKnqDKgw",
+			"e":   "AQAB",
+		})
+		So(err, ShouldBeNil)
+		So(thumbprint, ShouldEqual, "NzbLsXh8uDCcd-6MNwXF4W_7noWXFZAfHkxZsRGC9Xs")
+	})
+
+	Convey("Test at_hash", t, func() {
+		// example of OpenID Connect Core appendix A.3
+		So(ATHash("jHkWEdUXMU1BwAsC4vtUsZwnNvTIxEl0z9K3vx5KF0Y"), ShouldEqual, "77QmUPtjPfzWtF2AnpK9RQ")
+	})
+}
@@ -0,0 +1,241 @@
+package util
+
+import (
+	"crypto/rand"
+	"crypto/sha512"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/NJUPT-SAST/sast-link-backend/config"
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Password hashes are stored in PHC string format, e.g.
+//
+//	$argon2id$v=19$m=65536,t=3,p=2$<salt>$<hash>
+//	$2a$12$<salt and hash>
+//
+// so the algorithm and its parameters can be read back from the hash itself.
+// Hashes without a leading `$` are the legacy unsalted SHA-512 hex digests.
+var (
+	ErrUnknownPasswordHash = errors.New("unknown password hash format")
+
+	// PasswordHashing is the hasher used for new passwords,
+	// configured by the `password` section of the config file.
+	PasswordHashing = newPasswordHasher()
+)
+
+// PasswordHasher hash and verify passwords
+type PasswordHasher interface {
+	// ID is the PHC identifier of the algorithm, like "argon2id"
+	ID() string
+	// Hash return the encoded hash of password
+	Hash(password string) (string, error)
+	// Verify check password against the encoded hash
+	Verify(password, encoded string) (bool, error)
+	// NeedsRehash report whether encoded was produced with other parameters
+	NeedsRehash(encoded string) bool
+}
+
+// Argon2idHasher hash password with argon2id
+type Argon2idHasher struct {
+	Memory      uint32
+	Iterations  uint32
+	Parallelism uint8
+	SaltLength  uint32
+	KeyLength   uint32
+}
+
+// BcryptHasher hash password with bcrypt
+type BcryptHasher struct {
+	Cost int
+}
+
+// legacyHasher verify the old unsalted sha512 hashes, never used for hashing
+type legacyHasher struct{}
+
+func newPasswordHasher() PasswordHasher {
+	conf := config.Config
+	switch algorithm := conf.GetString("password.algorithm"); algorithm {
+	case "bcrypt":
+		cost := conf.GetInt("password.bcrypt_cost")
+		if cost == 0 {
+			cost = 12
+		}
+		return &BcryptHasher{Cost: cost}
+	case "", "argon2id":
+		hasher := &Argon2idHasher{
+			Memory:      conf.GetUint32("password.argon2_memory"),
+			Iterations:  conf.GetUint32("password.argon2_iterations"),
+			Parallelism: uint8(conf.GetUint("password.argon2_parallelism")),
+			SaltLength:  16,
+			KeyLength:   32,
+		}
+		if hasher.Memory == 0 {
+			hasher.Memory = 64 * 1024
+		}
+		if hasher.Iterations == 0 {
+			hasher.Iterations = 3
+		}
+		if hasher.Parallelism == 0 {
+			hasher.Parallelism = 2
+		}
+		return hasher
+	default:
+		panic(fmt.Sprintf("unsupported password algorithm [%s]", algorithm))
+	}
+}
+
+// HashPassword hash password with the configured hasher
+func HashPassword(password string) (string, error) {
+	return PasswordHashing.Hash(password)
+}
+
+// VerifyPassword check password against the encoded hash,
+// `rehash` is true when the hash should be upgraded to the configured hasher.
+func VerifyPassword(password, encoded string) (ok bool, rehash bool, err error) {
+	hasher, err := hasherOf(encoded)
+	if err != nil {
+		return false, false, err
+	}
+	ok, err = hasher.Verify(password, encoded)
+	if err != nil || !ok {
+		return false, false, err
+	}
+	rehash = hasher.ID() != PasswordHashing.ID() || PasswordHashing.NeedsRehash(encoded)
+	return true, rehash, nil
+}
+
+// hasherOf find the hasher which produced encoded
+func hasherOf(encoded string) (PasswordHasher, error) {
+	if !strings.HasPrefix(encoded, "$") {
+		if len(encoded) != sha512.Size*2 {
+			return nil, ErrUnknownPasswordHash
+		}
+		return legacyHasher{}, nil
+	}
+	id := strings.SplitN(encoded[1:], "$", 2)[0]
+	switch id {
+	case "argon2id":
+		if h, ok := PasswordHashing.(*Argon2idHasher); ok {
+			return h, nil
+		}
+		return &Argon2idHasher{}, nil
+	case "2a", "2b", "2y":
+		if h, ok := PasswordHashing.(*BcryptHasher); ok {
+			return h, nil
+		}
+		return &BcryptHasher{}, nil
+	}
+	return nil, ErrUnknownPasswordHash
+}
+
+func (h *Argon2idHasher) ID() string {
+	return "argon2id"
+}
+
+func (h *Argon2idHasher) Hash(password string) (string, error) {
+	salt := make([]byte, h.SaltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+	key := argon2.IDKey([]byte(password), salt, h.Iterations, h.Memory, h.Parallelism, h.KeyLength)
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2.Version, h.Memory, h.Iterations, h.Parallelism,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key),
+	), nil
+}
+
+func (h *Argon2idHasher) Verify(password, encoded string) (bool, error) {
+	params, salt, key, err := decodeArgon2id(encoded)
+	if err != nil {
+		return false, err
+	}
+	other := argon2.IDKey([]byte(password), salt, params.Iterations, params.Memory, params.Parallelism, uint32(len(key)))
+	return subtle.ConstantTimeCompare(key, other) == 1, nil
+}
+
+func (h *Argon2idHasher) NeedsRehash(encoded string) bool {
+	params, salt, key, err := decodeArgon2id(encoded)
+	if err != nil {
+		return true
+	}
+	return params.Memory != h.Memory ||
+		params.Iterations != h.Iterations ||
+		params.Parallelism != h.Parallelism ||
+		uint32(len(salt)) != h.SaltLength ||
+		uint32(len(key)) != h.KeyLength
+}
+
+// decodeArgon2id parse `$argon2id$v=19$m=65536,t=3,p=2$salt$hash`
+func decodeArgon2id(encoded string) (*Argon2idHasher, []byte, []byte, error) {
+	parts := strings.Split(encoded, "$")
+	if len(parts) != 6 || parts[1] != "argon2id" {
+		return nil, nil, nil, ErrUnknownPasswordHash
+	}
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil {
+		return nil, nil, nil, err
+	}
+	if version != argon2.Version {
+		return nil, nil, nil, fmt.Errorf("unsupported argon2 version [%d]", version)
+	}
+	params := &Argon2idHasher{}
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &params.Memory, &params.Iterations, &params.Parallelism); err != nil {
+		return nil, nil, nil, err
+	}
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	params.SaltLength = uint32(len(salt))
+	params.KeyLength = uint32(len(key))
+	return params, salt, key, nil
+}
+
+func (h *BcryptHasher) ID() string {
+	return "bcrypt"
+}
+
+func (h *BcryptHasher) Hash(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), h.Cost)
+	return string(hash), err
+}
+
+func (h *BcryptHasher) Verify(password, encoded string) (bool, error) {
+	err := bcrypt.CompareHashAndPassword([]byte(encoded), []byte(password))
+	if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (h *BcryptHasher) NeedsRehash(encoded string) bool {
+	cost, err := bcrypt.Cost([]byte(encoded))
+	return err != nil || cost != h.Cost
+}
+
+func (legacyHasher) ID() string {
+	return "sha512"
+}
+
+func (legacyHasher) Hash(string) (string, error) {
+	return "", errors.New("sha512 password hashing is deprecated")
+}
+
+func (legacyHasher) Verify(password, encoded string) (bool, error) {
+	return subtle.ConstantTimeCompare([]byte(ShaHashing(password)), []byte(encoded)) == 1, nil
+}
+
+func (legacyHasher) NeedsRehash(string) bool {
+	return true
+}
@@ -0,0 +1,49 @@
+package util
+
+import (
+	"strings"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestPasswordHashing(t *testing.T) {
+	Convey("Test argon2id password hashing", t, func() {
+		hash, err := HashPassword("sast-link")
+		So(err, ShouldBeNil)
+		So(strings.HasPrefix(hash, "$argon2id$v=19$"), ShouldBeTrue)
+		ok, rehash, err := VerifyPassword("sast-link", hash)
+		So(err, ShouldBeNil)
+		So(ok, ShouldBeTrue)
+		So(rehash, ShouldBeFalse)
+		ok, _, err = VerifyPassword("sast-lin", hash)
+		So(err, ShouldBeNil)
+		So(ok, ShouldBeFalse)
+	})
+
+	Convey("Test legacy sha512 hash need rehash", t, func() {
+		ok, rehash, err := VerifyPassword("sast-link", ShaHashing("sast-link"))
+		So(err, ShouldBeNil)
+		So(ok, ShouldBeTrue)
+		So(rehash, ShouldBeTrue)
+	})
+
+	Convey("Test bcrypt hash need rehash", t, func() {
+		hash, err := (&BcryptHasher{Cost: 4}).Hash("sast-link")
+		So(err, ShouldBeNil)
+		ok, rehash, err := VerifyPassword("sast-link", hash)
+		So(err, ShouldBeNil)
+		So(ok, ShouldBeTrue)
+		So(rehash, ShouldBeTrue)
+	})
+
+	Convey("Test weaker argon2id parameters need rehash", t, func() {
+		weak := &Argon2idHasher{Memory: 1024, Iterations: 1, Parallelism: 1, SaltLength: 16, KeyLength: 32}
+		hash, err := weak.Hash("sast-link")
+		So(err, ShouldBeNil)
+		ok, rehash, err := VerifyPassword("sast-link", hash)
+		So(err, ShouldBeNil)
+		So(ok, ShouldBeTrue)
+		So(rehash, ShouldBeTrue)
+	})
+}
@@ -0,0 +1,44 @@
+package util
+
+import (
+	"encoding/base32"
+	"strings"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestTOTP(t *testing.T) {
+	// RFC 6238 appendix B test secret
+	secret := base32.StdEncoding.EncodeToString([]byte("12345678901234567890"))
+
+	Convey("Test TOTP code with RFC 6238 test vectors", t, func() {
+		code, err := TOTPCode(secret, TOTPStep(time.Unix(59, 0)))
+		So(err, ShouldBeNil)
+		So(code, ShouldEqual, "287082")
+		code, err = TOTPCode(secret, TOTPStep(time.Unix(1111111109, 0)))
+		So(err, ShouldBeNil)
+		So(code, ShouldEqual, "081804")
+	})
+
+	Convey("Test TOTP validation with clock skew", t, func() {
+		now := time.Unix(1111111109, 0)
+		step, ok := ValidateTOTP(secret, "081804", now.Add(TOTPPeriod*time.Second))
+		So(ok, ShouldBeTrue)
+		So(step, ShouldEqual, TOTPStep(now))
+		_, ok = ValidateTOTP(secret, "081804", now.Add(3*TOTPPeriod*time.Second))
+		So(ok, ShouldBeFalse)
+	})
+
+	Convey("Test provisioning uri and recovery codes", t, func() {
+		newSecret, err := GenerateTOTPSecret()
+		So(err, ShouldBeNil)
+		uri := TOTPProvisioningURI("SAST-Link", "b22010101", newSecret)
+		So(strings.HasPrefix(uri, "otpauth://totp/SAST-Link:b22010101?"), ShouldBeTrue)
+		codes, err := GenerateRecoveryCodes(10)
+		So(err, ShouldBeNil)
+		So(len(codes), ShouldEqual, 10)
+		So(len(codes[0]), ShouldEqual, 9)
+	})
+}
@@ -0,0 +1,55 @@
+package util
+
+import "strings"
+
+// DeviceFromUserAgent return a readable device name like `Chrome on Windows`,
+// it is only shown to users to recognize their sessions.
+func DeviceFromUserAgent(userAgent string) string {
+	var platform, browser string
+	switch {
+	case strings.Contains(userAgent, "iPhone"):
+		platform = "iPhone"
+	case strings.Contains(userAgent, "iPad"):
+		platform = "iPad"
+	case strings.Contains(userAgent, "Android"):
+		platform = "Android"
+	case strings.Contains(userAgent, "Windows"):
+		platform = "Windows"
+	case strings.Contains(userAgent, "Mac OS X"), strings.Contains(userAgent, "Macintosh"):
+		platform = "macOS"
+	case strings.Contains(userAgent, "CrOS"):
+		platform = "ChromeOS"
+	case strings.Contains(userAgent, "Linux"):
+		platform = "Linux"
+	}
+
+	// order matters, most browsers pretend to be Chrome and Safari
+	switch {
+	case strings.Contains(userAgent, "Lark"), strings.Contains(userAgent, "Feishu"):
+		browser = "Lark"
+	case strings.Contains(userAgent, "MicroMessenger"):
+		browser = "WeChat"
+	case strings.Contains(userAgent, "QQ/"):
+		browser = "QQ"
+	case strings.Contains(userAgent, "Edg"):
+		browser = "Edge"
+	case strings.Contains(userAgent, "OPR/"), strings.Contains(userAgent, "Opera"):
+		browser = "Opera"
+	case strings.Contains(userAgent, "Firefox"), strings.Contains(userAgent, "FxiOS"):
+		browser = "Firefox"
+	case strings.Contains(userAgent, "Chrome"), strings.Contains(userAgent, "CriOS"):
+		browser = "Chrome"
+	case strings.Contains(userAgent, "Safari"):
+		browser = "Safari"
+	}
+
+	switch {
+	case browser != "" && platform != "":
+		return browser + " on " + platform
+	case browser != "":
+		return browser
+	case platform != "":
+		return platform
+	}
+	return "Unknown device"
+}
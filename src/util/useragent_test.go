@@ -0,0 +1,21 @@
+package util
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestDeviceFromUserAgent(t *testing.T) {
+	Convey("Test device name from user agent", t, func() {
+		So(DeviceFromUserAgent("Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36"),
+			ShouldEqual, "Chrome on Windows")
+		So(DeviceFromUserAgent("Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36 Edg/120.0.0.0"),
+			ShouldEqual, "Edge on Windows")
+		So(DeviceFromUserAgent("Mozilla/5.0 (iPhone; CPU iPhone OS 17_0 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.0 Mobile/15E148 Safari/604.1"),
+			ShouldEqual, "Safari on iPhone")
+		So(DeviceFromUserAgent("Mozilla/5.0 (X11; Linux x86_64; rv:121.0) Gecko/20100101 Firefox/121.0"),
+			ShouldEqual, "Firefox on Linux")
+		So(DeviceFromUserAgent("curl/8.0"), ShouldEqual, "Unknown device")
+	})
+}
@@ -0,0 +1,210 @@
+package util
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"math/big"
+)
+
+// WebAuthn (https://www.w3.org/TR/webauthn-2/) helpers.
+// Only attestation conveyance "none" is supported,
+// the attestation statement is never verified.
+
+// Authenticator data flags
+const (
+	WebAuthnFlagUserPresent  = 0x01
+	WebAuthnFlagUserVerified = 0x04
+	WebAuthnFlagAttestedData = 0x40
+	WebAuthnFlagExtension    = 0x80
+)
+
+// COSE algorithm identifiers
+const (
+	COSEAlgES256 = -7
+	COSEAlgEdDSA = -8
+	COSEAlgRS256 = -257
+)
+
+var (
+	ErrWebAuthnMalformed        = errors.New("malformed webauthn data")
+	ErrWebAuthnUnsupportedKey   = errors.New("unsupported credential public key")
+	ErrWebAuthnInvalidSignature = errors.New("invalid webauthn signature")
+)
+
+// CollectedClientData is the clientDataJSON signed by authenticator
+type CollectedClientData struct {
+	Type        string `json:"type"`
+	Challenge   string `json:"challenge"`
+	Origin      string `json:"origin"`
+	CrossOrigin bool   `json:"crossOrigin"`
+}
+
+// AuthenticatorData is the parsed authenticatorData,
+// CredentialID and PublicKey are set only on registration.
+type AuthenticatorData struct {
+	RPIDHash     []byte
+	Flags        byte
+	SignCount    uint32
+	AAGUID       []byte
+	CredentialID []byte
+	// COSE_Key encoded in CBOR
+	PublicKey []byte
+}
+
+// ParseClientData parse clientDataJSON
+func ParseClientData(clientDataJSON []byte) (*CollectedClientData, error) {
+	var clientData CollectedClientData
+	if err := json.Unmarshal(clientDataJSON, &clientData); err != nil {
+		return nil, ErrWebAuthnMalformed
+	}
+	return &clientData, nil
+}
+
+// ParseAttestationObject return the attestation format and raw authenticator data
+func ParseAttestationObject(attestationObject []byte) (format string, authData []byte, err error) {
+	value, _, err := CBORDecode(attestationObject)
+	if err != nil {
+		return "", nil, err
+	}
+	m, ok := value.(map[interface{}]interface{})
+	if !ok {
+		return "", nil, ErrWebAuthnMalformed
+	}
+	format, _ = m["fmt"].(string)
+	authData, ok = m["authData"].([]byte)
+	if !ok {
+		return "", nil, ErrWebAuthnMalformed
+	}
+	return format, authData, nil
+}
+
+// ParseAuthenticatorData parse authenticatorData,
+// see https://www.w3.org/TR/webauthn-2/#sctn-authenticator-data
+func ParseAuthenticatorData(b []byte) (*AuthenticatorData, error) {
+	if len(b) < 37 {
+		return nil, ErrWebAuthnMalformed
+	}
+	data := &AuthenticatorData{
+		RPIDHash:  b[:32],
+		Flags:     b[32],
+		SignCount: binary.BigEndian.Uint32(b[33:37]),
+	}
+	if data.Flags&WebAuthnFlagAttestedData == 0 {
+		return data, nil
+	}
+
+	rest := b[37:]
+	if len(rest) < 18 {
+		return nil, ErrWebAuthnMalformed
+	}
+	data.AAGUID = rest[:16]
+	idLength := int(binary.BigEndian.Uint16(rest[16:18]))
+	rest = rest[18:]
+	if len(rest) < idLength {
+		return nil, ErrWebAuthnMalformed
+	}
+	data.CredentialID = rest[:idLength]
+	rest = rest[idLength:]
+
+	// the public key may be followed by extensions
+	_, after, err := CBORDecode(rest)
+	if err != nil {
+		return nil, err
+	}
+	data.PublicKey = rest[:len(rest)-len(after)]
+	return data, nil
+}
+
+// RPIDHashMatch check the rpIdHash of authenticator data
+func (d *AuthenticatorData) RPIDHashMatch(rpID string) bool {
+	sum := sha256.Sum256([]byte(rpID))
+	return string(d.RPIDHash) == string(sum[:])
+}
+
+// COSEKeyAlgorithm return the alg of COSE_Key
+func COSEKeyAlgorithm(coseKey []byte) (int64, error) {
+	key, err := decodeCOSEKey(coseKey)
+	if err != nil {
+		return 0, err
+	}
+	alg, ok := key[3].(int64)
+	if !ok {
+		return 0, ErrWebAuthnUnsupportedKey
+	}
+	return alg, nil
+}
+
+// VerifyCOSESignature verify sig of data with the COSE_Key public key,
+// ES256, RS256 and EdDSA (Ed25519) are supported.
+func VerifyCOSESignature(coseKey, data, sig []byte) error {
+	key, err := decodeCOSEKey(coseKey)
+	if err != nil {
+		return err
+	}
+	alg, _ := key[3].(int64)
+	kty, _ := key[1].(int64)
+	digest := sha256.Sum256(data)
+
+	switch {
+	case alg == COSEAlgES256 && kty == 2:
+		x, _ := key[-2].([]byte)
+		y, _ := key[-3].([]byte)
+		if crv, _ := key[-1].(int64); crv != 1 || len(x) != 32 || len(y) != 32 {
+			return ErrWebAuthnUnsupportedKey
+		}
+		pub := &ecdsa.PublicKey{Curve: elliptic.P256(), X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+		if !pub.Curve.IsOnCurve(pub.X, pub.Y) {
+			return ErrWebAuthnUnsupportedKey
+		}
+		if !ecdsa.VerifyASN1(pub, digest[:], sig) {
+			return ErrWebAuthnInvalidSignature
+		}
+	case alg == COSEAlgRS256 && kty == 3:
+		n, _ := key[-1].([]byte)
+		e, _ := key[-2].([]byte)
+		if len(n) < 256 || len(e) == 0 || len(e) > 4 {
+			return ErrWebAuthnUnsupportedKey
+		}
+		pub := &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}
+		if err := rsa.VerifyPKCS1v15(pub, crypto.SHA256, digest[:], sig); err != nil {
+			return ErrWebAuthnInvalidSignature
+		}
+	case alg == COSEAlgEdDSA && kty == 1:
+		x, _ := key[-2].([]byte)
+		if crv, _ := key[-1].(int64); crv != 6 || len(x) != ed25519.PublicKeySize {
+			return ErrWebAuthnUnsupportedKey
+		}
+		if !ed25519.Verify(ed25519.PublicKey(x), data, sig) {
+			return ErrWebAuthnInvalidSignature
+		}
+	default:
+		return ErrWebAuthnUnsupportedKey
+	}
+	return nil
+}
+
+// decodeCOSEKey decode COSE_Key, labels are integers
+func decodeCOSEKey(coseKey []byte) (map[int64]interface{}, error) {
+	value, _, err := CBORDecode(coseKey)
+	if err != nil {
+		return nil, err
+	}
+	m, ok := value.(map[interface{}]interface{})
+	if !ok {
+		return nil, ErrWebAuthnUnsupportedKey
+	}
+	key := make(map[int64]interface{}, len(m))
+	for k, v := range m {
+		if label, ok := k.(int64); ok {
+			key[label] = v
+		}
+	}
+	return key, nil
+}
@@ -0,0 +1,138 @@
+package util
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// es256COSEKey encode an ES256 public key as COSE_Key
+func es256COSEKey(pub *ecdsa.PublicKey) []byte {
+	// {1: 2, 3: -7, -1: 1, -2: x, -3: y}
+	key := []byte{0xa5, 0x01, 0x02, 0x03, 0x26, 0x20, 0x01, 0x21, 0x58, 0x20}
+	key = append(key, pub.X.FillBytes(make([]byte, 32))...)
+	key = append(key, 0x22, 0x58, 0x20)
+	return append(key, pub.Y.FillBytes(make([]byte, 32))...)
+}
+
+func TestCBORDecode(t *testing.T) {
+	Convey("Test decode CBOR map", t, func() {
+		// {"fmt": "none", "n": [1, -2, h'0102'], "ok": true}
+		b := []byte{0xa3,
+			0x63, 'f', 'm', 't', 0x64, 'n', 'o', 'n', 'e',
+			0x61, 'n', 0x83, 0x01, 0x21, 0x42, 0x01, 0x02,
+			0x62, 'o', 'k', 0xf5,
+			0xff}
+		value, rest, err := CBORDecode(b)
+		So(err, ShouldBeNil)
+		So(rest, ShouldResemble, []byte{0xff})
+		m := value.(map[interface{}]interface{})
+		So(m["fmt"], ShouldEqual, "none")
+		So(m["n"], ShouldResemble, []interface{}{int64(1), int64(-2), []byte{1, 2}})
+		So(m["ok"], ShouldEqual, true)
+	})
+
+	Convey("Test reject truncated CBOR", t, func() {
+		_, _, err := CBORDecode([]byte{0x58, 0x20, 0x01})
+		So(err, ShouldEqual, ErrCBORMalformed)
+		_, _, err = CBORDecode([]byte{0x9f})
+		So(err, ShouldEqual, ErrCBORMalformed)
+	})
+
+	Convey("Test reject malformed CBOR", t, func() {
+		deep := make([]byte, cborMaxDepth+2)
+		for i := range deep {
+			deep[i] = 0x81
+		}
+		for _, b := range [][]byte{
+			{},
+			// truncated arguments
+			{0x18}, {0x19, 0x01}, {0x1a, 0x01, 0x02}, {0x1b, 0x01},
+			// reserved additional information
+			{0x1c}, {0x1d}, {0x1e},
+			// indefinite lengths
+			{0x5f, 0x41, 0x01, 0xff}, {0x7f, 0xff}, {0xbf, 0xff},
+			// integers out of int64
+			{0x1b, 0x80, 0, 0, 0, 0, 0, 0, 0},
+			{0x3b, 0x80, 0, 0, 0, 0, 0, 0, 0},
+			// lengths beyond the input
+			{0x7a, 0xff, 0xff, 0xff, 0xff, 'a'},
+			{0x9b, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff},
+			{0xa2, 0x01, 0x02},
+			// map keys other than integers and text strings, duplicate keys
+			{0xa1, 0x41, 0x01, 0x02},
+			{0xa1, 0x80, 0x02},
+			{0xa2, 0x01, 0x02, 0x01, 0x03},
+			// simple value in the next byte, break without indefinite length
+			{0xf8, 0x20}, {0xff},
+			// tag without the tagged item
+			{0xc2},
+			deep,
+		} {
+			_, _, err := CBORDecode(b)
+			So(err, ShouldEqual, ErrCBORMalformed)
+		}
+	})
+}
+
+func FuzzCBORDecode(f *testing.F) {
+	priv, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	f.Add(es256COSEKey(&priv.PublicKey))
+	f.Add([]byte{0xa2, 0x63, 'f', 'm', 't', 0x64, 'n', 'o', 'n', 'e', 0x01, 0x82, 0xf9, 0x3c, 0x00, 0xc2, 0x41, 0x01})
+	f.Add([]byte{0x9f})
+	f.Fuzz(func(t *testing.T, b []byte) {
+		value, rest, err := CBORDecode(b)
+		if err != nil {
+			if value != nil || rest != nil {
+				t.Fatalf("CBORDecode(%x) return %v, %x with error", b, value, rest)
+			}
+			return
+		}
+		// a data item is at least one byte
+		if len(rest) >= len(b) || string(b[len(b)-len(rest):]) != string(rest) {
+			t.Fatalf("CBORDecode(%x) return rest %x", b, rest)
+		}
+		// the WebAuthn parsers built on it must not panic either
+		_, _, _ = ParseAttestationObject(b)
+		_, _ = COSEKeyAlgorithm(b)
+		_ = VerifyCOSESignature(b, b, b)
+	})
+}
+
+func TestWebAuthn(t *testing.T) {
+	priv, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	coseKey := es256COSEKey(&priv.PublicKey)
+	credentialID := []byte("credential-id")
+
+	rpIDHash := sha256.Sum256([]byte("link.sast.fun"))
+	authData := append(rpIDHash[:], WebAuthnFlagUserPresent|WebAuthnFlagAttestedData, 0, 0, 0, 7)
+	authData = append(authData, make([]byte, 16)...)
+	authData = append(authData, 0, byte(len(credentialID)))
+	authData = append(authData, credentialID...)
+	authData = append(authData, coseKey...)
+
+	Convey("Test parse authenticator data with attested credential", t, func() {
+		data, err := ParseAuthenticatorData(authData)
+		So(err, ShouldBeNil)
+		So(data.RPIDHashMatch("link.sast.fun"), ShouldBeTrue)
+		So(data.RPIDHashMatch("evil.example"), ShouldBeFalse)
+		So(data.SignCount, ShouldEqual, 7)
+		So(data.CredentialID, ShouldResemble, credentialID)
+		So(data.PublicKey, ShouldResemble, coseKey)
+		alg, err := COSEKeyAlgorithm(data.PublicKey)
+		So(err, ShouldBeNil)
+		So(alg, ShouldEqual, COSEAlgES256)
+	})
+
+	Convey("Test verify ES256 assertion signature", t, func() {
+		signed := append(append([]byte(nil), authData[:37]...), []byte("client data hash")...)
+		digest := sha256.Sum256(signed)
+		sig, _ := ecdsa.SignASN1(rand.Reader, priv, digest[:])
+		So(VerifyCOSESignature(coseKey, signed, sig), ShouldBeNil)
+		So(VerifyCOSESignature(coseKey, append(signed, 0), sig), ShouldEqual, ErrWebAuthnInvalidSignature)
+	})
+}